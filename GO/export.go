@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"treehole/internal/config"
+	"treehole/internal/database"
+	"treehole/internal/export"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// runExport 执行 export 子命令，返回进程退出码
+// 用法: treehole export -format jsonl|csv|sqlite -out FILE [-from YYYY-MM-DD] [-to YYYY-MM-DD] [-state normal,hot] [-redact] [-include-hidden]
+func runExport(cfg *config.Config, args []string) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", export.FormatJSONL, "export format: jsonl, csv or sqlite")
+	out := flags.String("out", "-", "output file, - for stdout (jsonl and csv only)")
+	from := flags.String("from", "", "only posts created on or after this date (YYYY-MM-DD or RFC3339)")
+	to := flags.String("to", "", "only posts created on or before this date (YYYY-MM-DD or RFC3339)")
+	state := flags.String("state", "", "comma separated post states to include")
+	redact := flags.Bool("redact", false, "blank IP, author IDs and wechat fields")
+	includeHidden := flags.Bool("include-hidden", false, "include posts and replies hidden by moderators")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	opts := export.Options{
+		States:        export.ParseStates(*state),
+		Redact:        *redact,
+		IncludeHidden: *includeHidden,
+	}
+	var err error
+	if opts.From, err = export.ParseDate(*from, false); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if opts.To, err = export.ParseDate(*to, true); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	db, ok := openCommandDB(cfg)
+	if !ok {
+		return 1
+	}
+
+	if *format == export.FormatSQLite {
+		if *out == "-" {
+			fmt.Fprintln(os.Stderr, "sqlite export requires -out FILE")
+			return 2
+		}
+		if err := export.Snapshot(db, *out, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Export failed: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "Snapshot written to %s\n", *out)
+		return 0
+	}
+
+	w := os.Stdout
+	if *out != "-" {
+		file, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+	count, err := export.Write(db, w, *format, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Export failed: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d posts\n", count)
+	return 0
+}
+
+// openCommandDB 为子命令连接并迁移数据库
+// SQL 日志默认写到标准输出，导出到标准输出时会混入数据，改写到标准错误并关闭查询日志
+func openCommandDB(cfg *config.Config) (*gorm.DB, bool) {
+	logger.Default = logger.New(log.New(os.Stderr, "\r\n", log.LstdFlags), logger.Config{LogLevel: logger.Warn})
+	db, err := database.InitDB(cfg.DatabaseURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		return nil, false
+	}
+	db = db.Session(&gorm.Session{Logger: logger.Default.LogMode(logger.Silent)})
+	if err := database.Migrate(db); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to migrate database: %v\n", err)
+		return nil, false
+	}
+	return db, true
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"treehole/internal/config"
+	"treehole/internal/importer"
+)
+
+// runImport 执行 import 子命令，返回进程退出码
+// 用法: treehole import -in FILE [-policy newer|keep|overwrite] [-dry-run]
+func runImport(cfg *config.Config, args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	in := flags.String("in", "-", "JSONL file to import, - for stdin")
+	policy := flags.String("policy", importer.PolicyNewer, "conflict policy: newer, keep or overwrite")
+	dryRun := flags.Bool("dry-run", false, "report what would change without writing")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if !importer.ValidPolicy(*policy) {
+		fmt.Fprintln(os.Stderr, importer.ErrInvalidPolicy)
+		return 2
+	}
+
+	var r io.Reader = os.Stdin
+	if *in != "-" {
+		file, err := os.Open(*in)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer file.Close()
+		r = file
+	}
+
+	db, ok := openCommandDB(cfg)
+	if !ok {
+		return 1
+	}
+	report, err := importer.NewService(db).Import(r, importer.Options{Policy: *policy, DryRun: *dryRun})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Import failed: %v\n", err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(report); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(report.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,482 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"treehole/internal/models"
+	"treehole/internal/moderation"
+	"treehole/internal/webhook"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// adminActorKey 认证通过后保存在上下文中的管理员标识
+const adminActorKey = "admin_actor"
+
+// adminPrincipalKey 请求中管理员凭证的认证结果，每个请求只认证一次
+const adminPrincipalKey = "admin_principal"
+
+// 每个 IP 每分钟最多尝试登录的次数
+const adminLoginRateLimit = 5
+
+// hashSecret 计算密钥或会话令牌的哈希，数据库中只保存哈希
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateSecret 生成随机密钥
+func generateSecret(prefix string) (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(buf), nil
+}
+
+// bearerToken 从请求头中读取管理员凭证
+func bearerToken(c *gin.Context) string {
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return c.GetHeader("X-Admin-Key")
+}
+
+// authenticateAPIKey 校验管理员 API 密钥，返回操作者标识
+func (h *Handler) authenticateAPIKey(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	hash := hashSecret(key)
+
+	// 环境变量中配置的密钥
+	for _, configured := range h.config.AdminAPIKeyHashes {
+		if subtle.ConstantTimeCompare([]byte(strings.ToLower(configured)), []byte(hash)) == 1 {
+			return "env:" + hash[:8], true
+		}
+	}
+
+	// 数据库中管理的密钥
+	var apiKey models.AdminAPIKey
+	if err := h.db.Where("key_hash = ? AND revoked_at IS NULL", hash).First(&apiKey).Error; err != nil {
+		return "", false
+	}
+	now := time.Now()
+	h.db.Model(&apiKey).Update("last_used_at", &now)
+	return "key:" + apiKey.Name, true
+}
+
+// authenticateSession 校验管理员会话令牌，返回操作者标识
+func (h *Handler) authenticateSession(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	var session models.AdminSession
+	if err := h.db.Where("token_hash = ? AND expires_at > ?", hashSecret(token), time.Now()).First(&session).Error; err != nil {
+		return "", false
+	}
+	return session.Actor, true
+}
+
+// adminPrincipal 请求携带的有效管理员凭证
+type adminPrincipal struct {
+	actor          string
+	apiKey         bool   // 凭证是 API 密钥，否则是登录会话
+	credentialHash string // 凭证的哈希，用于绑定 CSRF 令牌
+}
+
+// authenticate 认证请求中的管理员凭证（API 密钥或登录会话），没有有效凭证时返回 nil
+// 结果保存在上下文中，CSRF 校验、字段可见策略和管理员认证共用，避免重复查询和更新密钥的使用时间
+func (h *Handler) authenticate(c *gin.Context) *adminPrincipal {
+	if value, ok := c.Get(adminPrincipalKey); ok {
+		return value.(*adminPrincipal)
+	}
+	var principal *adminPrincipal
+	if token := bearerToken(c); token != "" {
+		if actor, ok := h.authenticateAPIKey(token); ok {
+			principal = &adminPrincipal{actor: actor, apiKey: true, credentialHash: hashSecret(token)}
+		} else if actor, ok := h.authenticateSession(token); ok {
+			principal = &adminPrincipal{actor: actor, credentialHash: hashSecret(token)}
+		}
+	}
+	c.Set(adminPrincipalKey, principal)
+	return principal
+}
+
+// AdminAuthMiddleware 管理员认证中间件，支持 API 密钥和登录会话
+func (h *Handler) AdminAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := h.authenticate(c)
+		if principal == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin authentication required"})
+			c.Abort()
+			return
+		}
+
+		c.Set(adminActorKey, principal.actor)
+		c.Next()
+	}
+}
+
+// adminActor 获取当前管理员标识
+func adminActor(c *gin.Context) string {
+	return c.GetString(adminActorKey)
+}
+
+// recordAudit 操作完成后写入审计日志，写入失败时只记录错误，不影响已经完成的操作
+func (h *Handler) recordAudit(c *gin.Context, action, targetType string, targetID uint, details map[string]interface{}) {
+	if err := h.moderation.Record(h.db, adminActor(c), action, targetType, targetID, "", details); err != nil {
+		log.Printf("Failed to record audit log for %s: %v", action, err)
+	}
+}
+
+// AdminLoginRequest 管理员登录请求
+type AdminLoginRequest struct {
+	APIKey string `json:"api_key" binding:"required"`
+}
+
+// AdminLogin 使用 API 密钥换取有时效的会话令牌
+func (h *Handler) AdminLogin(c *gin.Context) {
+	if !h.rateLimiter.Allow("admin-login|"+c.ClientIP(), adminLoginRateLimit, time.Minute) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many login attempts, please try again later"})
+		return
+	}
+
+	var req AdminLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	actor, ok := h.authenticateAPIKey(req.APIKey)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		return
+	}
+
+	token, err := generateSecret("ths_")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+	session := models.AdminSession{
+		TokenHash: hashSecret(token),
+		Actor:     actor,
+		ExpiresAt: time.Now().Add(h.config.AdminSessionTTL),
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// 顺带清理过期会话
+		if err := tx.Where("expires_at <= ?", time.Now()).Delete(&models.AdminSession{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		return h.moderation.Record(tx, actor, "login", "", 0, "", nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":      token,
+		"actor":      actor,
+		"expires_at": session.ExpiresAt,
+	})
+}
+
+// AdminLogout 注销当前会话
+func (h *Handler) AdminLogout(c *gin.Context) {
+	h.db.Where("token_hash = ?", hashSecret(bearerToken(c))).Delete(&models.AdminSession{})
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// CreateAdminKeyRequest 创建 API 密钥请求
+type CreateAdminKeyRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// ListAdminKeys 获取 API 密钥列表
+func (h *Handler) ListAdminKeys(c *gin.Context) {
+	var keys []models.AdminAPIKey
+	if err := h.db.Order("id asc").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
+
+// CreateAdminKey 创建 API 密钥，明文只在创建时返回一次
+func (h *Handler) CreateAdminKey(c *gin.Context) {
+	var req CreateAdminKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name, err := validateAndSanitizeInput(req.Name, 50)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, err := generateSecret("thk_")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate key"})
+		return
+	}
+	apiKey := models.AdminAPIKey{Name: name, KeyHash: hashSecret(key)}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&apiKey).Error; err != nil {
+			return err
+		}
+		return h.moderation.Record(tx, adminActor(c), "create_key", "admin_key", apiKey.ID, "", map[string]interface{}{"name": name})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"key": key, "api_key": apiKey})
+}
+
+// RevokeAdminKey 吊销 API 密钥
+func (h *Handler) RevokeAdminKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid key id"})
+		return
+	}
+
+	now := time.Now()
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.AdminAPIKey{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", &now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return h.moderation.Record(tx, adminActor(c), "revoke_key", "admin_key", uint(id), "", nil)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Key revoked"})
+}
+
+// GetModerationQueue 获取审核队列
+func (h *Handler) GetModerationQueue(c *gin.Context) {
+	page, limit := parsePagination(c)
+	status := c.DefaultQuery("status", moderation.StatusPending)
+	if status == "all" {
+		status = ""
+	}
+
+	items, total, err := h.moderation.Queue(status, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":      items,
+		"pagination": paginationMeta(page, limit, total),
+	})
+}
+
+// ModerationActionRequest 审核操作请求
+type ModerationActionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// DismissModerationItem 驳回审核队列条目
+func (h *Handler) DismissModerationItem(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid moderation item id"})
+		return
+	}
+
+	var req ModerationActionRequest
+	c.ShouldBindJSON(&req)
+
+	if err := h.moderation.Dismiss(adminActor(c), uint(id), req.Reason); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Moderation item not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Moderation item dismissed"})
+}
+
+// Moderate 返回对帖子或回复执行审核操作的处理器
+func (h *Handler) Moderate(targetType, action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+			return
+		}
+
+		var req ModerationActionRequest
+		c.ShouldBindJSON(&req)
+
+		// 永久删除后无法再读取帖子，事件内容需要提前读取
+		var post models.Post
+		postFound := targetType == moderation.TargetPost &&
+			h.db.Unscoped().Where("id = ?", id).Limit(1).Find(&post).RowsAffected > 0
+
+		if err := h.moderation.Apply(adminActor(c), action, targetType, uint(id), req.Reason); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Target not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if postFound {
+			go h.webhooks.Emit(webhook.Event{Type: webhook.EventPostStateChanged, Post: &post, Data: map[string]interface{}{
+				"moderation_action": action,
+			}})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "Moderation action applied",
+			"action":      action,
+			"target_type": targetType,
+			"target_id":   id,
+		})
+	}
+}
+
+// publishReleased 审核通过的新内容首次发布后推送创建事件并同步到主站，与直接发布的内容一致
+func (h *Handler) publishReleased(targetType string, targetID uint) {
+	var post models.Post
+	var reply models.Reply
+	switch targetType {
+	case moderation.TargetPost:
+		if err := h.db.First(&post, targetID).Error; err != nil {
+			log.Printf("Failed to load released post %d: %v", targetID, err)
+			return
+		}
+		go h.webhooks.Emit(webhook.Event{Type: webhook.EventPostCreated, Post: &post})
+	case moderation.TargetReply:
+		if err := h.db.First(&reply, targetID).Error; err != nil {
+			log.Printf("Failed to load released reply %d: %v", targetID, err)
+			return
+		}
+		if err := h.db.First(&post, reply.PostID).Error; err != nil {
+			log.Printf("Failed to load post %d of released reply %d: %v", reply.PostID, targetID, err)
+			return
+		}
+		go h.webhooks.Emit(webhook.Event{Type: webhook.EventReplyCreated, Post: &post, Reply: &reply})
+	default:
+		return
+	}
+
+	if !h.config.OutboundSyncEnabled {
+		return
+	}
+	go func() {
+		var err error
+		if targetType == moderation.TargetPost {
+			err = h.scraperService.SyncPostToMainSite(post)
+		} else {
+			err = h.scraperService.SyncReplyToMainSite(post, reply)
+		}
+		if err != nil {
+			log.Printf("Failed to sync released %s %d to main site: %v", targetType, targetID, err)
+		}
+	}()
+}
+
+// GetAuditLogs 获取审计日志
+func (h *Handler) GetAuditLogs(c *gin.Context) {
+	page, limit := parsePagination(c)
+
+	logs, total, err := h.moderation.AuditLogs(c.Query("actor"), c.Query("action"), page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"logs":       logs,
+		"pagination": paginationMeta(page, limit, total),
+	})
+}
+
+// TriggerSync 触发同步
+func (h *Handler) TriggerSync(c *gin.Context) {
+	if h.scheduler.IsRunning() {
+		c.JSON(http.StatusConflict, gin.H{"error": "Sync job is already running"})
+		return
+	}
+
+	if err := h.moderation.Record(h.db, adminActor(c), "trigger_sync", "", 0, "", nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 异步执行同步
+	go func() {
+		if err := h.scheduler.TriggerSync(); err != nil {
+			log.Printf("Manual sync failed: %v", err)
+		}
+	}()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sync started"})
+}
+
+// ReloadFilter 重新加载内容过滤词表
+func (h *Handler) ReloadFilter(c *gin.Context) {
+	blockCount, reviewCount, err := h.filter.Reload()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.recordAudit(c, "reload_filter", "", 0, map[string]interface{}{
+		"block_words":  blockCount,
+		"review_words": reviewCount,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"block_words":  blockCount,
+		"review_words": reviewCount,
+	})
+}
+
+// CheckFilterRequest 过滤规则测试请求
+type CheckFilterRequest struct {
+	Text string `json:"text" binding:"required"`
+}
+
+// CheckFilter 测试一段文本的过滤结果，不参与重复发帖检测
+func (h *Handler) CheckFilter(c *gin.Context) {
+	var req CheckFilterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, h.filter.Preview(req.Text))
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"treehole/internal/backup"
+	"treehole/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListBackups 获取备份历史，按时间倒序，可按状态筛选
+func (h *Handler) ListBackups(c *gin.Context) {
+	page, limit := parsePagination(c)
+
+	query := h.db.Model(&models.Backup{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var backups []models.Backup
+	if err := query.Order("id desc").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&backups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	cfg := h.backups.Config()
+	c.JSON(http.StatusOK, gin.H{
+		"backups":    backups,
+		"pagination": paginationMeta(page, limit, total),
+		"config": gin.H{
+			"cron":    h.config.BackupCron,
+			"dir":     cfg.Dir,
+			"keep":    cfg.Keep,
+			"max_age": cfg.MaxAge.String(),
+		},
+	})
+}
+
+// CreateBackup 立即执行一次备份
+func (h *Handler) CreateBackup(c *gin.Context) {
+	actor := adminActor(c)
+	record, err := h.backups.Run(actor)
+	switch {
+	case errors.Is(err, backup.ErrRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, backup.ErrUnsupported):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil && record == nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.recordAudit(c, "create_backup", "backup", record.ID, map[string]interface{}{
+		"file_name": record.FileName,
+		"status":    record.Status,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "backup": record})
+		return
+	}
+	c.JSON(http.StatusCreated, record)
+}
@@ -0,0 +1,379 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"treehole/internal/bookmark"
+	"treehole/internal/database"
+	"treehole/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// BookmarkCollectionRequest 创建或重命名收藏夹的请求
+type BookmarkCollectionRequest struct {
+	Name string `json:"name" binding:"required,max=64"`
+}
+
+// AddBookmarkRequest 收藏帖子的请求
+type AddBookmarkRequest struct {
+	PostID string `json:"post_id" binding:"required"`
+	Note   string `json:"note" binding:"max=200"`
+}
+
+// collectionWithCount 收藏夹及其中的帖子数量
+type collectionWithCount struct {
+	models.BookmarkCollection
+	Count int64 `json:"count"`
+}
+
+// findCollection 查找当前身份的收藏夹，找不到时直接写入错误响应
+// id 为 default 时使用默认收藏夹，create 为 true 时不存在则创建
+func (h *Handler) findCollection(c *gin.Context, subject string, create bool) (*models.BookmarkCollection, bool) {
+	var collection models.BookmarkCollection
+	var result *gorm.DB
+	if c.Param("id") == "default" {
+		result = h.db.Where("subscriber = ? AND name = ?", subject, bookmark.DefaultCollectionName).
+			Order("id asc").Limit(1).Find(&collection)
+	} else {
+		result = h.db.Where("id = ? AND subscriber = ?", c.Param("id"), subject).Limit(1).Find(&collection)
+	}
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return nil, false
+	}
+	if result.RowsAffected > 0 {
+		return &collection, true
+	}
+	if c.Param("id") != "default" || !create {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return nil, false
+	}
+
+	collection = models.BookmarkCollection{Subscriber: subject, Name: bookmark.DefaultCollectionName}
+	if err := h.db.Create(&collection).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &collection, true
+}
+
+// bindCollectionName 解析并校验收藏夹名称
+func bindCollectionName(c *gin.Context) (string, bool) {
+	var req BookmarkCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	name, err := validateAndSanitizeInput(strings.TrimSpace(req.Name), 64)
+	if err != nil || name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection name"})
+		return "", false
+	}
+	return name, true
+}
+
+// ListBookmarkCollections 获取当前身份的收藏夹及收藏数量
+func (h *Handler) ListBookmarkCollections(c *gin.Context) {
+	subject, ok := h.requireSubject(c)
+	if !ok {
+		return
+	}
+	var collections []models.BookmarkCollection
+	if err := h.db.Where("subscriber = ?", subject).Order("id asc").Find(&collections).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ids := make([]uint, 0, len(collections))
+	for _, collection := range collections {
+		ids = append(ids, collection.ID)
+	}
+	var counts []struct {
+		CollectionID uint
+		Total        int64
+	}
+	if len(ids) > 0 {
+		if err := h.db.Model(&models.Bookmark{}).
+			Select("collection_id, COUNT(*) AS total").
+			Where("collection_id IN ?", ids).
+			Group("collection_id").
+			Scan(&counts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	countByID := make(map[uint]int64, len(counts))
+	for _, count := range counts {
+		countByID[count.CollectionID] = count.Total
+	}
+
+	result := make([]collectionWithCount, 0, len(collections))
+	for _, collection := range collections {
+		result = append(result, collectionWithCount{BookmarkCollection: collection, Count: countByID[collection.ID]})
+	}
+	c.JSON(http.StatusOK, gin.H{"collections": result})
+}
+
+// CreateBookmarkCollection 创建收藏夹
+func (h *Handler) CreateBookmarkCollection(c *gin.Context) {
+	subject, ok := h.requireSubject(c)
+	if !ok {
+		return
+	}
+	name, ok := bindCollectionName(c)
+	if !ok {
+		return
+	}
+
+	var count int64
+	if err := h.db.Model(&models.BookmarkCollection{}).Where("subscriber = ?", subject).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if count >= bookmark.MaxCollections {
+		c.JSON(http.StatusConflict, gin.H{"error": "Too many collections"})
+		return
+	}
+
+	collection := models.BookmarkCollection{Subscriber: subject, Name: name}
+	if err := h.db.Create(&collection).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, collection)
+}
+
+// RenameBookmarkCollection 重命名收藏夹
+func (h *Handler) RenameBookmarkCollection(c *gin.Context) {
+	subject, ok := h.requireSubject(c)
+	if !ok {
+		return
+	}
+	collection, ok := h.findCollection(c, subject, false)
+	if !ok {
+		return
+	}
+	name, ok := bindCollectionName(c)
+	if !ok {
+		return
+	}
+	collection.Name = name
+	if err := h.db.Save(collection).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, collection)
+}
+
+// DeleteBookmarkCollection 删除收藏夹及其中的收藏
+func (h *Handler) DeleteBookmarkCollection(c *gin.Context) {
+	subject, ok := h.requireSubject(c)
+	if !ok {
+		return
+	}
+	collection, ok := h.findCollection(c, subject, false)
+	if !ok {
+		return
+	}
+	err := database.SafeTransaction(h.db, func(tx *gorm.DB) error {
+		if err := tx.Where("collection_id = ?", collection.ID).Delete(&models.Bookmark{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(collection).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Collection deleted"})
+}
+
+// GetBookmarks 获取收藏夹中的帖子，按收藏时间倒序
+func (h *Handler) GetBookmarks(c *gin.Context) {
+	subject, ok := h.requireSubject(c)
+	if !ok {
+		return
+	}
+	collection, ok := h.findCollection(c, subject, false)
+	if !ok {
+		return
+	}
+	page, limit := parsePagination(c)
+	view, ok := h.newViewer(c)
+	if !ok {
+		return
+	}
+
+	query := h.db.Model(&models.Bookmark{}).Where("collection_id = ?", collection.ID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var bookmarks []models.Bookmark
+	if err := query.Order("id desc").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&bookmarks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	items, err := bookmark.Resolve(h.db, bookmarks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"collection": collection,
+		"bookmarks":  view.bookmarks(items),
+		"pagination": paginationMeta(page, limit, total),
+	})
+}
+
+// AddBookmark 收藏帖子并保存内容存档，已收藏时更新备注
+func (h *Handler) AddBookmark(c *gin.Context) {
+	subject, ok := h.requireSubject(c)
+	if !ok {
+		return
+	}
+	var req AddBookmarkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	note := strings.TrimSpace(req.Note)
+	if note != "" {
+		var err error
+		if note, err = validateAndSanitizeInput(note, 200); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	post, ok := h.findPost(c, req.PostID)
+	if !ok {
+		return
+	}
+	collection, ok := h.findCollection(c, subject, true)
+	if !ok {
+		return
+	}
+
+	var count int64
+	if err := h.db.Model(&models.Bookmark{}).Where("collection_id = ?", collection.ID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var saved models.Bookmark
+	status := http.StatusCreated
+	err := database.SafeTransaction(h.db, func(tx *gorm.DB) error {
+		result := tx.Where("collection_id = ? AND post_id = ?", collection.ID, post.ID).Limit(1).Find(&saved)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			status = http.StatusOK
+			saved.Note = note
+			if err := tx.Model(&saved).Update("note", note).Error; err != nil {
+				return err
+			}
+		} else {
+			if count >= bookmark.MaxBookmarksPerCollection {
+				return bookmark.ErrCollectionFull
+			}
+			saved = models.Bookmark{CollectionID: collection.ID, PostID: post.ID, Note: note}
+			if err := tx.Create(&saved).Error; err != nil {
+				return err
+			}
+		}
+		return bookmark.Refresh(tx, post)
+	})
+	if errors.Is(err, bookmark.ErrCollectionFull) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(status, gin.H{"collection": collection, "bookmark": saved})
+}
+
+// RemoveBookmark 从收藏夹移除帖子，帖子不再被任何收藏夹收藏时删除存档
+func (h *Handler) RemoveBookmark(c *gin.Context) {
+	subject, ok := h.requireSubject(c)
+	if !ok {
+		return
+	}
+	collection, ok := h.findCollection(c, subject, false)
+	if !ok {
+		return
+	}
+
+	var removed int64
+	err := database.SafeTransaction(h.db, func(tx *gorm.DB) error {
+		result := tx.Where("collection_id = ? AND post_id = ?", collection.ID, c.Param("post_id")).Delete(&models.Bookmark{})
+		if result.Error != nil {
+			return result.Error
+		}
+		removed = result.RowsAffected
+		return tx.Where("post_id = ? AND post_id NOT IN (?)", c.Param("post_id"), bookmark.Protected(tx)).
+			Delete(&models.PostArchive{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if removed == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Bookmark removed"})
+}
+
+// ExportBookmarks 以 JSON 文件导出当前身份的所有收藏夹和帖子内容
+func (h *Handler) ExportBookmarks(c *gin.Context) {
+	subject, ok := h.requireSubject(c)
+	if !ok {
+		return
+	}
+	view, ok := h.newViewer(c)
+	if !ok {
+		return
+	}
+	var collections []models.BookmarkCollection
+	if err := h.db.Where("subscriber = ?", subject).Order("id asc").Find(&collections).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	type exportedCollection struct {
+		models.BookmarkCollection
+		Bookmarks []publicBookmarkItem `json:"bookmarks"`
+	}
+	exported := make([]exportedCollection, 0, len(collections))
+	for _, collection := range collections {
+		var bookmarks []models.Bookmark
+		if err := h.db.Where("collection_id = ?", collection.ID).Order("id asc").Find(&bookmarks).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		items, err := bookmark.Resolve(h.db, bookmarks)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		exported = append(exported, exportedCollection{BookmarkCollection: collection, Bookmarks: view.bookmarks(items)})
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="bookmarks-`+time.Now().Format("20060102")+`.json"`)
+	c.JSON(http.StatusOK, gin.H{
+		"exported_at": time.Now(),
+		"collections": exported,
+	})
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"treehole/internal/csrf"
+
+	"github.com/gin-gonic/gin"
+)
+
+// csrfTokenHeader 客户端携带 CSRF 令牌的请求头
+const csrfTokenHeader = "X-CSRF-Token"
+
+// csrfBinding CSRF 令牌绑定的请求方标识
+// 携带管理员凭证的请求绑定到凭证的哈希，携带匿名身份令牌的请求绑定到身份，两者都没有时为空标识
+func (h *Handler) csrfBinding(c *gin.Context) string {
+	var parts []string
+	if principal := h.authenticate(c); principal != nil {
+		parts = append(parts, "admin:"+principal.credentialHash)
+	}
+	if token := h.requestToken(c); token != "" {
+		parts = append(parts, "identity:"+h.identity.Subject(token))
+	}
+	return strings.Join(parts, "|")
+}
+
+// IssueCSRFToken 签发 CSRF 令牌，之后的写请求在 X-CSRF-Token 请求头中携带
+// 令牌绑定请求中的管理员凭证和匿名身份，换用其他凭证或身份后需要重新获取
+func (h *Handler) IssueCSRFToken(c *gin.Context) {
+	token, expiresAt, err := h.csrf.Issue(h.csrfBinding(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue CSRF token"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"csrf_token": token,
+		"header":     csrfTokenHeader,
+		"expires_at": expiresAt,
+	})
+}
+
+// CSRFMiddleware 校验写请求的 CSRF 令牌
+// 携带有效管理员 API 密钥的请求不是由浏览器自动发出的，不需要令牌
+func (h *Handler) CSRFMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if !h.config.CSRFProtection {
+			c.Next()
+			return
+		}
+		if principal := h.authenticate(c); principal != nil && principal.apiKey {
+			c.Next()
+			return
+		}
+
+		token := c.GetHeader(csrfTokenHeader)
+		if token == "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "CSRF token required"})
+			c.Abort()
+			return
+		}
+		if err := h.csrf.Verify(token, h.csrfBinding(c)); err != nil {
+			message := "CSRF token validation failed"
+			if errors.Is(err, csrf.ErrExpiredToken) {
+				message = "CSRF token expired"
+			}
+			c.JSON(http.StatusForbidden, gin.H{"error": message})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"treehole/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// collapseDuplicates 请求带 collapse_duplicates=true 时只返回每组重复内容的代表帖子
+// 代表帖子被隐藏时，组内其余帖子照常返回
+func collapseDuplicates(c *gin.Context) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if c.Query("collapse_duplicates") != "true" {
+			return db
+		}
+		return db.Where("posts.duplicate_of IS NULL OR NOT EXISTS " +
+			"(SELECT 1 FROM posts canonical WHERE canonical.id = posts.duplicate_of AND canonical.deleted_at IS NULL)")
+	}
+}
+
+// GetPostDuplicates 获取与帖子内容重复的其他帖子，按发布时间排序，第一条为代表帖子
+func (h *Handler) GetPostDuplicates(c *gin.Context) {
+	post, ok := h.findPost(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	view, ok := h.newViewer(c)
+	if !ok {
+		return
+	}
+
+	var fp models.PostFingerprint
+	if err := h.db.Where("post_id = ?", post.ID).First(&fp).Error; err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if fp.ClusterID == nil {
+		c.JSON(http.StatusOK, gin.H{"post_id": post.ID, "cluster": nil, "posts": []interface{}{}})
+		return
+	}
+
+	var cluster models.DuplicateCluster
+	if err := h.db.First(&cluster, *fp.ClusterID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var posts []models.Post
+	if err := h.db.Where("id <> ? AND id IN (?)", post.ID,
+		h.db.Model(&models.PostFingerprint{}).Select("post_id").Where("cluster_id = ?", cluster.ID)).
+		Order("created_at asc, id asc").
+		Find(&posts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"post_id": post.ID, "cluster": cluster, "posts": view.posts(posts)})
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+	"treehole/internal/export"
+	"treehole/internal/importer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportContentTypes 流式导出格式的响应类型
+var exportContentTypes = map[string]string{
+	export.FormatJSONL: "application/x-ndjson; charset=utf-8",
+	export.FormatCSV:   "text/csv; charset=utf-8",
+}
+
+// ExportData 导出帖子和回复，jsonl 和 csv 流式返回，sqlite 返回筛选后的数据库快照
+func (h *Handler) ExportData(c *gin.Context) {
+	format := c.DefaultQuery("format", export.FormatJSONL)
+	if format != export.FormatSQLite && exportContentTypes[format] == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": export.ErrUnsupportedFormat.Error()})
+		return
+	}
+	opts := export.Options{
+		States:        export.ParseStates(c.Query("state")),
+		Redact:        c.Query("redact") == "true",
+		IncludeHidden: c.Query("include_hidden") == "true",
+	}
+	var err error
+	if opts.From, err = export.ParseDate(c.Query("from"), false); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if opts.To, err = export.ParseDate(c.Query("to"), true); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.moderation.Record(h.db, adminActor(c), "export_data", "", 0, "", map[string]interface{}{
+		"format":         format,
+		"from":           c.Query("from"),
+		"to":             c.Query("to"),
+		"state":          opts.States,
+		"redact":         opts.Redact,
+		"include_hidden": opts.IncludeHidden,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := "treehole-" + time.Now().Format("20060102-150405") + "." + format
+	if format == export.FormatSQLite {
+		dir, err := os.MkdirTemp("", "treehole-export-")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, filename)
+		if err := export.Snapshot(h.db, path, opts); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, export.ErrSnapshotUnsupported) {
+				status = http.StatusBadRequest
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		c.FileAttachment(path, filename)
+		return
+	}
+
+	c.Header("Content-Type", exportContentTypes[format])
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+	// 响应头已发出，中途出错只能记录日志并截断输出
+	if _, err := export.Write(h.db, c.Writer, format, opts); err != nil {
+		log.Printf("Export failed: %v", err)
+	}
+}
+
+// ImportData 导入 JSONL 导出文件，请求体为文件内容，或以 multipart 表单的 file 字段上传
+func (h *Handler) ImportData(c *gin.Context) {
+	opts := importer.Options{
+		Policy: c.DefaultQuery("policy", importer.PolicyNewer),
+		DryRun: c.Query("dry_run") == "true",
+	}
+	if !importer.ValidPolicy(opts.Policy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": importer.ErrInvalidPolicy.Error()})
+		return
+	}
+
+	var body io.Reader = c.Request.Body
+	if c.ContentType() == "multipart/form-data" {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	report, err := h.importer.Import(body, opts)
+	if errors.Is(err, importer.ErrRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !opts.DryRun {
+		h.recordAudit(c, "import_data", "", 0, map[string]interface{}{
+			"policy":          opts.Policy,
+			"lines":           report.Lines,
+			"posts_created":   report.PostsCreated,
+			"posts_updated":   report.PostsUpdated,
+			"replies_created": report.RepliesCreated,
+			"replies_updated": report.RepliesUpdated,
+			"conflicts":       report.ConflictCount,
+			"errors":          len(report.Errors),
+		})
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -1,112 +1,121 @@
 package api
 
 import (
-	"crypto/rand"
-	"encoding/hex"
 	"fmt"
 	"html"
 	"log"
 	"net/http"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
+	"treehole/internal/backup"
+	"treehole/internal/classifier"
+	"treehole/internal/config"
+	"treehole/internal/csrf"
+	"treehole/internal/dedup"
+	"treehole/internal/filter"
+	"treehole/internal/identity"
+	"treehole/internal/importer"
+	"treehole/internal/media"
+	"treehole/internal/metrics"
 	"treehole/internal/models"
+	"treehole/internal/moderation"
+	"treehole/internal/notify"
+	"treehole/internal/ratelimit"
+	"treehole/internal/related"
+	"treehole/internal/retention"
+	"treehole/internal/savedsearch"
+	"treehole/internal/scheduler"
 	"treehole/internal/scraper"
+	"treehole/internal/stats"
+	"treehole/internal/subscription"
+	"treehole/internal/suggest"
+	"treehole/internal/trending"
+	"treehole/internal/webhook"
 	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-// RateLimiter 简单的速率限制器
-type RateLimiter struct {
-	requests map[string][]time.Time
-	mutex    sync.RWMutex
-}
-
-// NewRateLimiter 创建新的速率限制器
-func NewRateLimiter() *RateLimiter {
-	return &RateLimiter{
-		requests: make(map[string][]time.Time),
-	}
-}
-
-// Allow 检查是否允许请求
-func (rl *RateLimiter) Allow(key string, maxRequests int, duration time.Duration) bool {
-	rl.mutex.Lock()
-	defer rl.mutex.Unlock()
-
-	now := time.Now()
-	cutoff := now.Add(-duration)
-
-	// 获取该key的请求记录
-	requests, exists := rl.requests[key]
-	if !exists {
-		rl.requests[key] = []time.Time{now}
-		return true
-	}
-
-	// 过滤掉过期的请求
-	var validRequests []time.Time
-	for _, reqTime := range requests {
-		if reqTime.After(cutoff) {
-			validRequests = append(validRequests, reqTime)
-		}
-	}
-
-	// 检查是否超过限制
-	if len(validRequests) >= maxRequests {
-		return false
-	}
-
-	// 添加当前请求
-	validRequests = append(validRequests, now)
-	rl.requests[key] = validRequests
+// rateLimitEvictInterval 回收已恢复满的令牌桶的间隔
+const rateLimitEvictInterval = time.Minute
 
-	return true
-}
+// defaultTrustedProxies 未配置 TRUSTED_PROXIES 时信任本机的反向代理
+var defaultTrustedProxies = []string{"127.0.0.1", "::1"}
 
 // RateLimitMiddleware 速率限制中间件
-func RateLimitMiddleware(rateLimiter *RateLimiter) gin.HandlerFunc {
+// 按路由选择限流策略，每个客户端 IP 在每条策略下有一个令牌桶
+func RateLimitMiddleware(limiter *ratelimit.Limiter, policies ratelimit.Policies) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 根据IP和用户代理生成key
-		clientKey := c.ClientIP() + "|" + c.GetHeader("User-Agent")
-		
-		// 对于写操作进行更严格的限制
-		if c.Request.Method == "POST" {
-			if !rateLimiter.Allow(clientKey, 10, time.Minute) { // 每分钟最多10次POST请求
-				c.JSON(http.StatusTooManyRequests, gin.H{
-					"error": "Too many requests, please try again later",
-				})
-				c.Abort()
-				return
-			}
-		} else {
-			if !rateLimiter.Allow(clientKey, 100, time.Minute) { // 每分钟最多100次GET请求
-				c.JSON(http.StatusTooManyRequests, gin.H{
-					"error": "Too many requests, please try again later",
-				})
-				c.Abort()
-				return
-			}
+		name, policy := policies.Select(c.Request.Method, c.FullPath())
+		result := limiter.Take(name+"|"+c.ClientIP(), policy)
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": "Too many requests, please try again later",
+			})
+			c.Abort()
+			return
 		}
 
 		c.Next()
 	}
 }
 
+// ceilSeconds 将时长向上取整为秒
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// Services 在 main 中创建、同时被定时任务使用的服务
+type Services struct {
+	Scraper    *scraper.Service
+	Scheduler  *scheduler.Scheduler
+	Classifier *classifier.Service
+	Trending   *trending.Service
+	Metrics    *metrics.Service
+	Related    *related.Service
+	Suggest    *suggest.Service
+	Notify     *notify.Service
+	Searches   *savedsearch.Service
+	Threads    *subscription.Service
+	Webhooks   *webhook.Service
+	Backups    *backup.Service
+	Retention  *retention.Service
+}
+
 // SetupRouter 设置路由
-func SetupRouter(db *gorm.DB, scraperService *scraper.Service) *gin.Engine {
+func SetupRouter(db *gorm.DB, cfg *config.Config, services Services) *gin.Engine {
 	r := gin.Default()
 
-	// 创建速率限制器
-	rateLimiter := NewRateLimiter()
+	// 只采用可信反向代理传来的 X-Forwarded-For，ClientIP 才是真实的客户端地址
+	trustedProxies := cfg.TrustedProxies
+	if len(trustedProxies) == 0 {
+		trustedProxies = defaultTrustedProxies
+	}
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Printf("Invalid TRUSTED_PROXIES, falling back to %v: %v", defaultTrustedProxies, err)
+		r.SetTrustedProxies(defaultTrustedProxies)
+	}
 
-	// 添加速率限制中间件
-	r.Use(RateLimitMiddleware(rateLimiter))
+	// 创建速率限制器，点赞、举报等接口的业务限制同样使用它
+	rateLimiter := ratelimit.New()
+	rateLimiter.StartEviction(rateLimitEvictInterval)
+
+	// 添加速率限制中间件，策略配置已在启动时校验
+	if cfg.RateLimitEnabled {
+		policies, _ := ratelimit.ParsePolicies(cfg.RateLimitRoutes, cfg.RateLimitRead, cfg.RateLimitWrite)
+		log.Printf("Rate limit: read %s, write %s, %d route rules", policies.Read, policies.Write, len(policies.Rules))
+		r.Use(RateLimitMiddleware(rateLimiter, policies))
+	}
 
 	// 添加安全响应头中间件
 	r.Use(func(c *gin.Context) {
@@ -147,8 +156,8 @@ func SetupRouter(db *gorm.DB, scraperService *scraper.Service) *gin.Engine {
 			}
 		}
 		
-		c.Header("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, X-Anonymous-Token, X-CSRF-Token")
 		c.Header("Access-Control-Max-Age", "86400") // 缓存预检请求结果24小时
 
 		if c.Request.Method == "OPTIONS" {
@@ -159,25 +168,90 @@ func SetupRouter(db *gorm.DB, scraperService *scraper.Service) *gin.Engine {
 		c.Next()
 	})
 
+	// 初始化本地媒体存储
+	mediaStore, err := media.NewStore(cfg.MediaDir, mediaURLPrefix, media.Limits{
+		MaxBytes:     cfg.MediaMaxBytes,
+		MaxDimension: cfg.MediaMaxDimension,
+		MaxPixels:    cfg.MediaMaxPixels,
+		MaxFrames:    cfg.MediaMaxFrames,
+	})
+	if err != nil {
+		log.Printf("Failed to initialize media store, image upload disabled: %v", err)
+	}
+
+	// 敏感字段可见策略，配置已在启动时校验
+	fieldPolicy, _ := ParseFieldPolicy(cfg.FieldVisibility)
+
 	// 创建处理器
 	handler := &Handler{
 		db:             db,
-		scraperService: scraperService,
-	}
+		scraperService: services.Scraper,
+		rateLimiter:    rateLimiter,
+		config:         cfg,
+		identity:       identity.NewManager(cfg.IdentitySecret),
+		csrf:           csrf.NewManager(cfg.CSRFSecret, cfg.CSRFTokenTTL),
+		media:          mediaStore,
+		moderation:     moderation.NewService(db),
+		scheduler:      services.Scheduler,
+		classifier:     services.Classifier,
+		trending:       services.Trending,
+		metrics:        services.Metrics,
+		related:        services.Related,
+		suggest:        services.Suggest,
+		notify:         services.Notify,
+		searches:       services.Searches,
+		threads:        services.Threads,
+		webhooks:       services.Webhooks,
+		stats:          stats.NewService(db, cfg.StatsCacheTTL),
+		importer:       importer.NewService(db),
+		backups:        services.Backups,
+		retention:      services.Retention,
+		fieldPolicy:    fieldPolicy,
+		filter: filter.New(filter.Config{
+			BlockWordsFile:  cfg.FilterBlockWordsFile,
+			ReviewWordsFile: cfg.FilterReviewWordsFile,
+			DuplicateWindow: cfg.FilterDuplicateWindow,
+			MaxRepeatRun:    cfg.FilterMaxRepeatRun,
+		}),
+	}
+
+	// 审核通过的待审核内容和直接发布的内容一样推送事件并同步到主站
+	handler.moderation.OnRelease(handler.publishReleased)
 
 	// API 路由组
-	api := r.Group("/api/v1")
+	// 写请求需要携带 CSRF 令牌
+	api := r.Group("/api/v1", handler.CSRFMiddleware())
 	{
+		// 匿名身份和 CSRF 令牌
+		api.POST("/identity", handler.IssueIdentity)
+		api.GET("/csrf-token", handler.IssueCSRFToken)
+
 		// 帖子相关路由
 		api.GET("/posts", handler.GetPosts)
+		api.GET("/posts/trending", handler.GetTrendingPosts)
 		api.GET("/posts/:id", handler.GetPost)
 		api.GET("/posts/:id/replies", handler.GetPostReplies)
+		api.GET("/posts/:id/metrics", handler.GetPostMetrics)
+		api.GET("/posts/:id/related", handler.GetRelatedPosts)
+		api.GET("/posts/:id/duplicates", handler.GetPostDuplicates)
 		api.POST("/posts", handler.CreatePost)
 		api.POST("/posts/:id/replies", handler.CreateReply)
 
+		// 点赞路由
+		api.POST("/posts/:id/like", handler.LikePost)
+		api.DELETE("/posts/:id/like", handler.UnlikePost)
+		api.POST("/replies/:id/like", handler.LikeReply)
+		api.DELETE("/replies/:id/like", handler.UnlikeReply)
+
+		// 举报路由
+		api.GET("/reports/categories", handler.GetReportCategories)
+		api.POST("/posts/:id/report", handler.ReportPost)
+		api.POST("/replies/:id/report", handler.ReportReply)
+
 		// 搜索路由
 		api.GET("/search", handler.SearchPosts)
 		api.GET("/search/advanced", handler.AdvancedSearch)
+		api.GET("/search/suggest", handler.SuggestSearch)
 		// api.GET("/search/users", handler.SearchUsers)
 		// api.GET("/search/comments", handler.SearchComments)
 
@@ -189,12 +263,87 @@ func SetupRouter(db *gorm.DB, scraperService *scraper.Service) *gin.Engine {
 		api.GET("/tags", handler.GetTags)
 		api.GET("/tags/:name/posts", handler.GetPostsByTag)
 
+		// 关键词订阅和通知
+		api.GET("/saved-searches", handler.ListSavedSearches)
+		api.POST("/saved-searches", handler.CreateSavedSearch)
+		api.PUT("/saved-searches/:id", handler.UpdateSavedSearch)
+		api.DELETE("/saved-searches/:id", handler.DeleteSavedSearch)
+		api.GET("/notifications", handler.GetNotifications)
+		api.GET("/notifications/stream", handler.StreamNotifications)
+		api.POST("/notifications/stream-ticket", handler.IssueStreamTicket)
+		api.POST("/notifications/read", handler.MarkNotificationsRead)
+		api.GET("/bookmarks/collections", handler.ListBookmarkCollections)
+		api.POST("/bookmarks/collections", handler.CreateBookmarkCollection)
+		api.PUT("/bookmarks/collections/:id", handler.RenameBookmarkCollection)
+		api.DELETE("/bookmarks/collections/:id", handler.DeleteBookmarkCollection)
+		api.GET("/bookmarks/collections/:id/posts", handler.GetBookmarks)
+		api.POST("/bookmarks/collections/:id/posts", handler.AddBookmark)
+		api.DELETE("/bookmarks/collections/:id/posts/:post_id", handler.RemoveBookmark)
+		api.GET("/bookmarks/export", handler.ExportBookmarks)
+		api.GET("/subscriptions", handler.ListThreadSubscriptions)
+		api.POST("/posts/:id/subscribe", handler.SubscribePost)
+		api.DELETE("/posts/:id/subscribe", handler.UnsubscribePost)
+
 		// 统计路由
 		api.GET("/stats", handler.GetStats)
+		api.GET("/stats/timeseries", handler.GetStatsTimeseries)
+		api.GET("/stats/heatmap", handler.GetStatsHeatmap)
+		api.GET("/stats/breakdown", handler.GetStatsBreakdown)
+		api.GET("/stats/top-tags", handler.GetStatsTopTags)
+		api.GET("/stats/top-threads", handler.GetStatsTopThreads)
+		api.GET("/stats/sync", handler.GetStatsSync)
 
 		// 同步相关路由
-		// api.POST("/sync", handler.TriggerSync)
 		api.GET("/sync/status", handler.GetSyncStatus)
+
+		// 管理后台路由
+		admin := api.Group("/admin")
+		admin.POST("/login", handler.AdminLogin)
+
+		authed := admin.Group("", handler.AdminAuthMiddleware())
+		{
+			authed.POST("/logout", handler.AdminLogout)
+			authed.GET("/keys", handler.ListAdminKeys)
+			authed.POST("/keys", handler.CreateAdminKey)
+			authed.DELETE("/keys/:id", handler.RevokeAdminKey)
+
+			authed.GET("/moderation/queue", handler.GetModerationQueue)
+			authed.POST("/moderation/queue/:id/dismiss", handler.DismissModerationItem)
+			for _, action := range []string{moderation.ActionHide, moderation.ActionRestore, moderation.ActionDelete} {
+				authed.POST("/posts/:id/"+action, handler.Moderate(moderation.TargetPost, action))
+				authed.POST("/replies/:id/"+action, handler.Moderate(moderation.TargetReply, action))
+			}
+			authed.GET("/audit-logs", handler.GetAuditLogs)
+			authed.POST("/filter/reload", handler.ReloadFilter)
+			authed.POST("/filter/check", handler.CheckFilter)
+			authed.POST("/posts/:id/tags", handler.SetTags(moderation.TargetPost))
+			authed.POST("/replies/:id/tags", handler.SetTags(moderation.TargetReply))
+			authed.POST("/tags", handler.CreateTag)
+			authed.PUT("/tags/:id", handler.UpdateTag)
+			authed.DELETE("/tags/:id", handler.DeleteTag)
+			authed.POST("/classifier/run", handler.RunClassifier)
+			authed.POST("/trending/refresh", handler.RefreshTrending)
+			authed.POST("/metrics/compact", handler.CompactMetrics)
+			authed.POST("/related/rebuild", handler.RebuildRelated)
+			authed.POST("/search/suggest/rebuild", handler.RebuildSuggestions)
+			authed.GET("/webhooks", handler.ListWebhooks)
+			authed.POST("/webhooks", handler.CreateWebhook)
+			authed.PUT("/webhooks/:id", handler.UpdateWebhook)
+			authed.DELETE("/webhooks/:id", handler.DeleteWebhook)
+			authed.POST("/webhooks/:id/ping", handler.PingWebhook)
+			authed.GET("/webhooks/:id/deliveries", handler.GetWebhookDeliveries)
+			authed.POST("/webhook-deliveries/:id/redeliver", handler.RedeliverWebhook)
+			authed.POST("/classifier/reload", handler.ReloadClassifier)
+			authed.POST("/classifier/check", handler.CheckClassifier)
+			authed.GET("/export", handler.ExportData)
+			authed.POST("/import", handler.ImportData)
+			authed.GET("/backups", handler.ListBackups)
+			authed.POST("/backups", handler.CreateBackup)
+			authed.GET("/retention", handler.GetRetentionPolicy)
+			authed.POST("/retention/run", handler.RunRetention)
+
+			authed.POST("/sync", handler.TriggerSync)
+		}
 	}
 
 	// 健康检查
@@ -203,6 +352,7 @@ func SetupRouter(db *gorm.DB, scraperService *scraper.Service) *gin.Engine {
 	})
 
 	// 静态文件托管
+	r.Static(mediaURLPrefix, cfg.MediaDir)
 	r.Static("/assets", "./dist/assets")
 	r.StaticFile("/", "./dist/index.html")
 	r.StaticFile("/index.html", "./dist/index.html")
@@ -225,10 +375,36 @@ func SetupRouter(db *gorm.DB, scraperService *scraper.Service) *gin.Engine {
 type Handler struct {
 	db             *gorm.DB
 	scraperService *scraper.Service
+	rateLimiter    *ratelimit.Limiter
+	config         *config.Config
+	identity       *identity.Manager
+	csrf           *csrf.Manager
+	media          *media.Store
+	moderation     *moderation.Service
+	scheduler      *scheduler.Scheduler
+	filter         *filter.Pipeline
+	classifier     *classifier.Service
+	trending       *trending.Service
+	metrics        *metrics.Service
+	related        *related.Service
+	suggest        *suggest.Service
+	notify         *notify.Service
+	searches       *savedsearch.Service
+	threads        *subscription.Service
+	webhooks       *webhook.Service
+	stats          *stats.Service
+	importer       *importer.Service
+	backups        *backup.Service
+	retention      *retention.Service
+	fieldPolicy    FieldPolicy
 }
 
 // GetPosts 获取帖子列表
 func (h *Handler) GetPosts(c *gin.Context) {
+	view, ok := h.newViewer(c)
+	if !ok {
+		return
+	}
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	
@@ -246,10 +422,10 @@ func (h *Handler) GetPosts(c *gin.Context) {
 	var total int64
 
 	// 获取总数
-	h.db.Model(&models.Post{}).Count(&total)
+	h.db.Model(&models.Post{}).Scopes(collapseDuplicates(c)).Count(&total)
 
 	// 获取帖子列表
-	if err := h.db.Order("created_at desc").
+	if err := h.db.Scopes(collapseDuplicates(c)).Order("created_at desc").
 		Limit(limit).
 		Offset(offset).
 		Find(&posts).Error; err != nil {
@@ -258,7 +434,7 @@ func (h *Handler) GetPosts(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"posts": posts,
+		"posts": view.posts(posts),
 		"pagination": gin.H{
 			"page":  page,
 			"limit": limit,
@@ -271,6 +447,10 @@ func (h *Handler) GetPosts(c *gin.Context) {
 // GetPost 获取单个帖子
 func (h *Handler) GetPost(c *gin.Context) {
 	id := c.Param("id")
+	view, ok := h.newViewer(c)
+	if !ok {
+		return
+	}
 
 	var post models.Post
 	if err := h.db.Where("id = ? OR original_id = ?", id, id).
@@ -286,12 +466,16 @@ func (h *Handler) GetPost(c *gin.Context) {
 	// 增加浏览次数
 	h.db.Model(&post).Update("view_count", gorm.Expr("view_count + ?", 1))
 
-	c.JSON(http.StatusOK, post)
+	c.JSON(http.StatusOK, view.post(&post))
 }
 
 // GetPostReplies 获取帖子回复
 func (h *Handler) GetPostReplies(c *gin.Context) {
 	id := c.Param("id")
+	view, ok := h.newViewer(c)
+	if !ok {
+		return
+	}
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	
@@ -319,9 +503,10 @@ func (h *Handler) GetPostReplies(c *gin.Context) {
 	var replies []models.Reply
 	var total int64
 
-	h.db.Unscoped().Model(&models.Reply{}).Where("post_id = ?", post.ID).Count(&total)
+	// 被管理员隐藏的回复不再返回
+	h.db.Model(&models.Reply{}).Where("post_id = ?", post.ID).Count(&total)
 
-	if err := h.db.Unscoped().Where("post_id = ?", post.ID).
+	if err := h.db.Where("post_id = ?", post.ID).
 		Order("created_at asc").
 		Limit(limit).
 		Offset(offset).
@@ -331,7 +516,7 @@ func (h *Handler) GetPostReplies(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"replies": replies,
+		"replies": view.replies(replies),
 		"pagination": gin.H{
 			"page":  page,
 			"limit": limit,
@@ -342,23 +527,29 @@ func (h *Handler) GetPostReplies(c *gin.Context) {
 }
 
 // CreatePostRequest 创建帖子的请求结构
+// 作者名由服务端根据匿名令牌生成，不再接受客户端传入
+// 支持 JSON 或 multipart 表单，表单中可通过 images 字段上传图片
 type CreatePostRequest struct {
-	Title    string `json:"title" binding:"required"`
-	Content  string `json:"content" binding:"required"`
-	UserName string `json:"username" binding:"required"`
+	Title   string `json:"title" form:"title" binding:"required"`
+	Content string `json:"content" form:"content" binding:"required"`
 }
 
 // CreateReplyRequest 创建回复的请求结构
 type CreateReplyRequest struct {
-	Content  string `json:"content" binding:"required"`
-	UserName string `json:"username" binding:"required"`
-	ParentID int    `json:"parent_id"` // 父评论ID，如果是0则回复帖子本身
+	Content  string `json:"content" form:"content" binding:"required"`
+	ParentID int    `json:"parent_id" form:"parent_id"` // 父评论ID，如果是0则回复帖子本身
 }
 
 // CreatePost 创建帖子
 func (h *Handler) CreatePost(c *gin.Context) {
+	view, ok := h.newViewer(c)
+	if !ok {
+		return
+	}
+	h.limitRequestBody(c)
+
 	var req CreatePostRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindCreateRequest(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -376,41 +567,90 @@ func (h *Handler) CreatePost(c *gin.Context) {
 		return
 	}
 
-	username, err := validateAndSanitizeInput(req.UserName, 50)
+	// 内容过滤，命中审核规则的帖子会先隐藏等待管理员处理
+	filterText, clientHash := req.Title+"\n"+req.Content, h.clientIdentity(c)
+	verdict := h.filter.Check(filterText, clientHash)
+	if verdict.Verdict == filter.VerdictReject {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Content rejected by filter", "reasons": verdict.Reasons})
+		return
+	}
+	held := verdict.Verdict == filter.VerdictReview
+
+	images, err := h.readUploadedImages(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// 记录没有保存成功时删除已写入的图片文件
+	saved := false
+	defer func() {
+		if !saved {
+			h.discardImages(images)
+		}
+	}()
+
+	token, issued, err := h.ensureToken(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue identity token"})
+		return
+	}
 
 	// 创建本地帖子记录
 	post := models.Post{
 		Title:       title,
 		Content:     content,
-		Author:      username,
+		Author:      identity.PosterName,
 		OriginalID: "0", // 默认原始ID
-		AuthorID:    "automatic"+time.Now().Format("20060102150405"), // 默认openid
 		RadioGroup:  "radio40",   // 默认分组
 		CampusGroup: "2",         // 默认校区
 		Region:      "0",         // 默认地区
 		LikeNum:     0,
 		ReplyCount:  0,
 		ViewCount:   0,
-		Tag:         "未分析",
+		Tag:         classifier.Untagged,
 		State:       "normal",
-		Images:      "[]",
-		Cover:       "[]",
+		Images:      mediaURLs(images),
+		Cover:       mediaCover(images),
 		CreatedAt:   time.Now(),
 	}
 
-	// 保存到本地数据库
-	if err := h.db.Create(&post).Error; err != nil {
+	// 保存到本地数据库，作者ID依赖帖子ID，需要在同一事务中回填
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&post).Error; err != nil {
+			return err
+		}
+		post.AuthorID = h.identity.ThreadAuthorID(token, post.ID)
+		if err := tx.Model(&post).Update("author_id", post.AuthorID).Error; err != nil {
+			return err
+		}
+		if err := attachMedia(tx, images, "post", post.ID); err != nil {
+			return err
+		}
+		// 待审核的帖子不参与重复检测，审核通过后再归组
+		if held {
+			return h.moderation.Hold(tx, moderation.TargetPost, post.ID, verdict.Reasons)
+		}
+		return dedup.Assign(tx, &post)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create post locally"})
 		return
 	}
+	saved = true
+	h.filter.Record(filterText, clientHash)
+
+	// 洞主自动订阅自己的帖子
+	if _, err := subscription.Subscribe(h.db, h.identity.Subject(token), post.AuthorID, post.ID); err != nil {
+		log.Printf("Failed to subscribe author to post %d: %v", post.ID, err)
+	}
+	if !held {
+		go h.webhooks.Emit(webhook.Event{Type: webhook.EventPostCreated, Post: &post})
+		go h.searches.MatchJob()
+	}
 
-	// 同步到主站
+	// 同步到主站，待审核的帖子不同步
 	go func() {
-		if os.Getenv("OUTBOUND_SYNC_ENABLED") != "true" {
+		if os.Getenv("OUTBOUND_SYNC_ENABLED") != "true" || held {
 			return
 		}
 		if err := h.scraperService.SyncPostToMainSite(post); err != nil {
@@ -418,18 +658,34 @@ func (h *Handler) CreatePost(c *gin.Context) {
 		}
 	}()
 
-	c.JSON(http.StatusCreated, gin.H{
+	status := http.StatusCreated
+	response := gin.H{
 		"message": "Post created successfully",
-		"post":    post,
-	})
+		"post":    view.post(&post),
+	}
+	if held {
+		status = http.StatusAccepted
+		response["message"] = "Post is pending review"
+		response["pending_review"] = true
+		response["reasons"] = verdict.Reasons
+	}
+	if issued {
+		response["identity_token"] = token
+	}
+	c.JSON(status, response)
 }
 
 // CreateReply 创建回复
 func (h *Handler) CreateReply(c *gin.Context) {
 	postID := c.Param("id")
+	view, ok := h.newViewer(c)
+	if !ok {
+		return
+	}
+	h.limitRequestBody(c)
 	
 	var req CreateReplyRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindCreateRequest(c, &req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -441,11 +697,33 @@ func (h *Handler) CreateReply(c *gin.Context) {
 		return
 	}
 
-	username, err := validateAndSanitizeInput(req.UserName, 50)
+	// 内容过滤，命中审核规则的回复会先隐藏等待管理员处理
+	clientHash := h.clientIdentity(c)
+	verdict := h.filter.Check(req.Content, clientHash)
+	if verdict.Verdict == filter.VerdictReject {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Content rejected by filter", "reasons": verdict.Reasons})
+		return
+	}
+	held := verdict.Verdict == filter.VerdictReview
+
+	images, err := h.readUploadedImages(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// 记录没有保存成功时删除已写入的图片文件
+	saved := false
+	defer func() {
+		if !saved {
+			h.discardImages(images)
+		}
+	}()
+
+	token, issued, err := h.ensureToken(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue identity token"})
+		return
+	}
 
 	// 先找到帖子
 	var post models.Post
@@ -458,18 +736,37 @@ func (h *Handler) CreateReply(c *gin.Context) {
 		return
 	}
 
+	// 根据令牌生成帖子内的匿名身份
+	authorID := h.identity.ThreadAuthorID(token, post.ID)
+	author, err := h.threadPseudonym(&post, authorID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 回复对象默认是洞主，回复评论时指向父评论作者
+	applyTo := post.AuthorID
+	if req.ParentID > 0 {
+		var parent models.Reply
+		if err := h.db.Unscoped().Where("id = ? AND post_id = ?", req.ParentID, post.ID).First(&parent).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent reply not found"})
+			return
+		}
+		applyTo = parent.AuthorID
+	}
+
 	// 创建回复记录
 	reply := models.Reply{
 		PostID:    post.ID,
 		Content:   content,
-		Author:    username,
-		AuthorID:  "automatic"+time.Now().Format("20060102150405"), // 默认openid
-		ApplyTo:   "automatic",
+		Author:    author,
+		AuthorID:  authorID,
+		ApplyTo:   applyTo,
 		Level:     1,           // 默认层级
 		ParentID:  req.ParentID,
 		LikeNum:   0,
-		Tag:       "未分析",
-		Images:    "[]",
+		Tag:       classifier.Untagged,
+		Images:    mediaURLs(images),
 		CreatedAt: time.Now(),
 	}
 
@@ -479,17 +776,43 @@ func (h *Handler) CreateReply(c *gin.Context) {
 	}
 
 	// 保存到本地数据库
-	if err := h.db.Create(&reply).Error; err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&reply).Error; err != nil {
+			return err
+		}
+		if err := attachMedia(tx, images, "reply", reply.ID); err != nil {
+			return err
+		}
+		if held {
+			return h.moderation.Hold(tx, moderation.TargetReply, reply.ID, verdict.Reasons)
+		}
+		return nil
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create reply locally"})
 		return
 	}
+	saved = true
+	h.filter.Record(req.Content, clientHash)
 
-	// 更新帖子的评论数
-	h.db.Model(&post).Update("reply_count", gorm.Expr("reply_count + ?", 1))
+	// 更新帖子的评论数，待审核的回复在审核通过后再计数
+	if !held {
+		h.db.Model(&post).Update("reply_count", gorm.Expr("reply_count + ?", 1))
+	}
 
-	// 同步到主站
+	// 回复者自动订阅帖子，并通知帖子的其他订阅者
+	if _, err := subscription.Subscribe(h.db, h.identity.Subject(token), authorID, post.ID); err != nil {
+		log.Printf("Failed to subscribe replier to post %d: %v", post.ID, err)
+	}
+	go h.threads.ProcessJob()
+	if !held {
+		go h.webhooks.Emit(webhook.Event{Type: webhook.EventReplyCreated, Post: &post, Reply: &reply})
+		go h.searches.MatchJob()
+	}
+
+	// 同步到主站，待审核的回复不同步
 	go func() {
-		if os.Getenv("OUTBOUND_SYNC_ENABLED") != "true" {
+		if os.Getenv("OUTBOUND_SYNC_ENABLED") != "true" || held {
 			return
 		}
 		if err := h.scraperService.SyncReplyToMainSite(post, reply); err != nil {
@@ -497,10 +820,21 @@ func (h *Handler) CreateReply(c *gin.Context) {
 		}
 	}()
 
-	c.JSON(http.StatusCreated, gin.H{
+	status := http.StatusCreated
+	response := gin.H{
 		"message": "Reply created successfully",
-		"reply":   reply,
-	})
+		"reply":   view.reply(&reply),
+	}
+	if held {
+		status = http.StatusAccepted
+		response["message"] = "Reply is pending review"
+		response["pending_review"] = true
+		response["reasons"] = verdict.Reasons
+	}
+	if issued {
+		response["identity_token"] = token
+	}
+	c.JSON(status, response)
 }
 
 // SearchPosts 搜索帖子
@@ -510,6 +844,10 @@ func (h *Handler) SearchPosts(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
 		return
 	}
+	view, ok := h.newViewer(c)
+	if !ok {
+		return
+	}
 
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
@@ -550,9 +888,11 @@ func (h *Handler) SearchPosts(c *gin.Context) {
 
 	h.db.Model(&models.Post{}).
 		Where(whereClause, args...).
+		Scopes(collapseDuplicates(c)).
 		Count(&total)
 
 	if err := h.db.Where(whereClause, args...).
+		Scopes(collapseDuplicates(c)).
 		Order("created_at desc").
 		Limit(limit).
 		Offset(offset).
@@ -561,8 +901,13 @@ func (h *Handler) SearchPosts(c *gin.Context) {
 		return
 	}
 
+	// 只记录有结果的搜索，作为搜索建议的来源
+	if total > 0 && page == 1 {
+		h.suggest.Record(query, h.clientIdentity(c))
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"posts": posts,
+		"posts": view.posts(posts),
 		"pagination": gin.H{
 			"page":  page,
 			"limit": limit,
@@ -574,73 +919,106 @@ func (h *Handler) SearchPosts(c *gin.Context) {
 	})
 }
 
-// GetTags 获取标签列表 (从帖子中提取唯一标签)
+// GetTags 获取标签列表
+// tags 为按帖子数从多到少排列的标签名，items 为带计数的标签详情，tree 为按父子关系组织的层级
 func (h *Handler) GetTags(c *gin.Context) {
-	var tags []string
-	
-	// 从帖子中获取所有唯一标签
-	rows, err := h.db.Model(&models.Post{}).Select("DISTINCT tag").Where("tag != ''").Rows()
+	ix, err := classifier.LoadTagIndex(h.db)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	defer rows.Close()
-	
-	for rows.Next() {
-		var tag string
-		if err := rows.Scan(&tag); err == nil && tag != "" {
-			tags = append(tags, tag)
-		}
+	counts, err := h.tagPostCounts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	items := make([]tagItem, 0, len(ix.Tags()))
+	for _, tag := range ix.Tags() {
+		items = append(items, newTagItem(tag, counts[tag.ID]))
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].PostCount > items[j].PostCount
+	})
+
+	tags := make([]string, 0, len(items))
+	for _, item := range items {
+		tags = append(tags, item.Name)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"tags": tags})
+	c.JSON(http.StatusOK, gin.H{
+		"tags":  tags,
+		"items": items,
+		"tree":  buildTagTree(ix, counts),
+	})
 }
 
 // GetPostsByTag 根据标签获取帖子
+// 多个标签以逗号分隔时返回同时带有所有标签的帖子，父标签包含其子标签下的帖子
 func (h *Handler) GetPostsByTag(c *gin.Context) {
 	tagName := c.Param("name")
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
-	
-	// 限制最大limit值防止恶意攻击
-	if limit > 50 {
-		limit = 50
+	page, limit := parsePagination(c)
+	view, ok := h.newViewer(c)
+	if !ok {
+		return
 	}
-	if limit <= 0 {
-		limit = 20
+
+	ix, err := classifier.LoadTagIndex(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := h.db.Model(&models.Post{})
+	var names []string
+	for _, name := range strings.Split(tagName, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		tag, ok := ix.Lookup(name)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found: " + name})
+			return
+		}
+		names = append(names, tag.Name)
+		query = query.Where("id IN (?)", h.taggedPostIDs(ix, tag))
+	}
+	if len(names) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tag name is required"})
+		return
 	}
-	
-	offset := (page - 1) * limit
 
 	var posts []models.Post
 	var total int64
 
-	// 直接通过tag字段查询
-	h.db.Model(&models.Post{}).Where("tag = ?", tagName).Count(&total)
+	query = query.Scopes(collapseDuplicates(c))
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
-	if err := h.db.Where("tag = ?", tagName).
-		Order("created_at desc").
+	if err := query.Order("created_at desc").
 		Limit(limit).
-		Offset(offset).
+		Offset((page - 1) * limit).
 		Find(&posts).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"posts": posts,
-		"pagination": gin.H{
-			"page":  page,
-			"limit": limit,
-			"total": total,
-			"pages": (total + int64(limit) - 1) / int64(limit),
-		},
-		"tag": tagName,
+		"posts":      view.posts(posts),
+		"pagination": paginationMeta(page, limit, total),
+		"tag":        tagName,
+		"tags":       names,
 	})
 }
 
 // GetStats 获取统计信息
 func (h *Handler) GetStats(c *gin.Context) {
+	view, ok := h.newViewer(c)
+	if !ok {
+		return
+	}
 	var totalPosts int64
 	var totalReplies int64
 	var totalTags int64
@@ -648,8 +1026,8 @@ func (h *Handler) GetStats(c *gin.Context) {
 	h.db.Model(&models.Post{}).Count(&totalPosts)
 	h.db.Model(&models.Reply{}).Count(&totalReplies)
 	
-	// 统计唯一标签数量
-	h.db.Model(&models.Post{}).Select("COUNT(DISTINCT tag)").Where("tag != ''").Scan(&totalTags)
+	// 统计标签数量
+	h.db.Model(&models.Tag{}).Count(&totalTags)
 
 	// 获取最新帖子
 	var latestPost models.Post
@@ -659,22 +1037,10 @@ func (h *Handler) GetStats(c *gin.Context) {
 		"total_posts":   totalPosts,
 		"total_replies": totalReplies,
 		"total_tags":    totalTags,
-		"latest_post":   latestPost,
+		"latest_post":   view.post(&latestPost),
 	})
 }
 
-// TriggerSync 触发同步
-func (h *Handler) TriggerSync(c *gin.Context) {
-	// 异步执行同步
-	go func() {
-		if err := h.scraperService.ScrapeData(); err != nil {
-			// 这里可以添加日志记录
-		}
-	}()
-
-	c.JSON(http.StatusOK, gin.H{"message": "Sync started"})
-}
-
 // GetSyncStatus 获取同步状态
 func (h *Handler) GetSyncStatus(c *gin.Context) {
 	status, err := h.scraperService.GetLastSyncStatus()
@@ -704,6 +1070,10 @@ func (h *Handler) AdvancedSearch(c *gin.Context) {
 	state := c.Query("state")       // 状态
 	radioGroup := c.Query("radio_group") // 分组
 	logic := c.DefaultQuery("logic", "and") // 逻辑关系：and 或 or
+	view, ok := h.newViewer(c)
+	if !ok {
+		return
+	}
 	
 	// 分页参数
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
@@ -777,10 +1147,19 @@ func (h *Handler) AdvancedSearch(c *gin.Context) {
 		args = append(args, originalID)
 	}
 
-	// 标签搜索
+	// 标签搜索，支持别名，父标签包含其子标签下的帖子
 	if tag != "" {
-		conditions = append(conditions, "tag = ?")
-		args = append(args, tag)
+		if ix, err := classifier.LoadTagIndex(h.db); err == nil {
+			if found, ok := ix.Lookup(tag); ok {
+				conditions = append(conditions, "id IN (?)")
+				args = append(args, h.taggedPostIDs(ix, found))
+			} else {
+				conditions = append(conditions, "1 = 0")
+			}
+		} else {
+			conditions = append(conditions, "tag = ?")
+			args = append(args, tag)
+		}
 	}
 
 	// 状态搜索
@@ -802,7 +1181,7 @@ func (h *Handler) AdvancedSearch(c *gin.Context) {
 			// 为每个关键词构建子查询
 			var commentConditions []string
 			for _, keyword := range keywords {
-				subQuery := h.db.Unscoped().Model(&models.Reply{}).
+				subQuery := h.db.Model(&models.Reply{}).
 					Select("DISTINCT post_id").
 					Where("content LIKE ?", "%"+keyword+"%")
 				commentConditions = append(commentConditions, "id IN (?)")
@@ -835,6 +1214,7 @@ func (h *Handler) AdvancedSearch(c *gin.Context) {
 		
 		db = db.Where(whereClause, args...)
 	}
+	db = db.Scopes(collapseDuplicates(c))
 
 	// 获取总数
 	db.Count(&total)
@@ -849,7 +1229,7 @@ func (h *Handler) AdvancedSearch(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"posts": posts,
+		"posts": view.posts(posts),
 		"pagination": gin.H{
 			"page":  page,
 			"limit": limit,
@@ -892,10 +1272,14 @@ func (h *Handler) SearchUsers(c *gin.Context) {
 	}
 	
 	offset := (page - 1) * limit
+	view, ok := h.newViewer(c)
+	if !ok {
+		return
+	}
 
 	type UserInfo struct {
 		Author   string `json:"author"`
-		AuthorID string `json:"author_id"`
+		AuthorID string `json:"author_id,omitempty"`
 		PostCount int64 `json:"post_count"`
 		ReplyCount int64 `json:"reply_count"`
 	}
@@ -928,6 +1312,7 @@ func (h *Handler) SearchUsers(c *gin.Context) {
 	for rows.Next() {
 		var user UserInfo
 		if err := rows.Scan(&user.Author, &user.AuthorID, &user.PostCount, &user.ReplyCount); err == nil {
+			user.AuthorID = view.value("author_id", user.AuthorID)
 			users = append(users, user)
 		}
 	}
@@ -954,6 +1339,10 @@ func (h *Handler) SearchUsers(c *gin.Context) {
 // GetUserPosts 获取指定用户的帖子
 func (h *Handler) GetUserPosts(c *gin.Context) {
 	userID := c.Param("user_id") // 可以是 author_id 或 author
+	view, ok := h.newViewer(c)
+	if !ok {
+		return
+	}
 	
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
@@ -987,16 +1376,16 @@ func (h *Handler) GetUserPosts(c *gin.Context) {
 	// 获取用户信息
 	var userInfo struct {
 		Author   string `json:"author"`
-		AuthorID string `json:"author_id"`
+		AuthorID string `json:"author_id,omitempty"`
 	}
 	
 	if len(posts) > 0 {
 		userInfo.Author = posts[0].Author
-		userInfo.AuthorID = posts[0].AuthorID
+		userInfo.AuthorID = view.value("author_id", posts[0].AuthorID)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"posts": posts,
+		"posts": view.posts(posts),
 		"user_info": userInfo,
 		"pagination": gin.H{
 			"page":  page,
@@ -1010,6 +1399,10 @@ func (h *Handler) GetUserPosts(c *gin.Context) {
 // GetUserReplies 获取指定用户的回复
 func (h *Handler) GetUserReplies(c *gin.Context) {
 	userID := c.Param("user_id") // 可以是 author_id 或 author
+	view, ok := h.newViewer(c)
+	if !ok {
+		return
+	}
 	
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
@@ -1028,7 +1421,7 @@ func (h *Handler) GetUserReplies(c *gin.Context) {
 	var total int64
 
 	// 同时按 author_id 和 author 搜索
-	db := h.db.Unscoped().Model(&models.Reply{}).Where("author_id = ? OR author = ?", userID, userID)
+	db := h.db.Model(&models.Reply{}).Where("author_id = ? OR author = ?", userID, userID)
 	
 	db.Count(&total)
 
@@ -1043,16 +1436,16 @@ func (h *Handler) GetUserReplies(c *gin.Context) {
 	// 获取用户信息
 	var userInfo struct {
 		Author   string `json:"author"`
-		AuthorID string `json:"author_id"`
+		AuthorID string `json:"author_id,omitempty"`
 	}
 	
 	if len(replies) > 0 {
 		userInfo.Author = replies[0].Author
-		userInfo.AuthorID = replies[0].AuthorID
+		userInfo.AuthorID = view.value("author_id", replies[0].AuthorID)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"replies": replies,
+		"replies": view.replies(replies),
 		"user_info": userInfo,
 		"pagination": gin.H{
 			"page":  page,
@@ -1063,6 +1456,13 @@ func (h *Handler) GetUserReplies(c *gin.Context) {
 	})
 }
 
+// replyWithPost 评论搜索结果，附带所属帖子的信息
+type replyWithPost struct {
+	PublicReply
+	PostTitle      string `json:"post_title"`
+	PostOriginalID string `json:"post_original_id"`
+}
+
 // SearchComments 搜索评论
 func (h *Handler) SearchComments(c *gin.Context) {
 	query := c.Query("q")
@@ -1074,6 +1474,10 @@ func (h *Handler) SearchComments(c *gin.Context) {
 	author := c.Query("author")     // 作者用户名
 	authorID := c.Query("author_id") // 作者ID (openid)
 	postID := c.Query("post_id")    // 限制在某个帖子内搜索
+	view, ok := h.newViewer(c)
+	if !ok {
+		return
+	}
 	
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
@@ -1092,7 +1496,7 @@ func (h *Handler) SearchComments(c *gin.Context) {
 	var total int64
 
 	// 构建查询条件
-	db := h.db.Unscoped().Model(&models.Reply{})
+	db := h.db.Model(&models.Reply{})
 
 	// 内容搜索
 	keywords := splitKeywords(query)
@@ -1139,27 +1543,21 @@ func (h *Handler) SearchComments(c *gin.Context) {
 	}
 
 	// 为每个回复添加对应的帖子信息
-	type ReplyWithPost struct {
-		models.Reply
-		PostTitle    string `json:"post_title"`
-		PostOriginalID string `json:"post_original_id"`
-	}
-
-	var repliesWithPost []ReplyWithPost
-	for _, reply := range replies {
+	var repliesWithPost []interface{}
+	for i, reply := range replies {
 		var post models.Post
 		if err := h.db.Where("id = ?", reply.PostID).First(&post).Error; err == nil {
-			repliesWithPost = append(repliesWithPost, ReplyWithPost{
-				Reply:          reply,
+			repliesWithPost = append(repliesWithPost, view.project(replyWithPost{
+				PublicReply:    view.publicReply(&replies[i]),
 				PostTitle:      post.Title,
 				PostOriginalID: post.OriginalID,
-			})
+			}))
 		} else {
-			repliesWithPost = append(repliesWithPost, ReplyWithPost{
-				Reply:          reply,
+			repliesWithPost = append(repliesWithPost, view.project(replyWithPost{
+				PublicReply:    view.publicReply(&replies[i]),
 				PostTitle:      "未知帖子",
 				PostOriginalID: "",
-			})
+			}))
 		}
 	}
 
@@ -1192,6 +1590,32 @@ func splitKeywords(query string) []string {
 	return result
 }
 
+// parsePagination 解析分页参数，limit 最大为50
+func parsePagination(c *gin.Context) (page, limit int) {
+	page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ = strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit > 50 {
+		limit = 50
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	return page, limit
+}
+
+// paginationMeta 构建分页信息
+func paginationMeta(page, limit int, total int64) gin.H {
+	return gin.H{
+		"page":  page,
+		"limit": limit,
+		"total": total,
+		"pages": (total + int64(limit) - 1) / int64(limit),
+	}
+}
+
 // buildMultiKeywordCondition 构建多关键词搜索条件
 func buildMultiKeywordCondition(field string, keywords []string) (string, []interface{}) {
 	if len(keywords) == 0 {
@@ -1211,45 +1635,22 @@ func buildMultiKeywordCondition(field string, keywords []string) (string, []inte
 	return condition, args
 }
 
-// CSRFMiddleware CSRF保护中间件
-func CSRFMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// 对于GET请求和OPTIONS请求，不需要CSRF保护
-		if c.Request.Method == "GET" || c.Request.Method == "OPTIONS" {
-			c.Next()
-			return
-		}
-
-		// 获取CSRF令牌
-		token := c.GetHeader("X-CSRF-Token")
-		if token == "" {
-			token = c.PostForm("_token")
-		}
-
-		// 验证CSRF令牌
-		sessionToken := c.GetHeader("X-Session-Token")
-		if token == "" || sessionToken == "" || !validateCSRFToken(token, sessionToken) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "CSRF token validation failed"})
-			c.Abort()
-			return
-		}
-
-		c.Next()
-	}
-}
-
-// generateCSRFToken 生成CSRF令牌
-func generateCSRFToken() string {
-	bytes := make([]byte, 32)
-	rand.Read(bytes)
-	return hex.EncodeToString(bytes)
-}
-
-// validateCSRFToken 验证CSRF令牌
-func validateCSRFToken(token, sessionToken string) bool {
-	// 这里应该实现实际的令牌验证逻辑
-	// 简单示例：检查令牌是否不为空且长度合适
-	return len(token) == 64 && len(sessionToken) > 0
+// dangerousPatterns 危险字符和脚本标签，启动时编译一次
+var dangerousPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`<script[^>]*>.*?</script>`),
+	regexp.MustCompile(`javascript:`),
+	regexp.MustCompile(`vbscript:`),
+	regexp.MustCompile(`onload=`),
+	regexp.MustCompile(`onclick=`),
+	regexp.MustCompile(`onerror=`),
+	regexp.MustCompile(`<iframe[^>]*>.*?</iframe>`),
+	regexp.MustCompile(`<img[^>]*src\s*=\s*["']?javascript:.*?["']?[^>]*>`),
+	regexp.MustCompile(`<a[^>]*href\s*=\s*["']?javascript:.*?["']?[^>]*>`),
+	regexp.MustCompile(`<style[^>]*>.*?</style>`),
+	regexp.MustCompile(`<link[^>]*>`),
+	regexp.MustCompile(`<body[^>]*>`),
+	regexp.MustCompile(`<html[^>]*>`),
+	regexp.MustCompile(`<meta[^>]*>`),
 }
 
 // validateAndSanitizeInput 验证和清理输入
@@ -1270,28 +1671,9 @@ func validateAndSanitizeInput(input string, maxLength int) (string, error) {
 	// HTML转义防止XSS
 	input = html.EscapeString(input)
 
-	// 过滤危险字符和脚本标签
-	dangerousPatterns := []string{
-		`<script[^>]*>.*?</script>`,
-		`javascript:`,
-		`vbscript:`,
-		`onload=`,
-		`onclick=`,
-		`onerror=`,
-		`<iframe[^>]*>.*?</iframe>`,
-		`<img[^>]*src\s*=\s*["']?javascript:.*?["']?[^>]*>`,
-		`<a[^>]*href\s*=\s*["']?javascript:.*?["']?[^>]*>`,
-		`<style[^>]*>.*?</style>`,
-		`<link[^>]*>`,
-		`<body[^>]*>`,
-		`<html[^>]*>`,
-		`<meta[^>]*>`,
-	}
-
 	// 检查是否包含危险内容
 	for _, pattern := range dangerousPatterns {
-		matched, _ := regexp.MatchString(pattern, input)
-		if matched {
+		if pattern.MatchString(input) {
 			return "", fmt.Errorf("input contains dangerous content")
 		}
 	}
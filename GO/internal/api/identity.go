@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http"
+	"time"
+	"treehole/internal/identity"
+	"treehole/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// anonymousTokenHeader 客户端携带匿名身份令牌的请求头
+const anonymousTokenHeader = "X-Anonymous-Token"
+
+// 每个来源 IP 每小时最多签发的匿名身份令牌数
+const identityRateLimit = 20
+
+// IssueIdentity 签发匿名身份令牌，由客户端自行保存
+func (h *Handler) IssueIdentity(c *gin.Context) {
+	if !h.rateLimiter.Allow("identity|"+c.ClientIP(), identityRateLimit, time.Hour) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many identity requests, please try again later"})
+		return
+	}
+	token, err := h.identity.Issue()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue identity token"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"token": token})
+}
+
+// requestToken 获取请求中经过校验的匿名令牌，无效时返回空字符串
+func (h *Handler) requestToken(c *gin.Context) string {
+	token := c.GetHeader(anonymousTokenHeader)
+	if token == "" || !h.identity.Verify(token) {
+		return ""
+	}
+	return token
+}
+
+// ensureToken 获取请求中的匿名令牌，没有时签发新令牌
+// issued 为 true 时调用方需要在响应中返回新令牌
+func (h *Handler) ensureToken(c *gin.Context) (token string, issued bool, err error) {
+	if token = h.requestToken(c); token != "" {
+		return token, false, nil
+	}
+	token, err = h.identity.Issue()
+	return token, err == nil, err
+}
+
+// clientIdentity 生成匿名客户端身份标识
+// 优先使用匿名令牌，没有令牌时退化为 IP 和 UA 的带密钥哈希，不在数据库中留下原始 IP
+func (h *Handler) clientIdentity(c *gin.Context) string {
+	if token := h.requestToken(c); token != "" {
+		return h.identity.Subject(token)
+	}
+	return h.identity.ClientHash(c.ClientIP(), c.GetHeader("User-Agent"))
+}
+
+// threadPseudonym 计算作者在帖子内的昵称
+// 同一作者在同一帖子内昵称保持不变，不同作者之间不重名
+func (h *Handler) threadPseudonym(post *models.Post, authorID string) (string, error) {
+	if authorID == post.AuthorID {
+		return identity.PosterName, nil
+	}
+
+	// 已经在帖子中回复过则沿用之前的昵称
+	var existing models.Reply
+	result := h.db.Unscoped().Where("post_id = ? AND author_id = ?", post.ID, authorID).Limit(1).Find(&existing)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if result.RowsAffected > 0 {
+		return existing.Author, nil
+	}
+
+	var taken []string
+	if err := h.db.Unscoped().Model(&models.Reply{}).
+		Where("post_id = ? AND author_id != ?", post.ID, authorID).
+		Distinct().Pluck("author", &taken).Error; err != nil {
+		return "", err
+	}
+	used := make(map[string]bool, len(taken)+1)
+	for _, name := range taken {
+		used[name] = true
+	}
+	used[identity.PosterName] = true
+
+	for attempt := 0; ; attempt++ {
+		if name := identity.Pseudonym(authorID, attempt); !used[name] {
+			return name, nil
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"treehole/internal/database"
+	"treehole/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 点赞目标类型
+const (
+	likeTargetPost  = "post"
+	likeTargetReply = "reply"
+)
+
+// 每个匿名客户端每分钟最多点赞/取消点赞的次数
+const likeRateLimit = 30
+
+// LikePost 点赞帖子
+func (h *Handler) LikePost(c *gin.Context) {
+	h.handleLike(c, likeTargetPost, true)
+}
+
+// UnlikePost 取消点赞帖子
+func (h *Handler) UnlikePost(c *gin.Context) {
+	h.handleLike(c, likeTargetPost, false)
+}
+
+// LikeReply 点赞回复
+func (h *Handler) LikeReply(c *gin.Context) {
+	h.handleLike(c, likeTargetReply, true)
+}
+
+// UnlikeReply 取消点赞回复
+func (h *Handler) UnlikeReply(c *gin.Context) {
+	h.handleLike(c, likeTargetReply, false)
+}
+
+// handleLike 点赞和取消点赞的公共逻辑
+func (h *Handler) handleLike(c *gin.Context, targetType string, like bool) {
+	clientHash := h.clientIdentity(c)
+	if !h.rateLimiter.Allow("like|"+clientHash, likeRateLimit, time.Minute) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many like requests, please try again later"})
+		return
+	}
+
+	var model interface{}
+	var targetID uint
+	var upstreamLikes int
+	switch targetType {
+	case likeTargetPost:
+		post, ok := h.findPost(c, c.Param("id"))
+		if !ok {
+			return
+		}
+		model, targetID, upstreamLikes = &models.Post{}, post.ID, post.LikeNum
+	case likeTargetReply:
+		reply, ok := h.findReply(c, c.Param("id"))
+		if !ok {
+			return
+		}
+		model, targetID, upstreamLikes = &models.Reply{}, reply.ID, reply.LikeNum
+	}
+
+	var localLikes int
+	err := database.SafeTransaction(h.db, func(tx *gorm.DB) error {
+		var existing models.Like
+		err := tx.Where("target_type = ? AND target_id = ? AND client_hash = ?", targetType, targetID, clientHash).
+			First(&existing).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		liked := err == nil
+
+		// 重复点赞或重复取消都视为成功，保持接口幂等
+		if like && !liked {
+			record := models.Like{TargetType: targetType, TargetID: targetID, ClientHash: clientHash}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+			if result.Error != nil {
+				return result.Error
+			}
+			// 并发的重复点赞会撞上唯一索引，没有插入记录时视为已点赞，不再计数
+			if result.RowsAffected > 0 {
+				if err := tx.Unscoped().Model(model).Where("id = ?", targetID).
+					Update("local_like_num", gorm.Expr("local_like_num + ?", 1)).Error; err != nil {
+					return err
+				}
+			}
+		} else if !like && liked {
+			if err := tx.Delete(&existing).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(model).Where("id = ? AND local_like_num > 0", targetID).
+				Update("local_like_num", gorm.Expr("local_like_num - ?", 1)).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Unscoped().Model(model).Select("local_like_num").Where("id = ?", targetID).Scan(&localLikes).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update like"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"liked":          like,
+		"like_num":       upstreamLikes,
+		"local_like_num": localLikes,
+	})
+}
+
+// findPost 按本地ID或原始ID查找帖子，找不到时直接写入错误响应
+func (h *Handler) findPost(c *gin.Context, id string) (*models.Post, bool) {
+	var post models.Post
+	if err := h.db.Where("id = ? OR original_id = ?", id, id).First(&post).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &post, true
+}
+
+// findReply 按本地ID或原始ID查找回复，找不到时直接写入错误响应
+func (h *Handler) findReply(c *gin.Context, id string) (*models.Reply, bool) {
+	var reply models.Reply
+	if err := h.db.Where("id = ? OR original_id = ?", id, id).First(&reply).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Reply not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &reply, true
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"treehole/internal/media"
+	"treehole/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// mediaURLPrefix 本地媒体文件的访问路径
+const mediaURLPrefix = "/media"
+
+// uploadFieldName 上传图片使用的表单字段
+const uploadFieldName = "images"
+
+// limitRequestBody 限制请求体大小，multipart 请求按图片数量和单张上限放宽
+func (h *Handler) limitRequestBody(c *gin.Context) {
+	limit := int64(1 << 20)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		limit += int64(h.config.MediaMaxFiles) * h.config.MediaMaxBytes
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+}
+
+// bindCreateRequest 绑定发帖或回复请求，multipart 请求按表单解析，其余按 JSON 解析
+func bindCreateRequest(c *gin.Context, req interface{}) error {
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		return c.ShouldBind(req)
+	}
+	return c.ShouldBindJSON(req)
+}
+
+// readUploadedImages 读取 multipart 请求中的图片并保存到媒体存储
+// 非 multipart 请求返回空列表
+func (h *Handler) readUploadedImages(c *gin.Context) ([]*media.Stored, error) {
+	if !strings.HasPrefix(c.ContentType(), "multipart/") {
+		return nil, nil
+	}
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+
+	files := form.File[uploadFieldName]
+	if len(files) == 0 {
+		return nil, nil
+	}
+	if h.media == nil {
+		return nil, fmt.Errorf("image upload is disabled")
+	}
+	if len(files) > h.config.MediaMaxFiles {
+		return nil, fmt.Errorf("too many images, maximum %d allowed", h.config.MediaMaxFiles)
+	}
+
+	stored := make([]*media.Stored, 0, len(files))
+	for _, fileHeader := range files {
+		if fileHeader.Size > h.config.MediaMaxBytes {
+			h.discardImages(stored)
+			return nil, media.ErrTooLarge
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			h.discardImages(stored)
+			return nil, err
+		}
+		data, err := io.ReadAll(io.LimitReader(file, h.config.MediaMaxBytes+1))
+		file.Close()
+		if err != nil {
+			h.discardImages(stored)
+			return nil, err
+		}
+
+		item, err := h.media.Save(data)
+		if err != nil {
+			h.discardImages(stored)
+			return nil, err
+		}
+		stored = append(stored, item)
+	}
+
+	return stored, nil
+}
+
+// discardImages 删除已保存但没有写入归属记录的图片
+// 相同内容的图片只保存一份，仍被其他帖子或回复引用的文件保留
+func (h *Handler) discardImages(stored []*media.Stored) {
+	for _, item := range stored {
+		var count int64
+		if err := h.db.Model(&models.Media{}).Where("hash = ?", item.Hash).Count(&count).Error; err != nil || count > 0 {
+			continue
+		}
+		if err := h.media.Remove(item); err != nil {
+			log.Printf("Failed to remove orphaned image %s: %v", item.Hash, err)
+		}
+	}
+}
+
+// attachMedia 记录图片归属
+func attachMedia(tx *gorm.DB, stored []*media.Stored, ownerType string, ownerID uint) error {
+	for _, item := range stored {
+		record := models.Media{
+			Hash:         item.Hash,
+			MimeType:     item.MimeType,
+			Width:        item.Width,
+			Height:       item.Height,
+			Size:         item.Size,
+			URL:          item.URL,
+			ThumbnailURL: item.ThumbnailURL,
+			OwnerType:    ownerType,
+			OwnerID:      ownerID,
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mediaURLs 生成图片地址列表，格式与同步的 images 字段一致
+func mediaURLs(stored []*media.Stored) string {
+	urls := make([]string, 0, len(stored))
+	for _, item := range stored {
+		urls = append(urls, item.URL)
+	}
+	data, _ := json.Marshal(urls)
+	return string(data)
+}
+
+// mediaCover 使用第一张图片的缩略图作为封面
+func mediaCover(stored []*media.Stored) string {
+	if len(stored) == 0 {
+		return "[]"
+	}
+	data, _ := json.Marshal([]string{stored[0].ThumbnailURL})
+	return string(data)
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"treehole/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxMetricsDays 计数曲线最多查询的天数
+const maxMetricsDays = 365
+
+// GetPostMetrics 获取帖子的点赞、回复和浏览数增长曲线
+// days 为查询的天数（默认 30），interval 为 raw、hour 或 day，默认按查询范围自动选择
+func (h *Handler) GetPostMetrics(c *gin.Context) {
+	post, ok := h.findPost(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days"})
+		return
+	}
+	if days > maxMetricsDays {
+		days = maxMetricsDays
+	}
+
+	interval := c.Query("interval")
+	if interval == "" {
+		interval = metrics.IntervalHour
+		if days > 7 {
+			interval = metrics.IntervalDay
+		}
+	}
+	if !metrics.ValidInterval(interval) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid interval, expected raw, hour or day"})
+		return
+	}
+
+	points, err := h.metrics.Series(post.ID, time.Now().AddDate(0, 0, -days), interval)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"post_id":  post.ID,
+		"days":     days,
+		"interval": interval,
+		"points":   points,
+		"current": gin.H{
+			"like_num":       post.LikeNum,
+			"local_like_num": post.LocalLikeNum,
+			"reply_count":    post.ReplyCount,
+			"view_count":     post.ViewCount,
+		},
+	})
+}
+
+// CompactMetrics 立即按保留策略压缩和清理计数快照
+func (h *Handler) CompactMetrics(c *gin.Context) {
+	result, err := h.metrics.Compact()
+	if errors.Is(err, metrics.ErrRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Metrics compaction is already running"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordAudit(c, "compact_metrics", "", 0, map[string]interface{}{
+		"downsampled": result.Downsampled,
+		"expired":     result.Expired,
+	})
+	c.JSON(http.StatusOK, result)
+}
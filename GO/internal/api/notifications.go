@@ -0,0 +1,153 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"time"
+	"treehole/internal/models"
+	"treehole/internal/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamHeartbeat 实时通知连接的心跳间隔，避免代理断开空闲连接
+const streamHeartbeat = 30 * time.Second
+
+// MarkReadRequest 标记通知已读的请求，ids 为空时标记全部
+type MarkReadRequest struct {
+	IDs []uint `json:"ids" binding:"max=100"`
+}
+
+// GetNotifications 获取当前身份的通知，按时间倒序
+// 传入 after_id 时只返回该ID之后的通知，用于轮询；unread=true 只返回未读通知
+func (h *Handler) GetNotifications(c *gin.Context) {
+	subject, ok := h.requireSubject(c)
+	if !ok {
+		return
+	}
+	page, limit := parsePagination(c)
+
+	var unread int64
+	if err := h.db.Model(&models.Notification{}).
+		Where("subscriber = ? AND read_at IS NULL", subject).
+		Count(&unread).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := h.db.Model(&models.Notification{}).Where("subscriber = ?", subject)
+	if afterID := c.Query("after_id"); afterID != "" {
+		query = query.Where("id > ?", afterID)
+	}
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+	if notificationType := c.Query("type"); notificationType != "" {
+		query = query.Where("type = ?", notificationType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var notifications []models.Notification
+	if err := query.Order("id desc").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"unread_count":  unread,
+		"pagination":    paginationMeta(page, limit, total),
+	})
+}
+
+// MarkNotificationsRead 将当前身份的通知标记为已读
+func (h *Handler) MarkNotificationsRead(c *gin.Context) {
+	subject, ok := h.requireSubject(c)
+	if !ok {
+		return
+	}
+	var req MarkReadRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	query := h.db.Model(&models.Notification{}).Where("subscriber = ? AND read_at IS NULL", subject)
+	if len(req.IDs) > 0 {
+		query = query.Where("id IN ?", req.IDs)
+	}
+	result := query.UpdateColumn("read_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+
+	var unread int64
+	if err := h.db.Model(&models.Notification{}).
+		Where("subscriber = ? AND read_at IS NULL", subject).
+		Count(&unread).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"marked": result.RowsAffected, "unread_count": unread})
+}
+
+// IssueStreamTicket 签发实时通知连接的一次性票据
+// 浏览器的 EventSource 无法设置请求头，用票据代替令牌放在 URL 中，令牌不会出现在访问日志里
+func (h *Handler) IssueStreamTicket(c *gin.Context) {
+	subject, ok := h.requireSubject(c)
+	if !ok {
+		return
+	}
+	ticket, err := h.notify.IssueTicket(subject)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue ticket"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ticket": ticket, "expires_in": int(notify.TicketTTL.Seconds())})
+}
+
+// StreamNotifications 以 SSE 推送当前身份的新通知，身份来自请求头中的令牌或 ticket 查询参数
+func (h *Handler) StreamNotifications(c *gin.Context) {
+	var subject string
+	if value := c.Query("ticket"); value != "" {
+		var ok bool
+		if subject, ok = h.notify.RedeemTicket(value); !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired stream ticket"})
+			return
+		}
+	} else {
+		var ok bool
+		if subject, ok = h.requireSubject(c); !ok {
+			return
+		}
+	}
+
+	notifications, cancel := h.notify.Subscribe(subject)
+	defer cancel()
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case notification := <-notifications:
+			c.SSEvent("notification", notification)
+		case <-heartbeat.C:
+			c.SSEvent("heartbeat", time.Now().Unix())
+		}
+		return true
+	})
+}
@@ -0,0 +1,317 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+	"treehole/internal/bookmark"
+	"treehole/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 字段可见级别
+const (
+	VisibilityPublic = "public" // 所有人可见
+	VisibilityMasked = "masked" // 匿名访问返回打码后的值，管理员 API 密钥可见原值
+	VisibilityAdmin  = "admin"  // 只有管理员 API 密钥可见
+	VisibilityHidden = "hidden" // 任何人都不可见
+)
+
+// sensitiveFields 受可见策略控制的字段，未配置时只有管理员可见
+var sensitiveFields = []string{"author_id", "apply_to", "ip", "wechat"}
+
+// FieldPolicy 敏感字段的可见级别，键为 JSON 字段名
+type FieldPolicy map[string]string
+
+// ParseFieldPolicy 解析 field=level 形式、以逗号分隔的可见策略
+func ParseFieldPolicy(value string) (FieldPolicy, error) {
+	policy := make(FieldPolicy, len(sensitiveFields))
+	for _, field := range sensitiveFields {
+		policy[field] = VisibilityAdmin
+	}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		field, level, ok := strings.Cut(entry, "=")
+		field, level = strings.TrimSpace(field), strings.TrimSpace(level)
+		if !ok {
+			return nil, fmt.Errorf("invalid field visibility %q", entry)
+		}
+		if _, known := policy[field]; !known {
+			return nil, fmt.Errorf("unknown sensitive field %q", field)
+		}
+		switch level {
+		case VisibilityPublic, VisibilityMasked, VisibilityAdmin, VisibilityHidden:
+			policy[field] = level
+		default:
+			return nil, fmt.Errorf("invalid visibility %q for field %s", level, field)
+		}
+	}
+	return policy, nil
+}
+
+// PublicPost 对外返回的帖子
+// 作者ID、IP 和微信号按可见策略打码或省略
+type PublicPost struct {
+	ID           uint      `json:"id"`
+	OriginalID   string    `json:"original_id"`
+	Title        string    `json:"title"`
+	Content      string    `json:"content"`
+	Author       string    `json:"author"`
+	AuthorID     string    `json:"author_id,omitempty"`
+	IP           string    `json:"ip,omitempty"`
+	LikeNum      int       `json:"like_num"`
+	LocalLikeNum int       `json:"local_like_num"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	ReplyCount   int       `json:"reply_count"`
+	ViewCount    int       `json:"view_count"`
+	RadioGroup   string    `json:"radio_group"`
+	CampusGroup  string    `json:"campus_group"`
+	Region       string    `json:"region"`
+	Price        string    `json:"price"`
+	Wechat       string    `json:"wechat,omitempty"`
+	Images       string    `json:"images"`
+	Cover        string    `json:"cover"`
+	State        string    `json:"state"`
+	Tag          string    `json:"tag"`
+	TagSource    string    `json:"tag_source"`
+	DuplicateOf  *uint     `json:"duplicate_of"`
+}
+
+// PublicReply 对外返回的回复
+// 作者ID和回复对象按可见策略打码或省略
+type PublicReply struct {
+	ID           uint      `json:"id"`
+	PostID       uint      `json:"post_id"`
+	OriginalID   string    `json:"original_id"`
+	Content      string    `json:"content"`
+	Author       string    `json:"author"`
+	AuthorID     string    `json:"author_id,omitempty"`
+	ApplyTo      string    `json:"apply_to,omitempty"`
+	Level        int       `json:"level"`
+	ParentID     int       `json:"parent_id"`
+	LikeNum      int       `json:"like_num"`
+	LocalLikeNum int       `json:"local_like_num"`
+	Images       string    `json:"images"`
+	Tag          string    `json:"tag"`
+	TagSource    string    `json:"tag_source"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// publicBookmarkItem 对外返回的收藏，帖子内容按可见策略输出
+type publicBookmarkItem struct {
+	models.Bookmark
+	Available bool        `json:"available"`
+	Archived  bool        `json:"archived"`
+	Post      interface{} `json:"post,omitempty"`
+}
+
+// selectableFields fields 参数可以选择的字段，包括各接口在帖子和回复上附加的字段
+var selectableFields = func() map[string]bool {
+	fields := make(map[string]bool)
+	for _, value := range []interface{}{PublicPost{}, PublicReply{}, relatedPost{}, trendingPost{}, statsThread{}, replyWithPost{}} {
+		collectJSONFields(reflect.TypeOf(value), fields)
+	}
+	return fields
+}()
+
+// collectJSONFields 收集结构体的 JSON 字段名，展开匿名嵌入的结构体
+func collectJSONFields(t reflect.Type, into map[string]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			collectJSONFields(field.Type, into)
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			into[name] = true
+		}
+	}
+}
+
+// viewer 决定一次请求能看到的字段
+// 携带有效管理员 API 密钥的请求按管理员可见级别输出，fields 参数只保留指定的字段
+type viewer struct {
+	admin  bool
+	policy FieldPolicy
+	fields map[string]bool // 为空时返回全部字段
+}
+
+// newViewer 根据请求的管理员 API 密钥和 fields 参数创建 viewer
+// fields 包含未知字段时写入错误响应并返回 false
+func (h *Handler) newViewer(c *gin.Context) (*viewer, bool) {
+	v := &viewer{policy: h.fieldPolicy}
+	if principal := h.authenticate(c); principal != nil && principal.apiKey {
+		v.admin = true
+	}
+
+	if raw := c.Query("fields"); raw != "" {
+		v.fields = make(map[string]bool)
+		for _, name := range strings.Split(raw, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if !selectableFields[name] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown field: " + name})
+				return nil, false
+			}
+			v.fields[name] = true
+		}
+	}
+	return v, true
+}
+
+// value 按可见策略输出敏感字段，不可见时返回空字符串
+func (v *viewer) value(field, value string) string {
+	if value == "" {
+		return ""
+	}
+	switch v.policy[field] {
+	case VisibilityPublic:
+		return value
+	case VisibilityMasked:
+		if v.admin {
+			return value
+		}
+		return mask(value)
+	case VisibilityAdmin:
+		if v.admin {
+			return value
+		}
+	}
+	return ""
+}
+
+// mask 保留首尾各两个字符，其余替换为星号，过短的值全部替换
+func mask(value string) string {
+	runes := []rune(value)
+	if len(runes) <= 4 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:2]) + strings.Repeat("*", len(runes)-4) + string(runes[len(runes)-2:])
+}
+
+// publicPost 转换为对外的帖子
+func (v *viewer) publicPost(post *models.Post) PublicPost {
+	return PublicPost{
+		ID:           post.ID,
+		OriginalID:   post.OriginalID,
+		Title:        post.Title,
+		Content:      post.Content,
+		Author:       post.Author,
+		AuthorID:     v.value("author_id", post.AuthorID),
+		IP:           v.value("ip", post.IP),
+		LikeNum:      post.LikeNum,
+		LocalLikeNum: post.LocalLikeNum,
+		CreatedAt:    post.CreatedAt,
+		UpdatedAt:    post.UpdatedAt,
+		ReplyCount:   post.ReplyCount,
+		ViewCount:    post.ViewCount,
+		RadioGroup:   post.RadioGroup,
+		CampusGroup:  post.CampusGroup,
+		Region:       post.Region,
+		Price:        post.Price,
+		Wechat:       v.value("wechat", post.Wechat),
+		Images:       post.Images,
+		Cover:        post.Cover,
+		State:        post.State,
+		Tag:          post.Tag,
+		TagSource:    post.TagSource,
+		DuplicateOf:  post.DuplicateOf,
+	}
+}
+
+// publicReply 转换为对外的回复
+func (v *viewer) publicReply(reply *models.Reply) PublicReply {
+	return PublicReply{
+		ID:           reply.ID,
+		PostID:       reply.PostID,
+		OriginalID:   reply.OriginalID,
+		Content:      reply.Content,
+		Author:       reply.Author,
+		AuthorID:     v.value("author_id", reply.AuthorID),
+		ApplyTo:      v.value("apply_to", reply.ApplyTo),
+		Level:        reply.Level,
+		ParentID:     reply.ParentID,
+		LikeNum:      reply.LikeNum,
+		LocalLikeNum: reply.LocalLikeNum,
+		Images:       reply.Images,
+		Tag:          reply.Tag,
+		TagSource:    reply.TagSource,
+		CreatedAt:    reply.CreatedAt,
+		UpdatedAt:    reply.UpdatedAt,
+	}
+}
+
+// post 输出单个帖子
+func (v *viewer) post(post *models.Post) interface{} {
+	return v.project(v.publicPost(post))
+}
+
+// posts 输出帖子列表
+func (v *viewer) posts(posts []models.Post) []interface{} {
+	items := make([]interface{}, 0, len(posts))
+	for i := range posts {
+		items = append(items, v.post(&posts[i]))
+	}
+	return items
+}
+
+// reply 输出单条回复
+func (v *viewer) reply(reply *models.Reply) interface{} {
+	return v.project(v.publicReply(reply))
+}
+
+// replies 输出回复列表
+func (v *viewer) replies(replies []models.Reply) []interface{} {
+	items := make([]interface{}, 0, len(replies))
+	for i := range replies {
+		items = append(items, v.reply(&replies[i]))
+	}
+	return items
+}
+
+// bookmarks 输出收藏列表
+func (v *viewer) bookmarks(items []bookmark.Item) []publicBookmarkItem {
+	result := make([]publicBookmarkItem, 0, len(items))
+	for _, item := range items {
+		public := publicBookmarkItem{Bookmark: item.Bookmark, Available: item.Available, Archived: item.Archived}
+		if item.Post != nil {
+			public.Post = v.post(item.Post)
+		}
+		result = append(result, public)
+	}
+	return result
+}
+
+// project 按 fields 参数裁剪对象，id 始终保留
+func (v *viewer) project(value interface{}) interface{} {
+	if len(v.fields) == 0 {
+		return value
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return value
+	}
+	trimmed := make(map[string]json.RawMessage, len(v.fields)+1)
+	for name, raw := range all {
+		if name == "id" || v.fields[name] {
+			trimmed[name] = raw
+		}
+	}
+	return trimmed
+}
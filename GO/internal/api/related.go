@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"treehole/internal/models"
+	"treehole/internal/related"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRelatedLimit 相关帖子最多返回的数量
+const maxRelatedLimit = 20
+
+// relatedPost 相关帖子
+type relatedPost struct {
+	PublicPost
+	Similarity float64 `json:"similarity"`
+}
+
+// GetRelatedPosts 获取标题和内容相似的帖子，limit 默认 5
+func (h *Handler) GetRelatedPosts(c *gin.Context) {
+	post, ok := h.findPost(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	view, ok := h.newViewer(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "5"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+	if limit > maxRelatedLimit {
+		limit = maxRelatedLimit
+	}
+
+	// 索引中可能有已被隐藏的帖子，多取一些候选再过滤
+	matches := h.related.Related(post, limit*2)
+	postIDs := make([]uint, 0, len(matches))
+	for _, match := range matches {
+		postIDs = append(postIDs, match.PostID)
+	}
+	var posts []models.Post
+	if len(postIDs) > 0 {
+		if err := h.db.Where("id IN ?", postIDs).Find(&posts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	postByID := make(map[uint]models.Post, len(posts))
+	for _, p := range posts {
+		postByID[p.ID] = p
+	}
+
+	items := make([]interface{}, 0, limit)
+	for _, match := range matches {
+		if p, ok := postByID[match.PostID]; ok && len(items) < limit {
+			items = append(items, view.project(relatedPost{PublicPost: view.publicPost(&p), Similarity: match.Score}))
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"post_id": post.ID, "posts": items})
+}
+
+// RebuildRelated 立即重建相关帖子索引
+func (h *Handler) RebuildRelated(c *gin.Context) {
+	result, err := h.related.Rebuild()
+	if errors.Is(err, related.ErrRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Related index update is already running"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordAudit(c, "rebuild_related", "", 0, map[string]interface{}{
+		"indexed": result.Indexed,
+		"total":   result.Total,
+	})
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+	"treehole/internal/moderation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 每个匿名客户端每分钟最多提交的举报次数
+const reportRateLimit = 10
+
+// 每个来源 IP 每分钟最多提交的举报次数，防止批量签发匿名身份刷举报
+const reportNetworkRateLimit = 30
+
+// ReportRequest 举报请求
+type ReportRequest struct {
+	Category string `json:"category" binding:"required"`
+	Detail   string `json:"detail"`
+}
+
+// ReportPost 举报帖子
+func (h *Handler) ReportPost(c *gin.Context) {
+	h.handleReport(c, moderation.TargetPost)
+}
+
+// ReportReply 举报回复
+func (h *Handler) ReportReply(c *gin.Context) {
+	h.handleReport(c, moderation.TargetReply)
+}
+
+// GetReportCategories 获取可选的举报类别
+func (h *Handler) GetReportCategories(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"categories": moderation.ReportCategories})
+}
+
+// handleReport 举报的公共逻辑
+func (h *Handler) handleReport(c *gin.Context, targetType string) {
+	clientHash := h.clientIdentity(c)
+	if !h.rateLimiter.Allow("report|"+clientHash, reportRateLimit, time.Minute) ||
+		!h.rateLimiter.Allow("report-ip|"+c.ClientIP(), reportNetworkRateLimit, time.Minute) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many reports, please try again later"})
+		return
+	}
+
+	var req ReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	detail := ""
+	if req.Detail != "" {
+		var err error
+		if detail, err = validateAndSanitizeInput(req.Detail, 500); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var targetID uint
+	switch targetType {
+	case moderation.TargetPost:
+		post, ok := h.findPost(c, c.Param("id"))
+		if !ok {
+			return
+		}
+		targetID = post.ID
+	case moderation.TargetReply:
+		reply, ok := h.findReply(c, c.Param("id"))
+		if !ok {
+			return
+		}
+		targetID = reply.ID
+	}
+
+	result, err := h.moderation.Report(targetType, targetID, clientHash, h.identity.NetworkHash(c.ClientIP()), req.Category, detail, h.config.ReportHideThreshold)
+	if err != nil {
+		switch {
+		case errors.Is(err, moderation.ErrInvalidCategory):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, moderation.ErrDuplicateReport):
+			c.JSON(http.StatusConflict, gin.H{"error": "You have already reported this content"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit report"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Report submitted",
+		"report_count": result.ReportCount,
+		"hidden":       result.Hidden,
+	})
+}
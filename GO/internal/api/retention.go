@@ -0,0 +1,39 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"treehole/internal/retention"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRetentionPolicy 获取数据保留策略
+func (h *Handler) GetRetentionPolicy(c *gin.Context) {
+	cfg := h.retention.Config()
+	c.JSON(http.StatusOK, gin.H{
+		"cron":                h.config.RetentionCron,
+		"openid_after":        cfg.OpenIDAfter.String(),
+		"openid_mode":         cfg.OpenIDMode,
+		"ip_after":            cfg.IPAfter.String(),
+		"wechat_after":        cfg.WechatAfter.String(),
+		"purge_deleted_after": cfg.PurgeDeletedAfter.String(),
+	})
+}
+
+// RunRetention 立即执行数据保留策略，dry_run=true 时只返回将要处理的数量
+func (h *Handler) RunRetention(c *gin.Context) {
+	report, err := h.retention.Run(adminActor(c), c.Query("dry_run") == "true")
+	switch {
+	case errors.Is(err, retention.ErrRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, retention.ErrNoHashSecret):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
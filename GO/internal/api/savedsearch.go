@@ -0,0 +1,167 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"treehole/internal/models"
+	"treehole/internal/savedsearch"
+	"treehole/internal/webhook"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SavedSearchRequest 创建或修改关键词订阅的请求
+type SavedSearchRequest struct {
+	Name         string `json:"name" binding:"max=64"`
+	Keywords     string `json:"keywords" binding:"required,max=200"`
+	RadioGroup   string `json:"radio_group" binding:"max=32"`
+	MatchReplies bool   `json:"match_replies"`
+	WebhookURL   string `json:"webhook_url" binding:"max=512"`
+}
+
+// requireSubject 获取匿名令牌对应的身份，没有有效令牌时返回 401
+func (h *Handler) requireSubject(c *gin.Context) (string, bool) {
+	token := h.requestToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Anonymous identity token required"})
+		return "", false
+	}
+	return h.identity.Subject(token), true
+}
+
+// bindSavedSearch 解析并校验订阅请求，首次填写 Webhook 地址时生成签名密钥
+func bindSavedSearch(c *gin.Context, search *models.SavedSearch) bool {
+	var req SavedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	keywords, err := savedsearch.ParseKeywords(req.Keywords)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	req.WebhookURL = strings.TrimSpace(req.WebhookURL)
+	if req.WebhookURL != "" {
+		if err := webhook.ValidatePublicURL(req.WebhookURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+		if search.WebhookSecret == "" {
+			secret, err := generateSecret("whsec_")
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+				return false
+			}
+			search.WebhookSecret = secret
+		}
+	}
+
+	search.Name = strings.TrimSpace(req.Name)
+	if search.Name == "" {
+		search.Name = strings.Join(keywords, " ")
+	}
+	search.Keywords = strings.Join(keywords, " ")
+	search.RadioGroup = strings.TrimSpace(req.RadioGroup)
+	search.MatchReplies = req.MatchReplies
+	search.WebhookURL = req.WebhookURL
+	return true
+}
+
+// findSavedSearch 查找当前身份的订阅，找不到时直接写入错误响应
+func (h *Handler) findSavedSearch(c *gin.Context, subject string) (*models.SavedSearch, bool) {
+	var search models.SavedSearch
+	result := h.db.Where("id = ? AND subscriber = ?", c.Param("id"), subject).Limit(1).Find(&search)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return nil, false
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Saved search not found"})
+		return nil, false
+	}
+	return &search, true
+}
+
+// ListSavedSearches 获取当前身份的关键词订阅
+func (h *Handler) ListSavedSearches(c *gin.Context) {
+	subject, ok := h.requireSubject(c)
+	if !ok {
+		return
+	}
+	var searches []models.SavedSearch
+	if err := h.db.Where("subscriber = ?", subject).Order("id asc").Find(&searches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"saved_searches": searches})
+}
+
+// CreateSavedSearch 创建关键词订阅，只通知创建之后同步到的内容
+func (h *Handler) CreateSavedSearch(c *gin.Context) {
+	subject, ok := h.requireSubject(c)
+	if !ok {
+		return
+	}
+	search := models.SavedSearch{Subscriber: subject}
+	if !bindSavedSearch(c, &search) {
+		return
+	}
+
+	var count int64
+	if err := h.db.Model(&models.SavedSearch{}).Where("subscriber = ?", subject).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if count >= savedsearch.MaxPerSubscriber {
+		c.JSON(http.StatusConflict, gin.H{"error": "Too many saved searches"})
+		return
+	}
+
+	if err := savedsearch.StartFrom(h.db, &search); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.db.Create(&search).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, search)
+}
+
+// UpdateSavedSearch 修改关键词订阅
+func (h *Handler) UpdateSavedSearch(c *gin.Context) {
+	subject, ok := h.requireSubject(c)
+	if !ok {
+		return
+	}
+	search, ok := h.findSavedSearch(c, subject)
+	if !ok {
+		return
+	}
+	if !bindSavedSearch(c, search) {
+		return
+	}
+	if err := h.db.Save(search).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, search)
+}
+
+// DeleteSavedSearch 删除关键词订阅，已收到的通知保留
+func (h *Handler) DeleteSavedSearch(c *gin.Context) {
+	subject, ok := h.requireSubject(c)
+	if !ok {
+		return
+	}
+	search, ok := h.findSavedSearch(c, subject)
+	if !ok {
+		return
+	}
+	if err := h.db.Delete(search).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Saved search deleted"})
+}
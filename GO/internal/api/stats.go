@@ -0,0 +1,221 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"treehole/internal/models"
+	"treehole/internal/stats"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 统计查询的时间范围和数量限制
+const (
+	maxStatsDays     = 365
+	maxStatsHourDays = 31
+	maxStatsLimit    = 100
+)
+
+// statsDays 解析统计的天数，超过上限时按上限处理
+func statsDays(c *gin.Context, defaultDays string, maxDays int) (int, bool) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", defaultDays))
+	if err != nil || days <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days"})
+		return 0, false
+	}
+	if days > maxDays {
+		days = maxDays
+	}
+	return days, true
+}
+
+// statsLimit 解析排行返回的数量
+func statsLimit(c *gin.Context) (int, bool) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return 0, false
+	}
+	if limit > maxStatsLimit {
+		limit = maxStatsLimit
+	}
+	return limit, true
+}
+
+// statsInterval 解析聚合粒度，默认按天；按小时统计时天数不超过 maxStatsHourDays
+func statsInterval(c *gin.Context) (string, int, bool) {
+	interval := c.DefaultQuery("interval", stats.IntervalDay)
+	if !stats.ValidInterval(interval) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid interval, expected hour, day or week"})
+		return "", 0, false
+	}
+	maxDays := maxStatsDays
+	if interval == stats.IntervalHour {
+		maxDays = maxStatsHourDays
+	}
+	days, ok := statsDays(c, "30", maxDays)
+	return interval, days, ok
+}
+
+// statsSince 统计范围的开始时间
+func statsSince(days int) time.Time {
+	return time.Now().AddDate(0, 0, -days)
+}
+
+// GetStatsTimeseries 按小时、天或周统计发帖和回复数量
+func (h *Handler) GetStatsTimeseries(c *gin.Context) {
+	interval, days, ok := statsInterval(c)
+	if !ok {
+		return
+	}
+
+	points, err := h.stats.Timeseries(statsSince(days), interval)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"days": days, "interval": interval, "points": points})
+}
+
+// GetStatsHeatmap 按星期和小时统计发帖和回复数量
+func (h *Handler) GetStatsHeatmap(c *gin.Context) {
+	days, ok := statsDays(c, "30", maxStatsDays)
+	if !ok {
+		return
+	}
+
+	heatmap, err := h.stats.Heatmap(statsSince(days))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"days": days, "posts": heatmap.Posts, "replies": heatmap.Replies})
+}
+
+// GetStatsBreakdown 按 radio_group、campus_group 或 region 分组统计帖子
+func (h *Handler) GetStatsBreakdown(c *gin.Context) {
+	field := c.DefaultQuery("field", "radio_group")
+	if !stats.ValidBreakdownField(field) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid field, expected radio_group, campus_group or region"})
+		return
+	}
+	days, ok := statsDays(c, "30", maxStatsDays)
+	if !ok {
+		return
+	}
+
+	groups, err := h.stats.Breakdown(field, statsSince(days))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"days": days, "field": field, "groups": groups})
+}
+
+// GetStatsTopTags 统计时间范围内使用最多的标签
+func (h *Handler) GetStatsTopTags(c *gin.Context) {
+	days, ok := statsDays(c, "7", maxStatsDays)
+	if !ok {
+		return
+	}
+	limit, ok := statsLimit(c)
+	if !ok {
+		return
+	}
+
+	tags, err := h.stats.TopTags(statsSince(days), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"days": days, "tags": tags})
+}
+
+// statsThread 新增回复最多的帖子
+type statsThread struct {
+	PublicPost
+	NewReplies int64 `json:"new_replies"`
+}
+
+// GetStatsTopThreads 统计时间范围内新增回复最多的帖子
+func (h *Handler) GetStatsTopThreads(c *gin.Context) {
+	days, ok := statsDays(c, "7", maxStatsDays)
+	if !ok {
+		return
+	}
+	limit, ok := statsLimit(c)
+	if !ok {
+		return
+	}
+	view, ok := h.newViewer(c)
+	if !ok {
+		return
+	}
+
+	threads, err := h.stats.TopThreads(statsSince(days), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	postIDs := make([]uint, 0, len(threads))
+	for _, thread := range threads {
+		postIDs = append(postIDs, thread.PostID)
+	}
+	var posts []models.Post
+	if len(postIDs) > 0 {
+		if err := h.db.Where("id IN ?", postIDs).Find(&posts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	postByID := make(map[uint]models.Post, len(posts))
+	for _, post := range posts {
+		postByID[post.ID] = post
+	}
+
+	// 缓存期间被隐藏的帖子不再返回
+	items := make([]interface{}, 0, len(threads))
+	for _, thread := range threads {
+		if post, ok := postByID[thread.PostID]; ok {
+			items = append(items, view.project(statsThread{PublicPost: view.publicPost(&post), NewReplies: thread.Replies}))
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"days": days, "posts": items})
+}
+
+// GetStatsSync 统计同步次数、失败次数和同步的数据量
+func (h *Handler) GetStatsSync(c *gin.Context) {
+	interval, days, ok := statsInterval(c)
+	if !ok {
+		return
+	}
+
+	points, err := h.stats.SyncThroughput(statsSince(days), interval)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var total stats.SyncPoint
+	for _, point := range points {
+		total.Runs += point.Runs
+		total.Success += point.Success
+		total.Errors += point.Errors
+		total.Posts += point.Posts
+		total.Replies += point.Replies
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"days":     days,
+		"interval": interval,
+		"points":   points,
+		"total": gin.H{
+			"runs":    total.Runs,
+			"success": total.Success,
+			"errors":  total.Errors,
+			"posts":   total.Posts,
+			"replies": total.Replies,
+		},
+	})
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"treehole/internal/models"
+	"treehole/internal/subscription"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListThreadSubscriptions 获取当前身份订阅的帖子
+func (h *Handler) ListThreadSubscriptions(c *gin.Context) {
+	subject, ok := h.requireSubject(c)
+	if !ok {
+		return
+	}
+	page, limit := parsePagination(c)
+
+	query := h.db.Model(&models.ThreadSubscription{}).Where("subscriber = ?", subject)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var subscriptions []models.ThreadSubscription
+	if err := query.Order("id desc").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&subscriptions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"subscriptions": subscriptions,
+		"pagination":    paginationMeta(page, limit, total),
+	})
+}
+
+// SubscribePost 订阅帖子的新回复，回复自己的内容会单独标记
+func (h *Handler) SubscribePost(c *gin.Context) {
+	subject, ok := h.requireSubject(c)
+	if !ok {
+		return
+	}
+	post, ok := h.findPost(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	authorID := h.identity.ThreadAuthorID(h.requestToken(c), post.ID)
+	sub, err := subscription.Subscribe(h.db, subject, authorID, post.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+// UnsubscribePost 取消订阅帖子，已收到的通知保留
+func (h *Handler) UnsubscribePost(c *gin.Context) {
+	subject, ok := h.requireSubject(c)
+	if !ok {
+		return
+	}
+	post, ok := h.findPost(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	result := h.db.Where("subscriber = ? AND post_id = ?", subject, post.ID).Delete(&models.ThreadSubscription{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Unsubscribed"})
+}
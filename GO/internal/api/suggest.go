@@ -0,0 +1,48 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"treehole/internal/suggest"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SuggestSearch 获取搜索建议，返回以 q 开头的热门搜索词、标签和标题短语
+func (h *Handler) SuggestSearch(c *gin.Context) {
+	prefix := c.Query("q")
+	if suggest.NormalizeQuery(prefix) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+
+	suggestions := h.suggest.Suggest(prefix, limit)
+	if suggestions == nil {
+		suggestions = []suggest.Suggestion{}
+	}
+	c.JSON(http.StatusOK, gin.H{"query": prefix, "suggestions": suggestions})
+}
+
+// RebuildSuggestions 立即重建搜索建议
+func (h *Handler) RebuildSuggestions(c *gin.Context) {
+	result, err := h.suggest.Rebuild()
+	if errors.Is(err, suggest.ErrRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Suggestion rebuild is already running"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordAudit(c, "rebuild_suggestions", "", 0, map[string]interface{}{
+		"total": result.Total,
+	})
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,405 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"treehole/internal/classifier"
+	"treehole/internal/database"
+	"treehole/internal/models"
+	"treehole/internal/moderation"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// tagPostCounts 统计每个标签直接关联的帖子数，不包括已隐藏的帖子
+func (h *Handler) tagPostCounts() (map[uint]int64, error) {
+	var rows []struct {
+		TagID uint
+		Count int64
+	}
+	if err := h.db.Table("post_tags").
+		Select("post_tags.tag_id, COUNT(*) AS count").
+		Joins("JOIN posts ON posts.id = post_tags.post_id AND posts.deleted_at IS NULL").
+		Group("post_tags.tag_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.TagID] = row.Count
+	}
+	return counts, nil
+}
+
+// taggedPostIDs 带有指定标签或其子标签的帖子 ID 子查询
+func (h *Handler) taggedPostIDs(ix *classifier.TagIndex, tag *models.Tag) *gorm.DB {
+	return h.db.Model(&models.PostTag{}).
+		Select("post_id").
+		Where("tag_id IN ?", ix.Descendants(tag.ID))
+}
+
+// tagItem 标签详情
+type tagItem struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Aliases     []string  `json:"aliases"`
+	ParentID    *uint     `json:"parent_id"`
+	PostCount   int64     `json:"post_count"`
+	Children    []tagItem `json:"children,omitempty"`
+}
+
+// newTagItem 根据标签模型生成标签详情
+func newTagItem(tag *models.Tag, postCount int64) tagItem {
+	aliases := classifier.SplitAliases(tag.Aliases)
+	if aliases == nil {
+		aliases = []string{}
+	}
+	return tagItem{
+		ID:          tag.ID,
+		Name:        tag.Name,
+		Description: tag.Description,
+		Aliases:     aliases,
+		ParentID:    tag.ParentID,
+		PostCount:   postCount,
+	}
+}
+
+// buildTagTree 按父子关系组织标签，同级按帖子数从多到少排列
+func buildTagTree(ix *classifier.TagIndex, counts map[uint]int64) []tagItem {
+	var build func(ids []uint) []tagItem
+	build = func(ids []uint) []tagItem {
+		items := make([]tagItem, 0, len(ids))
+		for _, id := range ids {
+			tag, _ := ix.Get(id)
+			item := newTagItem(tag, counts[id])
+			item.Children = build(ix.Children(id))
+			items = append(items, item)
+		}
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].PostCount > items[j].PostCount
+		})
+		return items
+	}
+
+	var roots []uint
+	for _, tag := range ix.Tags() {
+		if tag.ParentID == nil {
+			roots = append(roots, tag.ID)
+		}
+	}
+	return build(roots)
+}
+
+// SetTagsRequest 人工设置标签请求，标签为空时恢复自动分类
+type SetTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// SetTags 人工设置帖子或回复的标签，人工标签不会被自动分类覆盖
+func (h *Handler) SetTags(targetType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+			return
+		}
+
+		var req SetTagsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		tags, err := classifier.NormalizeTags(req.Tags)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var model interface{} = &models.Post{}
+		if targetType == moderation.TargetReply {
+			model = &models.Reply{}
+		}
+		ix, err := classifier.LoadTagIndex(h.db)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var updates map[string]interface{}
+		err = database.SafeTransaction(h.db, func(tx *gorm.DB) error {
+			// 已隐藏的内容也允许修改标签
+			if err := tx.Unscoped().First(model, id).Error; err != nil {
+				return err
+			}
+			// 帖子的标签同时写入关联表，别名解析为规范标签名
+			if targetType == moderation.TargetPost {
+				names, err := classifier.SetPostTags(tx, ix, uint(id), tags)
+				if err != nil {
+					return err
+				}
+				tags = names
+			}
+			updates = classifier.TagUpdates(tags)
+			if err := tx.Unscoped().Model(model).Where("id = ?", id).UpdateColumns(updates).Error; err != nil {
+				return err
+			}
+			return h.moderation.Record(tx, adminActor(c), "set_tags", targetType, uint(id), "", map[string]interface{}{
+				"tags": tags,
+			})
+		})
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Target not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"target_type": targetType,
+			"target_id":   id,
+			"tag":         updates["tag"],
+			"tag_source":  updates["tag_source"],
+		})
+	}
+}
+
+// TagRequest 新建或修改标签请求
+type TagRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Aliases     []string `json:"aliases"`
+	ParentID    *uint    `json:"parent_id"`
+}
+
+// bindTagRequest 绑定并校验标签请求，id 为 0 表示新建
+func bindTagRequest(c *gin.Context, ix *classifier.TagIndex, id uint) (*TagRequest, bool) {
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	var aliases []string
+	for _, alias := range req.Aliases {
+		alias = strings.TrimSpace(alias)
+		if alias != "" && !strings.EqualFold(alias, req.Name) && !containsFold(aliases, alias) {
+			aliases = append(aliases, alias)
+		}
+	}
+	req.Aliases = aliases
+
+	if err := ix.ValidateDefinition(id, req.Name, req.Aliases, req.ParentID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &req, true
+}
+
+// containsFold 不区分大小写检查列表中是否包含指定字符串
+func containsFold(values []string, value string) bool {
+	for _, existing := range values {
+		if strings.EqualFold(existing, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateTag 新建标签
+func (h *Handler) CreateTag(c *gin.Context) {
+	ix, err := classifier.LoadTagIndex(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	req, ok := bindTagRequest(c, ix, 0)
+	if !ok {
+		return
+	}
+
+	tag := models.Tag{
+		Name:        req.Name,
+		Description: req.Description,
+		Aliases:     classifier.JoinTags(req.Aliases),
+		ParentID:    req.ParentID,
+	}
+	err = database.SafeTransaction(h.db, func(tx *gorm.DB) error {
+		if err := tx.Create(&tag).Error; err != nil {
+			return err
+		}
+		return h.moderation.Record(tx, adminActor(c), "create_tag", "tag", tag.ID, "", map[string]interface{}{
+			"name": tag.Name,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newTagItem(&tag, 0))
+}
+
+// UpdateTag 修改标签，改名后同步更新帖子的 tag 字段
+func (h *Handler) UpdateTag(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+		return
+	}
+	ix, err := classifier.LoadTagIndex(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	existing, found := ix.Get(uint(id))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+		return
+	}
+	req, ok := bindTagRequest(c, ix, uint(id))
+	if !ok {
+		return
+	}
+
+	tag := *existing
+	tag.Name = req.Name
+	tag.Description = req.Description
+	tag.Aliases = classifier.JoinTags(req.Aliases)
+	tag.ParentID = req.ParentID
+
+	err = database.SafeTransaction(h.db, func(tx *gorm.DB) error {
+		if err := tx.Select("name", "description", "aliases", "parent_id", "updated_at").Save(&tag).Error; err != nil {
+			return err
+		}
+		if tag.Name != existing.Name {
+			var postIDs []uint
+			if err := tx.Model(&models.PostTag{}).Where("tag_id = ?", tag.ID).Pluck("post_id", &postIDs).Error; err != nil {
+				return err
+			}
+			if err := classifier.RefreshPostTags(tx, postIDs); err != nil {
+				return err
+			}
+		}
+		return h.moderation.Record(tx, adminActor(c), "update_tag", "tag", tag.ID, "", map[string]interface{}{
+			"previous_name": existing.Name,
+			"name":          tag.Name,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	counts, _ := h.tagPostCounts()
+	c.JSON(http.StatusOK, newTagItem(&tag, counts[tag.ID]))
+}
+
+// DeleteTag 删除标签，子标签移到被删除标签的父标签下
+func (h *Handler) DeleteTag(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+		return
+	}
+
+	var tag models.Tag
+	var postIDs []uint
+	err = database.SafeTransaction(h.db, func(tx *gorm.DB) error {
+		if err := tx.First(&tag, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.PostTag{}).Where("tag_id = ?", tag.ID).Pluck("post_id", &postIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id = ?", tag.ID).Delete(&models.PostTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Tag{}).Where("parent_id = ?", tag.ID).Update("parent_id", tag.ParentID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&tag).Error; err != nil {
+			return err
+		}
+		if err := classifier.RefreshPostTags(tx, postIDs); err != nil {
+			return err
+		}
+		return h.moderation.Record(tx, adminActor(c), "delete_tag", "tag", tag.ID, "", map[string]interface{}{
+			"name":  tag.Name,
+			"posts": len(postIDs),
+		})
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Tag deleted",
+		"id":            tag.ID,
+		"updated_posts": len(postIDs),
+	})
+}
+
+// RunClassifier 立即对未分类的内容执行一次自动分类
+func (h *Handler) RunClassifier(c *gin.Context) {
+	result, err := h.classifier.Run()
+	if errors.Is(err, classifier.ErrRunning) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Classifier job is already running"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.moderation.Record(h.db, adminActor(c), "run_classifier", "", 0, "", map[string]interface{}{
+		"posts":   result.Posts,
+		"replies": result.Replies,
+	})
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ReloadClassifier 重新加载分类规则文件
+func (h *Handler) ReloadClassifier(c *gin.Context) {
+	count, err := h.classifier.Reload()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.moderation.Record(h.db, adminActor(c), "reload_classifier", "", 0, "", map[string]interface{}{
+		"rules": count,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"rules": count})
+}
+
+// CheckClassifierRequest 分类测试请求
+type CheckClassifierRequest struct {
+	Title   string `json:"title"`
+	Content string `json:"content" binding:"required"`
+}
+
+// CheckClassifier 测试一段文本的分类结果
+func (h *Handler) CheckClassifier(c *gin.Context) {
+	var req CheckClassifierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tags": h.classifier.Classify(req.Title, req.Content)})
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"treehole/internal/models"
+	"treehole/internal/trending"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trendingPost 热度排行中的帖子
+type trendingPost struct {
+	PublicPost
+	Trending models.TrendingPost `json:"trending"`
+}
+
+// GetTrendingPosts 获取热度排行，window 为配置的时间窗口，默认 24h
+func (h *Handler) GetTrendingPosts(c *gin.Context) {
+	window := h.trending.DefaultWindow()
+	if name := c.Query("window"); name != "" {
+		var ok bool
+		if window, ok = h.trending.Window(name); !ok {
+			names := make([]string, 0, len(h.trending.Windows()))
+			for _, w := range h.trending.Windows() {
+				names = append(names, w.Name)
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid window", "windows": names})
+			return
+		}
+	}
+	page, limit := parsePagination(c)
+	view, ok := h.newViewer(c)
+	if !ok {
+		return
+	}
+
+	var total int64
+	query := h.db.Model(&models.TrendingPost{}).Where("time_window = ?", window.Name)
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var ranks []models.TrendingPost
+	if err := query.Order("ranking asc").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&ranks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	postIDs := make([]uint, 0, len(ranks))
+	for _, rank := range ranks {
+		postIDs = append(postIDs, rank.PostID)
+	}
+	var posts []models.Post
+	if len(postIDs) > 0 {
+		if err := h.db.Where("id IN ?", postIDs).Find(&posts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	postByID := make(map[uint]models.Post, len(posts))
+	for _, post := range posts {
+		postByID[post.ID] = post
+	}
+
+	// 排行计算后被隐藏的帖子不再返回
+	items := make([]interface{}, 0, len(ranks))
+	var computedAt interface{}
+	for _, rank := range ranks {
+		computedAt = rank.ComputedAt
+		if post, ok := postByID[rank.PostID]; ok {
+			items = append(items, view.project(trendingPost{PublicPost: view.publicPost(&post), Trending: rank}))
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"posts":       items,
+		"window":      window.Name,
+		"computed_at": computedAt,
+		"pagination":  paginationMeta(page, limit, total),
+	})
+}
+
+// RefreshTrending 立即重新计算热度排行
+func (h *Handler) RefreshTrending(c *gin.Context) {
+	if err := h.trending.Refresh(); err != nil {
+		if errors.Is(err, trending.ErrRunning) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Trending refresh is already running"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.recordAudit(c, "refresh_trending", "", 0, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Trending ranking refreshed"})
+}
@@ -0,0 +1,240 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"treehole/internal/classifier"
+	"treehole/internal/database"
+	"treehole/internal/models"
+	"treehole/internal/webhook"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// WebhookRequest 创建或修改 Webhook 的请求
+type WebhookRequest struct {
+	Name       string   `json:"name" binding:"required,max=64"`
+	URL        string   `json:"url" binding:"required,max=512"`
+	Events     []string `json:"events"`
+	Tags       []string `json:"tags"`
+	RadioGroup string   `json:"radio_group" binding:"max=32"`
+	Keywords   string   `json:"keywords" binding:"max=200"`
+	Active     *bool    `json:"active"`
+}
+
+// bindWebhook 解析并校验 Webhook 请求
+func bindWebhook(c *gin.Context, hook *models.Webhook) bool {
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	u, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an absolute http or https URL"})
+		return false
+	}
+	for _, event := range req.Events {
+		if !webhook.ValidEvent(event) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown event: " + event, "events": webhook.Events})
+			return false
+		}
+	}
+	tags, err := classifier.NormalizeTags(req.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	hook.Name = strings.TrimSpace(req.Name)
+	hook.URL = u.String()
+	hook.Events = strings.Join(req.Events, ",")
+	hook.Tags = classifier.JoinTags(tags)
+	hook.RadioGroup = strings.TrimSpace(req.RadioGroup)
+	hook.Keywords = strings.Join(strings.Fields(req.Keywords), " ")
+	if req.Active != nil {
+		hook.Active = *req.Active
+	}
+	return true
+}
+
+// findWebhook 查找 Webhook，找不到时直接写入错误响应
+func (h *Handler) findWebhook(c *gin.Context) (*models.Webhook, bool) {
+	var hook models.Webhook
+	result := h.db.Where("id = ?", c.Param("id")).Limit(1).Find(&hook)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return nil, false
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return nil, false
+	}
+	return &hook, true
+}
+
+// ListWebhooks 获取所有 Webhook
+func (h *Handler) ListWebhooks(c *gin.Context) {
+	var hooks []models.Webhook
+	if err := h.db.Order("id asc").Find(&hooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"webhooks": hooks, "events": webhook.Events})
+}
+
+// CreateWebhook 注册 Webhook，签名密钥只在创建时返回
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	hook := models.Webhook{Active: true}
+	if !bindWebhook(c, &hook) {
+		return
+	}
+	secret, err := generateSecret("whsec_")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	hook.Secret = secret
+
+	err = database.SafeTransaction(h.db, func(tx *gorm.DB) error {
+		if err := tx.Create(&hook).Error; err != nil {
+			return err
+		}
+		return h.moderation.Record(tx, adminActor(c), "create_webhook", "webhook", hook.ID, "", map[string]interface{}{
+			"name": hook.Name,
+			"url":  hook.URL,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"webhook": hook, "secret": secret})
+}
+
+// UpdateWebhook 修改 Webhook
+func (h *Handler) UpdateWebhook(c *gin.Context) {
+	hook, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+	if !bindWebhook(c, hook) {
+		return
+	}
+	err := database.SafeTransaction(h.db, func(tx *gorm.DB) error {
+		if err := tx.Save(hook).Error; err != nil {
+			return err
+		}
+		return h.moderation.Record(tx, adminActor(c), "update_webhook", "webhook", hook.ID, "", map[string]interface{}{
+			"name":   hook.Name,
+			"url":    hook.URL,
+			"active": hook.Active,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, hook)
+}
+
+// DeleteWebhook 删除 Webhook 及其投递记录
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	hook, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+	err := database.SafeTransaction(h.db, func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", hook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(hook).Error; err != nil {
+			return err
+		}
+		return h.moderation.Record(tx, adminActor(c), "delete_webhook", "webhook", hook.ID, "", map[string]interface{}{
+			"name": hook.Name,
+		})
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+// PingWebhook 向 Webhook 发送测试事件
+func (h *Handler) PingWebhook(c *gin.Context) {
+	hook, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+	delivery, err := h.webhooks.Ping(hook)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, delivery)
+}
+
+// GetWebhookDeliveries 获取 Webhook 的投递记录，按时间倒序，可按状态和事件筛选
+func (h *Handler) GetWebhookDeliveries(c *gin.Context) {
+	hook, ok := h.findWebhook(c)
+	if !ok {
+		return
+	}
+	page, limit := parsePagination(c)
+
+	query := h.db.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", hook.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("id desc").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"pagination": paginationMeta(page, limit, total),
+	})
+}
+
+// RedeliverWebhook 重新投递一条记录
+func (h *Handler) RedeliverWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+		return
+	}
+	var delivery models.WebhookDelivery
+	result := h.db.Where("id = ?", id).Limit(1).Find(&delivery)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+	if err := h.webhooks.Redeliver(&delivery); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Delivery scheduled", "delivery_id": delivery.ID})
+}
@@ -0,0 +1,268 @@
+package backup
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"treehole/internal/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 备份状态
+const (
+	StatusRunning = "running"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// TriggerSchedule 定时任务执行的备份
+const TriggerSchedule = "schedule"
+
+// ErrRunning 已有备份任务在运行
+var ErrRunning = errors.New("backup is already running")
+
+// ErrUnsupported 数据库不是 SQLite
+var ErrUnsupported = errors.New("online backup requires a SQLite database")
+
+// Config 备份配置
+type Config struct {
+	Dir    string        // 备份目录
+	Keep   int           // 保留的备份数量，0 表示不限
+	MaxAge time.Duration // 备份保留时间，0 表示不限
+}
+
+// Service 数据库备份服务
+// 用 VACUUM INTO 在线生成一致的快照，校验完整性后压缩保存，并按数量和时间轮换
+type Service struct {
+	db     *gorm.DB
+	config Config
+
+	running sync.Mutex
+}
+
+// NewService 创建备份服务
+func NewService(db *gorm.DB, cfg Config) *Service {
+	return &Service{db: db, config: cfg}
+}
+
+// Config 返回备份配置
+func (s *Service) Config() Config {
+	return s.config
+}
+
+// Run 执行一次备份，成功后轮换旧备份
+// 失败的备份同样留下记录，返回的记录包含失败原因
+func (s *Service) Run(triggeredBy string) (*models.Backup, error) {
+	if s.db.Dialector.Name() != "sqlite" {
+		return nil, ErrUnsupported
+	}
+	if !s.running.TryLock() {
+		return nil, ErrRunning
+	}
+	defer s.running.Unlock()
+
+	// 进程在备份途中退出时留下的记录不会再完成
+	if err := s.db.Model(&models.Backup{}).Where("status = ?", StatusRunning).Updates(map[string]interface{}{
+		"status": StatusFailed,
+		"error":  "interrupted",
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	record := &models.Backup{
+		TriggeredBy: triggeredBy,
+		Status:      StatusRunning,
+		StartedAt:   started,
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		return nil, err
+	}
+	record.FileName = fmt.Sprintf("treehole-%s-%d.db.gz", started.Format("20060102-150405"), record.ID)
+
+	err := s.write(record)
+	finished := time.Now()
+	record.FinishedAt = &finished
+	record.Status = StatusSuccess
+	if err != nil {
+		record.Status = StatusFailed
+		record.Error = err.Error()
+	}
+	if saveErr := s.db.Save(record).Error; saveErr != nil {
+		return record, saveErr
+	}
+	if err != nil {
+		return record, err
+	}
+
+	if removed, err := s.Rotate(); err != nil {
+		log.Printf("Backup rotation failed: %v", err)
+	} else if removed > 0 {
+		log.Printf("Backup rotation removed %d old backups", removed)
+	}
+	return record, nil
+}
+
+// RunJob 定时任务入口
+func (s *Service) RunJob() {
+	record, err := s.Run(TriggerSchedule)
+	if errors.Is(err, ErrRunning) {
+		log.Println("Previous backup is still running, skipping this execution")
+		return
+	}
+	if err != nil {
+		log.Printf("Database backup failed: %v", err)
+		return
+	}
+	log.Printf("Database backup %s finished: %d bytes, %d compressed", record.FileName, record.Size, record.CompressedSize)
+}
+
+// write 生成快照、校验并压缩，出错时清理产生的文件
+// 备份包含完整的数据库，目录和文件只允许运行服务的用户访问
+func (s *Service) write(record *models.Backup) error {
+	if err := os.MkdirAll(s.config.Dir, 0700); err != nil {
+		return err
+	}
+	snapshot := filepath.Join(s.config.Dir, strings.TrimSuffix(record.FileName, ".gz"))
+	target := filepath.Join(s.config.Dir, record.FileName)
+	partial := target + ".part"
+	defer os.Remove(snapshot)
+
+	// VACUUM INTO 允许写入已存在的空文件，预先创建以限制快照的权限
+	os.Remove(snapshot)
+	file, err := os.OpenFile(snapshot, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	file.Close()
+	if err := s.db.Exec("VACUUM INTO ?", snapshot).Error; err != nil {
+		return fmt.Errorf("vacuum into: %w", err)
+	}
+	info, err := os.Stat(snapshot)
+	if err != nil {
+		return err
+	}
+	record.Size = info.Size()
+
+	record.Integrity, err = integrityCheck(snapshot)
+	if err != nil {
+		return fmt.Errorf("integrity check: %w", err)
+	}
+	if record.Integrity != "ok" {
+		return fmt.Errorf("integrity check failed: %s", record.Integrity)
+	}
+
+	size, sum, err := compress(snapshot, partial)
+	if err != nil {
+		os.Remove(partial)
+		return fmt.Errorf("compress: %w", err)
+	}
+	if err := os.Rename(partial, target); err != nil {
+		os.Remove(partial)
+		return err
+	}
+	record.CompressedSize = size
+	record.SHA256 = sum
+	return nil
+}
+
+// integrityCheck 在快照上执行 PRAGMA integrity_check，正常时返回 ok
+func integrityCheck(path string) (string, error) {
+	db, err := gorm.Open(sqlite.Dialector{DriverName: "sqlite", DSN: path}, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return "", err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return "", err
+	}
+	defer sqlDB.Close()
+
+	var results []string
+	if err := db.Raw("PRAGMA integrity_check").Scan(&results).Error; err != nil {
+		return "", err
+	}
+	return strings.Join(results, "\n"), nil
+}
+
+// compress 以 gzip 压缩文件，返回压缩后的大小和 SHA-256
+func compress(src, dst string) (int64, string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, "", err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, "", err
+	}
+	defer out.Close()
+
+	hash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(out, hash)}
+	zw := gzip.NewWriter(counter)
+	zw.Name = strings.TrimSuffix(filepath.Base(dst), ".gz.part")
+	if _, err := io.Copy(zw, in); err != nil {
+		return 0, "", err
+	}
+	if err := zw.Close(); err != nil {
+		return 0, "", err
+	}
+	if err := out.Sync(); err != nil {
+		return 0, "", err
+	}
+	return counter.n, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// countingWriter 统计写入的字节数
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Rotate 删除超出保留数量或保留时间的备份文件，最新的一份成功备份始终保留
+func (s *Service) Rotate() (int, error) {
+	var backups []models.Backup
+	if err := s.db.Where("status = ? AND removed_at IS NULL", StatusSuccess).Order("id desc").Find(&backups).Error; err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	removed := 0
+	for i, backup := range backups {
+		if i == 0 {
+			continue
+		}
+		expired := s.config.MaxAge > 0 && now.Sub(backup.StartedAt) > s.config.MaxAge
+		if !expired && (s.config.Keep <= 0 || i < s.config.Keep) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.config.Dir, backup.FileName)); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		if err := s.db.Model(&models.Backup{}).Where("id = ?", backup.ID).Update("removed_at", now).Error; err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
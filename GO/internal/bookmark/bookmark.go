@@ -0,0 +1,170 @@
+package bookmark
+
+import (
+	"errors"
+	"time"
+	"treehole/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// DefaultCollectionName 未指定收藏夹时使用的收藏夹名称
+const DefaultCollectionName = "默认收藏夹"
+
+// 收藏的数量限制
+const (
+	MaxCollections            = 50
+	MaxBookmarksPerCollection = 1000
+)
+
+// ErrCollectionFull 收藏夹已满
+var ErrCollectionFull = errors.New("collection is full")
+
+// deletedState 主站删除的帖子在 state 字段中的值
+const deletedState = "deleted"
+
+// Item 收藏夹中的一条收藏及帖子内容
+// 帖子被主站删除时从存档读取（archived 为 true），被管理员隐藏时不返回内容（available 为 false）
+type Item struct {
+	models.Bookmark
+	Available bool         `json:"available"`
+	Archived  bool         `json:"archived"`
+	Post      *models.Post `json:"post,omitempty"`
+}
+
+// Archive 保存帖子内容的存档，已有存档时覆盖
+func Archive(db *gorm.DB, post *models.Post) error {
+	archive := models.PostArchive{
+		PostID:        post.ID,
+		OriginalID:    post.OriginalID,
+		Title:         post.Title,
+		Content:       post.Content,
+		Author:        post.Author,
+		RadioGroup:    post.RadioGroup,
+		Tag:           post.Tag,
+		Images:        post.Images,
+		Cover:         post.Cover,
+		PostCreatedAt: post.CreatedAt,
+		ArchivedAt:    time.Now(),
+	}
+	result := db.Model(&models.PostArchive{}).Where("post_id = ?", post.ID).Updates(map[string]interface{}{
+		"original_id":     archive.OriginalID,
+		"title":           archive.Title,
+		"content":         archive.Content,
+		"author":          archive.Author,
+		"radio_group":     archive.RadioGroup,
+		"tag":             archive.Tag,
+		"images":          archive.Images,
+		"cover":           archive.Cover,
+		"post_created_at": archive.PostCreatedAt,
+		"archived_at":     archive.ArchivedAt,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil
+	}
+	return db.Create(&archive).Error
+}
+
+// Refresh 同步更新帖子后刷新被收藏帖子的存档
+// 主站已删除的帖子保留删除前的存档，不用删除后的内容覆盖
+func Refresh(db *gorm.DB, post *models.Post) error {
+	if post.State == deletedState {
+		return nil
+	}
+	var count int64
+	if err := db.Model(&models.Bookmark{}).Where("post_id = ?", post.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+	return Archive(db, post)
+}
+
+// Protected 被收藏的帖子ID子查询，清理帖子时用于排除这些帖子
+func Protected(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Bookmark{}).Select("DISTINCT post_id")
+}
+
+// Remove 删除帖子的所有收藏和存档，帖子被管理员永久删除时调用
+func Remove(tx *gorm.DB, postID uint) error {
+	if err := tx.Where("post_id = ?", postID).Delete(&models.Bookmark{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("post_id = ?", postID).Delete(&models.PostArchive{}).Error
+}
+
+// Resolve 读取收藏对应的帖子内容，未隐藏且未被主站删除的帖子返回当前内容，否则使用存档
+func Resolve(db *gorm.DB, bookmarks []models.Bookmark) ([]Item, error) {
+	items := make([]Item, len(bookmarks))
+	if len(bookmarks) == 0 {
+		return items, nil
+	}
+	postIDs := make([]uint, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		postIDs = append(postIDs, bookmark.PostID)
+	}
+
+	var posts []models.Post
+	if err := db.Unscoped().Where("id IN ?", postIDs).Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	postByID := make(map[uint]*models.Post, len(posts))
+	for i := range posts {
+		postByID[posts[i].ID] = &posts[i]
+	}
+	var archives []models.PostArchive
+	if err := db.Where("post_id IN ?", postIDs).Find(&archives).Error; err != nil {
+		return nil, err
+	}
+	archiveByID := make(map[uint]*models.PostArchive, len(archives))
+	for i := range archives {
+		archiveByID[archives[i].PostID] = &archives[i]
+	}
+
+	for i, bookmark := range bookmarks {
+		items[i].Bookmark = bookmark
+		post, found := postByID[bookmark.PostID]
+		switch {
+		case found && post.DeletedAt.Valid:
+			// 管理员隐藏的帖子不从存档提供
+		case found && post.State != deletedState:
+			items[i].Available = true
+			items[i].Post = post
+		case archiveByID[bookmark.PostID] != nil:
+			items[i].Available = true
+			items[i].Archived = true
+			items[i].Post = fromArchive(archiveByID[bookmark.PostID], post)
+		}
+	}
+	return items, nil
+}
+
+// fromArchive 由存档还原帖子，帖子记录仍在时使用当前的计数
+func fromArchive(archive *models.PostArchive, current *models.Post) *models.Post {
+	post := &models.Post{
+		ID:         archive.PostID,
+		OriginalID: archive.OriginalID,
+		Title:      archive.Title,
+		Content:    archive.Content,
+		Author:     archive.Author,
+		RadioGroup: archive.RadioGroup,
+		Tag:        archive.Tag,
+		Images:     archive.Images,
+		Cover:      archive.Cover,
+		State:      deletedState,
+		CreatedAt:  archive.PostCreatedAt,
+		UpdatedAt:  archive.ArchivedAt,
+	}
+	if current != nil {
+		post.LikeNum = current.LikeNum
+		post.LocalLikeNum = current.LocalLikeNum
+		post.ReplyCount = current.ReplyCount
+		post.ViewCount = current.ViewCount
+		post.CampusGroup = current.CampusGroup
+	}
+	return post
+}
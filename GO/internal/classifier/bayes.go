@@ -0,0 +1,113 @@
+package classifier
+
+import (
+	"math"
+	"sort"
+)
+
+// Sample 朴素贝叶斯的训练样本
+type Sample struct {
+	Text string
+	Tags []string
+}
+
+// Prediction 单个标签的预测结果
+type Prediction struct {
+	Tag         string  `json:"tag"`
+	Probability float64 `json:"probability"`
+}
+
+// Bayes 多项式朴素贝叶斯模型
+// 多标签样本对每个标签各计一次，预测时对各标签的后验概率归一化
+type Bayes struct {
+	docs       int
+	tagDocs    map[string]int
+	tagTokens  map[string]map[string]int
+	tagTotals  map[string]int
+	vocabulary map[string]struct{}
+}
+
+// TrainBayes 使用已标注的样本训练模型
+func TrainBayes(samples []Sample) *Bayes {
+	b := &Bayes{
+		tagDocs:    make(map[string]int),
+		tagTokens:  make(map[string]map[string]int),
+		tagTotals:  make(map[string]int),
+		vocabulary: make(map[string]struct{}),
+	}
+
+	for _, sample := range samples {
+		if len(sample.Tags) == 0 {
+			continue
+		}
+		tokens := Tokenize(sample.Text)
+		b.docs++
+		for _, tag := range sample.Tags {
+			b.tagDocs[tag]++
+			counts := b.tagTokens[tag]
+			if counts == nil {
+				counts = make(map[string]int)
+				b.tagTokens[tag] = counts
+			}
+			for _, token := range tokens {
+				counts[token]++
+				b.tagTotals[tag]++
+				b.vocabulary[token] = struct{}{}
+			}
+		}
+	}
+
+	return b
+}
+
+// Tags 模型中的标签数量
+func (b *Bayes) Tags() int {
+	return len(b.tagDocs)
+}
+
+// Predict 返回按概率从高到低排列的标签
+func (b *Bayes) Predict(text string) []Prediction {
+	if b == nil || len(b.tagDocs) == 0 {
+		return nil
+	}
+
+	tokens := Tokenize(text)
+	vocabulary := float64(len(b.vocabulary))
+
+	predictions := make([]Prediction, 0, len(b.tagDocs))
+	maxScore := math.Inf(-1)
+	for tag, docs := range b.tagDocs {
+		// 先验概率加拉普拉斯平滑后的似然
+		score := math.Log(float64(docs) / float64(b.docs))
+		counts := b.tagTokens[tag]
+		total := float64(b.tagTotals[tag])
+		for _, token := range tokens {
+			if _, ok := b.vocabulary[token]; !ok {
+				continue
+			}
+			score += math.Log((float64(counts[token]) + 1) / (total + vocabulary))
+		}
+		predictions = append(predictions, Prediction{Tag: tag, Probability: score})
+		if score > maxScore {
+			maxScore = score
+		}
+	}
+
+	// 对数得分转换为归一化的概率
+	sum := 0.0
+	for i := range predictions {
+		predictions[i].Probability = math.Exp(predictions[i].Probability - maxScore)
+		sum += predictions[i].Probability
+	}
+	for i := range predictions {
+		predictions[i].Probability /= sum
+	}
+
+	sort.Slice(predictions, func(i, j int) bool {
+		if predictions[i].Probability != predictions[j].Probability {
+			return predictions[i].Probability > predictions[j].Probability
+		}
+		return predictions[i].Tag < predictions[j].Tag
+	})
+	return predictions
+}
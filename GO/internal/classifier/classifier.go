@@ -0,0 +1,365 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"treehole/internal/database"
+	"treehole/internal/models"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// 标签占位符
+const (
+	Untagged    = models.UntaggedTag // 尚未分类
+	FallbackTag = "其他"               // 没有命中任何规则
+)
+
+// 标签来源，人工标注的标签不会被自动分类覆盖
+const (
+	SourceAuto   = "auto"
+	SourceManual = "manual"
+)
+
+// MaxTags 每条内容最多的标签数量
+const MaxTags = 3
+
+// tagSeparator 多个标签在 tag 字段中的分隔符
+const tagSeparator = ","
+
+// maxTagLength 单个标签的最大长度（按字符计）
+const maxTagLength = 16
+
+// ErrRunning 已有分类任务在运行
+var ErrRunning = errors.New("classifier job is already running")
+
+// Config 分类配置
+type Config struct {
+	RulesFile       string  // 关键词规则文件，为空时使用内置规则
+	BatchSize       int     // 每批处理的记录数
+	BayesEnabled    bool    // 是否启用朴素贝叶斯模型
+	BayesMinSamples int     // 训练模型所需的最少人工标注样本数
+	BayesThreshold  float64 // 采用模型预测结果的最低概率
+}
+
+// RunResult 一次分类任务的统计
+type RunResult struct {
+	Posts        int `json:"posts"`
+	Replies      int `json:"replies"`
+	TrainSamples int `json:"train_samples"`
+}
+
+// Service 本地标签分类服务
+// 关键词规则和朴素贝叶斯模型的结果合并为多个标签，后台任务处理未分类的帖子和回复
+type Service struct {
+	db     *gorm.DB
+	config Config
+
+	mutex sync.RWMutex
+	rules []Rule
+	bayes *Bayes
+
+	running sync.Mutex
+}
+
+// NewService 创建分类服务并加载规则
+func NewService(db *gorm.DB, cfg Config) *Service {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 200
+	}
+	s := &Service{db: db, config: cfg, rules: DefaultRules}
+	if count, err := s.Reload(); err != nil {
+		log.Printf("Failed to load classifier rules, using built-in rules: %v", err)
+	} else {
+		log.Printf("Classifier rules loaded: %d tags", count)
+	}
+	return s
+}
+
+// Reload 重新加载规则文件，加载失败时保留原有规则
+func (s *Service) Reload() (int, error) {
+	rules, err := LoadRules(s.config.RulesFile)
+	if err != nil {
+		return 0, err
+	}
+	s.mutex.Lock()
+	s.rules = rules
+	s.mutex.Unlock()
+	return len(rules), nil
+}
+
+// Classify 对标题和正文分类，返回最多 MaxTags 个标签，没有命中时返回 FallbackTag
+func (s *Service) Classify(title, content string) []string {
+	s.mutex.RLock()
+	rules, bayes := s.rules, s.bayes
+	s.mutex.RUnlock()
+
+	tags := matchRules(rules, title, content)
+	if bayes != nil {
+		for _, prediction := range bayes.Predict(title + "\n" + content) {
+			if prediction.Probability < s.config.BayesThreshold {
+				break
+			}
+			tags = appendUnique(tags, prediction.Tag)
+		}
+	}
+
+	if len(tags) == 0 {
+		return []string{FallbackTag}
+	}
+	if len(tags) > MaxTags {
+		tags = tags[:MaxTags]
+	}
+	return tags
+}
+
+// Train 使用人工标注的帖子和回复训练朴素贝叶斯模型
+// 样本数不足或标签少于两个时不启用模型
+func (s *Service) Train() (int, error) {
+	var samples []Sample
+
+	var posts []models.Post
+	if err := s.db.Unscoped().Select("title, content, tag").
+		Where("tag_source = ?", SourceManual).Find(&posts).Error; err != nil {
+		return 0, err
+	}
+	for _, post := range posts {
+		samples = append(samples, Sample{Text: post.Title + "\n" + post.Content, Tags: SplitTags(post.Tag)})
+	}
+
+	var replies []models.Reply
+	if err := s.db.Unscoped().Select("content, tag").
+		Where("tag_source = ?", SourceManual).Find(&replies).Error; err != nil {
+		return 0, err
+	}
+	for _, reply := range replies {
+		samples = append(samples, Sample{Text: reply.Content, Tags: SplitTags(reply.Tag)})
+	}
+
+	var bayes *Bayes
+	if len(samples) >= s.config.BayesMinSamples {
+		if model := TrainBayes(samples); model.Tags() >= 2 {
+			bayes = model
+		}
+	}
+
+	s.mutex.Lock()
+	s.bayes = bayes
+	s.mutex.Unlock()
+
+	if bayes == nil {
+		return 0, nil
+	}
+	return len(samples), nil
+}
+
+// Run 分类所有未分类的帖子和回复，同一时间只允许一个任务运行
+func (s *Service) Run() (*RunResult, error) {
+	if !s.running.TryLock() {
+		return nil, ErrRunning
+	}
+	defer s.running.Unlock()
+
+	result := &RunResult{}
+	if s.config.BayesEnabled {
+		samples, err := s.Train()
+		if err != nil {
+			return nil, fmt.Errorf("failed to train classifier: %v", err)
+		}
+		result.TrainSamples = samples
+	}
+
+	posts, err := s.classifyPosts()
+	result.Posts = posts
+	if err != nil {
+		return result, err
+	}
+	replies, err := s.classifyReplies()
+	result.Replies = replies
+	return result, err
+}
+
+// RunJob 供定时任务调用的分类任务
+func (s *Service) RunJob() {
+	result, err := s.Run()
+	if errors.Is(err, ErrRunning) {
+		log.Println("Previous classifier job is still running, skipping this execution")
+		return
+	}
+	if err != nil {
+		log.Printf("Classifier job failed: %v", err)
+		return
+	}
+	if result.Posts > 0 || result.Replies > 0 {
+		log.Printf("Classifier tagged %d posts and %d replies", result.Posts, result.Replies)
+	}
+}
+
+// classifyPosts 分批处理未分类的帖子（包括已隐藏的帖子）
+func (s *Service) classifyPosts() (int, error) {
+	ix, err := LoadTagIndex(s.db)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	var lastID uint
+	for {
+		var posts []models.Post
+		if err := untagged(s.db.Unscoped().Select("id, title, content"), lastID).
+			Limit(s.config.BatchSize).Find(&posts).Error; err != nil {
+			return count, err
+		}
+		if len(posts) == 0 {
+			return count, nil
+		}
+		for _, post := range posts {
+			lastID = post.ID
+			if err := s.applyPost(ix, post.ID, s.Classify(post.Title, post.Content)); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+}
+
+// classifyReplies 分批处理未分类的回复（包括已隐藏的回复）
+func (s *Service) classifyReplies() (int, error) {
+	count := 0
+	var lastID uint
+	for {
+		var replies []models.Reply
+		if err := untagged(s.db.Unscoped().Select("id, content"), lastID).
+			Limit(s.config.BatchSize).Find(&replies).Error; err != nil {
+			return count, err
+		}
+		if len(replies) == 0 {
+			return count, nil
+		}
+		for _, reply := range replies {
+			lastID = reply.ID
+			if err := s.applyReply(reply.ID, s.Classify("", reply.Content)); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+}
+
+// untagged 未分类且非人工标注的记录，按 ID 顺序分批
+func untagged(query *gorm.DB, afterID uint) *gorm.DB {
+	return query.
+		Where("(tag = ? OR tag = '' OR tag IS NULL)", Untagged).
+		Where("(tag_source IS NULL OR tag_source <> ?)", SourceManual).
+		Where("id > ?", afterID).
+		Order("id asc")
+}
+
+// notManual 写入自动分类结果前再次确认没有被人工标注
+func notManual(tx *gorm.DB, id uint) *gorm.DB {
+	return tx.Unscoped().Where("id = ? AND (tag_source IS NULL OR tag_source <> ?)", id, SourceManual)
+}
+
+// applyPost 写入帖子的自动分类结果并更新标签关联，不修改更新时间
+func (s *Service) applyPost(ix *TagIndex, id uint, tags []string) error {
+	return database.SafeTransaction(s.db, func(tx *gorm.DB) error {
+		var count int64
+		if err := notManual(tx.Model(&models.Post{}), id).Count(&count).Error; err != nil || count == 0 {
+			return err
+		}
+		names, err := SetPostTags(tx, ix, id, tags)
+		if err != nil {
+			return err
+		}
+		return notManual(tx.Model(&models.Post{}), id).UpdateColumns(map[string]interface{}{
+			"tag":        JoinTags(names),
+			"tag_source": SourceAuto,
+		}).Error
+	})
+}
+
+// applyReply 写入回复的自动分类结果，不修改更新时间
+func (s *Service) applyReply(id uint, tags []string) error {
+	return notManual(s.db.Model(&models.Reply{}), id).UpdateColumns(map[string]interface{}{
+		"tag":        JoinTags(tags),
+		"tag_source": SourceAuto,
+	}).Error
+}
+
+// TagUpdates 人工设置标签时需要更新的字段
+// 标签为空时清除人工标注，交由自动分类重新处理
+func TagUpdates(tags []string) map[string]interface{} {
+	if len(tags) == 0 {
+		return map[string]interface{}{"tag": Untagged, "tag_source": ""}
+	}
+	return map[string]interface{}{"tag": JoinTags(tags), "tag_source": SourceManual}
+}
+
+// NormalizeTags 校验并去重标签列表
+func NormalizeTags(tags []string) ([]string, error) {
+	var result []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if err := ValidateTag(tag); err != nil {
+			return nil, err
+		}
+		result = appendUnique(result, tag)
+	}
+	if len(result) > MaxTags {
+		return nil, fmt.Errorf("at most %d tags allowed", MaxTags)
+	}
+	return result, nil
+}
+
+// ValidateTag 检查标签是否合法
+func ValidateTag(tag string) error {
+	switch {
+	case tag == "":
+		return errors.New("tag cannot be empty")
+	case tag == Untagged:
+		return fmt.Errorf("tag %q is reserved", tag)
+	case strings.ContainsAny(tag, tagSeparator+"，"):
+		return fmt.Errorf("tag %q cannot contain commas", tag)
+	case utf8.RuneCountInString(tag) > maxTagLength:
+		return fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLength)
+	}
+	return nil
+}
+
+// JoinTags 将标签列表合并为 tag 字段的值
+func JoinTags(tags []string) string {
+	return strings.Join(tags, tagSeparator)
+}
+
+// SplitTags 拆分 tag 字段中的多个标签，占位符不视为标签
+func SplitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, tagSeparator) {
+		if tag = strings.TrimSpace(tag); tag != "" && tag != Untagged {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// appendUnique 追加不重复的标签
+func appendUnique(tags []string, tag string) []string {
+	if containsTag(tags, tag) {
+		return tags
+	}
+	return append(tags, tag)
+}
+
+// containsTag 检查标签列表中是否已包含指定标签
+func containsTag(tags []string, tag string) bool {
+	for _, existing := range tags {
+		if existing == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package classifier
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Rule 关键词规则，命中任一关键词即为该标签计分
+type Rule struct {
+	Tag      string   `json:"tag"`
+	Keywords []string `json:"keywords"`
+}
+
+// 标题中命中的关键词权重高于正文
+const (
+	titleWeight   = 2
+	contentWeight = 1
+)
+
+// DefaultRules 内置的树洞常见分类规则，未配置规则文件时使用
+var DefaultRules = []Rule{
+	{"表白", []string{"表白", "喜欢你", "暗恋", "心动", "捞人", "一见钟情", "小姐姐", "小哥哥"}},
+	{"情感", []string{"分手", "失恋", "对象", "男朋友", "女朋友", "男友", "女友", "恋爱", "前任", "emo"}},
+	{"失物招领", []string{"失物", "招领", "寻物", "捡到", "丢了", "丢失", "遗失", "校园卡", "饭卡", "钥匙"}},
+	{"二手交易", []string{"出售", "转让", "二手", "闲置", "求购", "收购", "低价", "包邮", "出一个", "出个"}},
+	{"求助", []string{"求助", "请问", "有没有人", "怎么办", "求推荐", "求问", "蹲一个", "求解答"}},
+	{"学习", []string{"考试", "期末", "考研", "作业", "绩点", "选课", "论文", "图书馆", "四六级", "保研", "复习"}},
+	{"找搭子", []string{"拼车", "组队", "搭子", "约饭", "一起去", "找人一起", "开黑", "拼单"}},
+	{"兼职招聘", []string{"兼职", "招聘", "实习", "日结", "内推", "招人"}},
+	{"校园生活", []string{"食堂", "宿舍", "快递", "外卖", "洗澡", "空调", "澡堂", "宿管", "校车"}},
+	{"吐槽", []string{"吐槽", "无语", "离谱", "气死", "恶心", "服了", "避雷", "破防"}},
+}
+
+// LoadRules 读取规则文件，每行格式为 "标签: 关键词1, 关键词2"，# 开头为注释
+// 路径为空时返回内置规则
+func LoadRules(path string) ([]Rule, error) {
+	if path == "" {
+		return DefaultRules, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rules []Rule
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(strings.Replace(line, "：", ":", 1), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected \"tag: keyword, ...\"", path, lineNo)
+		}
+		tag := strings.TrimSpace(parts[0])
+		if err := ValidateTag(tag); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+		}
+
+		var keywords []string
+		for _, keyword := range strings.FieldsFunc(parts[1], func(r rune) bool { return r == ',' || r == '，' }) {
+			if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+				keywords = append(keywords, keyword)
+			}
+		}
+		if len(keywords) == 0 {
+			continue
+		}
+
+		// 同一标签出现多行时合并关键词
+		if i, ok := index[tag]; ok {
+			rules[i].Keywords = append(rules[i].Keywords, keywords...)
+			continue
+		}
+		index[tag] = len(rules)
+		rules = append(rules, Rule{Tag: tag, Keywords: keywords})
+	}
+	return rules, scanner.Err()
+}
+
+// matchRules 按关键词规则给各标签打分，返回得分从高到低的标签
+func matchRules(rules []Rule, title, content string) []string {
+	title = strings.ToLower(title)
+	content = strings.ToLower(content)
+
+	scores := make(map[string]int)
+	for _, rule := range rules {
+		for _, keyword := range rule.Keywords {
+			if strings.Contains(title, keyword) {
+				scores[rule.Tag] += titleWeight
+			}
+			if strings.Contains(content, keyword) {
+				scores[rule.Tag] += contentWeight
+			}
+		}
+	}
+
+	tags := make([]string, 0, len(scores))
+	for tag := range scores {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if scores[tags[i]] != scores[tags[j]] {
+			return scores[tags[i]] > scores[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+	return tags
+}
@@ -0,0 +1,179 @@
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"treehole/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// TagIndex 标签索引，按名称或别名查找标签并展开子标签
+type TagIndex struct {
+	tags     []*models.Tag
+	byKey    map[string]*models.Tag // 小写的名称和别名
+	byID     map[uint]*models.Tag
+	children map[uint][]uint
+}
+
+// LoadTagIndex 加载全部标签
+func LoadTagIndex(db *gorm.DB) (*TagIndex, error) {
+	var tags []models.Tag
+	if err := db.Order("id asc").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	ix := &TagIndex{
+		byKey:    make(map[string]*models.Tag),
+		byID:     make(map[uint]*models.Tag),
+		children: make(map[uint][]uint),
+	}
+	for i := range tags {
+		ix.add(&tags[i])
+	}
+	return ix, nil
+}
+
+// add 将标签加入索引，名称优先于其他标签的别名
+func (ix *TagIndex) add(tag *models.Tag) {
+	ix.tags = append(ix.tags, tag)
+	ix.byID[tag.ID] = tag
+	ix.byKey[strings.ToLower(tag.Name)] = tag
+	for _, alias := range SplitAliases(tag.Aliases) {
+		key := strings.ToLower(alias)
+		if _, exists := ix.byKey[key]; !exists {
+			ix.byKey[key] = tag
+		}
+	}
+	if tag.ParentID != nil {
+		ix.children[*tag.ParentID] = append(ix.children[*tag.ParentID], tag.ID)
+	}
+}
+
+// Tags 按创建顺序返回全部标签
+func (ix *TagIndex) Tags() []*models.Tag {
+	return ix.tags
+}
+
+// Lookup 按名称或别名查找标签，不区分大小写
+func (ix *TagIndex) Lookup(name string) (*models.Tag, bool) {
+	tag, ok := ix.byKey[strings.ToLower(strings.TrimSpace(name))]
+	return tag, ok
+}
+
+// Get 按 ID 查找标签
+func (ix *TagIndex) Get(id uint) (*models.Tag, bool) {
+	tag, ok := ix.byID[id]
+	return tag, ok
+}
+
+// Children 直接子标签的 ID
+func (ix *TagIndex) Children(id uint) []uint {
+	return ix.children[id]
+}
+
+// Descendants 标签自身及其所有子孙标签的 ID
+func (ix *TagIndex) Descendants(id uint) []uint {
+	ids := []uint{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, ix.children[ids[i]]...)
+	}
+	return ids
+}
+
+// ValidateDefinition 校验新建或修改的标签，id 为 0 表示新建
+// 名称和别名不能与其他标签冲突，父标签必须存在且不能形成环
+func (ix *TagIndex) ValidateDefinition(id uint, name string, aliases []string, parentID *uint) error {
+	keys := append([]string{name}, aliases...)
+	for _, key := range keys {
+		if err := ValidateTag(key); err != nil {
+			return err
+		}
+		if existing, ok := ix.Lookup(key); ok && existing.ID != id {
+			return fmt.Errorf("%q is already used by tag %q", key, existing.Name)
+		}
+	}
+
+	if parentID == nil {
+		return nil
+	}
+	if _, ok := ix.Get(*parentID); !ok {
+		return fmt.Errorf("parent tag %d not found", *parentID)
+	}
+	if id != 0 {
+		for _, descendant := range ix.Descendants(id) {
+			if descendant == *parentID {
+				return errors.New("parent tag cannot be the tag itself or one of its children")
+			}
+		}
+	}
+	return nil
+}
+
+// SetPostTags 用给定标签替换帖子的标签关联
+// 别名解析为对应的标签，不存在的标签会自动创建，返回去重后的规范标签名
+func SetPostTags(tx *gorm.DB, ix *TagIndex, postID uint, names []string) ([]string, error) {
+	var canonical []string
+	var tagIDs []uint
+	for _, name := range names {
+		tag, ok := ix.Lookup(name)
+		if !ok {
+			tag = &models.Tag{Name: strings.TrimSpace(name)}
+			if err := tx.Where("name = ?", tag.Name).FirstOrCreate(tag).Error; err != nil {
+				return nil, err
+			}
+			ix.add(tag)
+		}
+		if containsTag(canonical, tag.Name) {
+			continue
+		}
+		canonical = append(canonical, tag.Name)
+		tagIDs = append(tagIDs, tag.ID)
+	}
+
+	if err := tx.Where("post_id = ?", postID).Delete(&models.PostTag{}).Error; err != nil {
+		return nil, err
+	}
+	for _, tagID := range tagIDs {
+		if err := tx.Create(&models.PostTag{PostID: postID, TagID: tagID}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return canonical, nil
+}
+
+// RefreshPostTags 标签改名或删除后，根据关联表重新生成帖子的 tag 字段
+// 没有剩余标签的帖子恢复为未分类，交由自动分类重新处理
+func RefreshPostTags(tx *gorm.DB, postIDs []uint) error {
+	for _, postID := range postIDs {
+		var names []string
+		if err := tx.Table("post_tags").
+			Joins("JOIN tags ON tags.id = post_tags.tag_id").
+			Where("post_tags.post_id = ?", postID).
+			Order("post_tags.id asc").
+			Pluck("tags.name", &names).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"tag": JoinTags(names)}
+		if len(names) == 0 {
+			updates = map[string]interface{}{"tag": Untagged, "tag_source": ""}
+		}
+		if err := tx.Unscoped().Model(&models.Post{}).Where("id = ?", postID).UpdateColumns(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SplitAliases 拆分别名字段
+func SplitAliases(aliases string) []string {
+	var result []string
+	for _, alias := range strings.Split(aliases, tagSeparator) {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			result = append(result, alias)
+		}
+	}
+	return result
+}
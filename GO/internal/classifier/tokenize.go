@@ -0,0 +1,48 @@
+package classifier
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokenize 将文本切分为特征词
+// 中文按相邻两字切分（单字片段保留单字），英文和数字按连续字符切分并转为小写
+func Tokenize(text string) []string {
+	var tokens []string
+	var han []rune
+	var word []rune
+
+	flushHan := func() {
+		if len(han) == 1 {
+			tokens = append(tokens, string(han))
+		}
+		for i := 0; i+1 < len(han); i++ {
+			tokens = append(tokens, string(han[i:i+2]))
+		}
+		han = han[:0]
+	}
+	flushWord := func() {
+		if len(word) > 1 {
+			tokens = append(tokens, strings.ToLower(string(word)))
+		}
+		word = word[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, r)
+		default:
+			flushHan()
+			flushWord()
+		}
+	}
+	flushHan()
+	flushWord()
+
+	return tokens
+}
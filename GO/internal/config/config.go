@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -20,6 +21,78 @@ type Config struct {
 	// 隐私发帖配置
 	ProxyEnabled         bool
 	ProxyURL             string
+	// 匿名身份配置
+	IdentitySecret       string
+	// 图片上传配置
+	MediaDir             string
+	MediaMaxBytes        int64
+	MediaMaxDimension    int
+	MediaMaxPixels       int64 // 单张图片解码的像素总数上限，动图按帧累计
+	MediaMaxFrames       int
+	MediaMaxFiles        int
+	PublicBaseURL        string // 同步到主站时用于拼接图片的绝对地址
+	// 管理后台配置
+	AdminAPIKeyHashes    []string // 管理员 API 密钥的 SHA-256 哈希
+	AdminSessionTTL      time.Duration
+	// CSRF 防护配置
+	CSRFProtection       bool
+	CSRFSecret           string // 开启防护时必填，不能与 IDENTITY_SECRET 相同
+	CSRFTokenTTL         time.Duration
+	// 请求限流配置
+	RateLimitEnabled     bool
+	RateLimitRead        string   // 未匹配路由规则的读请求策略，格式为 LIMIT/WINDOW[/BURST]
+	RateLimitWrite       string   // 未匹配路由规则的写请求策略
+	RateLimitRoutes      string   // 以分号分隔的路由规则，格式为 METHODS PATH=LIMIT/WINDOW[/BURST]
+	TrustedProxies       []string // 可信反向代理的地址或网段，只有来自这些地址的 X-Forwarded-For 才被采用
+	// 举报配置
+	ReportHideThreshold  int // 来自不同 IP 的举报数达到该值时自动隐藏，0 表示不自动隐藏
+	// 内容过滤配置
+	FilterBlockWordsFile  string
+	FilterReviewWordsFile string
+	FilterDuplicateWindow time.Duration
+	FilterMaxRepeatRun    int
+	// 标签分类配置
+	ClassifierRulesFile       string
+	ClassifierCron            string
+	ClassifierBatchSize       int
+	ClassifierBayesEnabled    bool
+	ClassifierBayesMinSamples int
+	ClassifierBayesThreshold  float64
+	// 热度排行配置
+	TrendingWindows string // 逗号分隔的时间窗口，如 1h,24h,7d
+	TrendingCron    string
+	// 帖子计数快照配置
+	MetricsHourlyAfter time.Duration
+	MetricsDailyAfter  time.Duration
+	MetricsRetention   time.Duration
+	MetricsCompactCron string
+	// 统计接口缓存时间，0 表示不缓存
+	StatsCacheTTL time.Duration
+	// 相关帖子索引的增量更新周期
+	RelatedCron string
+	// 搜索建议配置
+	SuggestCron          string
+	SuggestQueryDays     int
+	SuggestMinQueryCount int
+	// 出站 Webhook 配置
+	WebhookRetryCron   string
+	WebhookMaxAttempts int
+	WebhookTimeout     time.Duration
+	// 数据库备份配置
+	BackupCron   string // 为 off 时不自动备份
+	BackupDir    string
+	BackupKeep   int           // 保留的备份数量，0 表示不限
+	BackupMaxAge time.Duration // 备份保留时间，0 表示不限
+	// 数据保留策略，时间为 0 的策略不执行
+	RetentionCron              string // 为 off 时不自动执行
+	RetentionOpenIDAfter       time.Duration
+	RetentionOpenIDMode        string // hash 或 drop
+	RetentionHashSecret        string // hash 模式必须单独配置，不使用 IDENTITY_SECRET
+	RetentionIPAfter           time.Duration
+	RetentionWechatAfter       time.Duration
+	RetentionPurgeDeletedAfter time.Duration
+	// 公开接口中敏感字段的可见策略，格式为 field=level，以逗号分隔
+	FieldVisibility string
 }
 
 // Load 加载配置
@@ -36,6 +109,78 @@ func Load() *Config {
 		// 隐私发帖配置
 		ProxyEnabled:         getEnv("PROXY_ENABLED", "false") == "true",
 		ProxyURL:             getEnv("PROXY_URL", ""),
+		// 匿名身份配置
+		IdentitySecret:       getEnv("IDENTITY_SECRET", ""),
+		// 图片上传配置
+		MediaDir:             getEnv("MEDIA_DIR", "media"),
+		MediaMaxBytes:        int64(getIntEnv("MEDIA_MAX_BYTES", 8<<20)),
+		MediaMaxDimension:    getIntEnv("MEDIA_MAX_DIMENSION", 4096),
+		MediaMaxPixels:       int64(getIntEnv("MEDIA_MAX_PIXELS", 16<<20)),
+		MediaMaxFrames:       getIntEnv("MEDIA_MAX_FRAMES", 100),
+		MediaMaxFiles:        getIntEnv("MEDIA_MAX_FILES", 9),
+		PublicBaseURL:        strings.TrimRight(getEnv("PUBLIC_BASE_URL", ""), "/"),
+		// 管理后台配置
+		AdminAPIKeyHashes:    getListEnv("ADMIN_API_KEY_HASHES"),
+		AdminSessionTTL:      getDurationEnv("ADMIN_SESSION_TTL", 12*time.Hour),
+		// CSRF 防护配置
+		CSRFProtection:       getEnv("CSRF_PROTECTION", "true") == "true",
+		CSRFSecret:           getEnv("CSRF_SECRET", ""),
+		CSRFTokenTTL:         getDurationEnv("CSRF_TOKEN_TTL", 2*time.Hour),
+		// 请求限流配置
+		RateLimitEnabled:     getEnv("RATE_LIMIT_ENABLED", "true") == "true",
+		RateLimitRead:        getEnv("RATE_LIMIT_READ", "100/1m"),
+		RateLimitWrite:       getEnv("RATE_LIMIT_WRITE", "10/1m"),
+		RateLimitRoutes:      getEnv("RATE_LIMIT_ROUTES", ""),
+		TrustedProxies:       getListEnv("TRUSTED_PROXIES"),
+		// 举报配置
+		ReportHideThreshold:  getIntEnv("REPORT_HIDE_THRESHOLD", 5),
+		// 内容过滤配置
+		FilterBlockWordsFile:  getEnv("FILTER_BLOCK_WORDS_FILE", ""),
+		FilterReviewWordsFile: getEnv("FILTER_REVIEW_WORDS_FILE", ""),
+		FilterDuplicateWindow: getDurationEnv("FILTER_DUPLICATE_WINDOW", 10*time.Minute),
+		FilterMaxRepeatRun:    getIntEnv("FILTER_MAX_REPEAT_RUN", 10),
+		// 标签分类配置
+		ClassifierRulesFile:       getEnv("CLASSIFIER_RULES_FILE", ""),
+		ClassifierCron:            getEnv("CLASSIFIER_CRON", "0 */5 * * * *"),
+		ClassifierBatchSize:       getIntEnv("CLASSIFIER_BATCH_SIZE", 200),
+		ClassifierBayesEnabled:    getEnv("CLASSIFIER_BAYES_ENABLED", "false") == "true",
+		ClassifierBayesMinSamples: getIntEnv("CLASSIFIER_BAYES_MIN_SAMPLES", 50),
+		ClassifierBayesThreshold:  getFloatEnv("CLASSIFIER_BAYES_THRESHOLD", 0.6),
+		// 热度排行配置
+		TrendingWindows: getEnv("TRENDING_WINDOWS", "1h,24h,7d"),
+		TrendingCron:    getEnv("TRENDING_CRON", "0 */10 * * * *"),
+		// 帖子计数快照配置
+		MetricsHourlyAfter: getDurationEnv("METRICS_HOURLY_AFTER", 48*time.Hour),
+		MetricsDailyAfter:  getDurationEnv("METRICS_DAILY_AFTER", 30*24*time.Hour),
+		MetricsRetention:   getDurationEnv("METRICS_RETENTION", 180*24*time.Hour),
+		MetricsCompactCron: getEnv("METRICS_COMPACT_CRON", "0 30 3 * * *"),
+		// 统计接口缓存时间
+		StatsCacheTTL: getDurationEnv("STATS_CACHE_TTL", 5*time.Minute),
+		// 相关帖子索引的增量更新周期
+		RelatedCron: getEnv("RELATED_CRON", "0 * * * * *"),
+		// 搜索建议配置
+		SuggestCron:          getEnv("SUGGEST_CRON", "0 */10 * * * *"),
+		SuggestQueryDays:     getIntEnv("SUGGEST_QUERY_DAYS", 30),
+		SuggestMinQueryCount: getIntEnv("SUGGEST_MIN_QUERY_COUNT", 3),
+		// 出站 Webhook 配置
+		WebhookRetryCron:   getEnv("WEBHOOK_RETRY_CRON", "*/30 * * * * *"),
+		WebhookMaxAttempts: getIntEnv("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookTimeout:     getDurationEnv("WEBHOOK_TIMEOUT", 10*time.Second),
+		// 数据库备份配置
+		BackupCron:   getEnv("BACKUP_CRON", "0 0 4 * * *"),
+		BackupDir:    getEnv("BACKUP_DIR", "backups"),
+		BackupKeep:   getIntEnv("BACKUP_KEEP", 7),
+		BackupMaxAge: getDurationEnv("BACKUP_MAX_AGE", 30*24*time.Hour),
+		// 数据保留策略
+		RetentionCron:              getEnv("RETENTION_CRON", "0 45 4 * * *"),
+		RetentionOpenIDAfter:       getDurationEnv("RETENTION_OPENID_AFTER", 0),
+		RetentionOpenIDMode:        getEnv("RETENTION_OPENID_MODE", "hash"),
+		RetentionHashSecret:        getEnv("RETENTION_HASH_SECRET", ""),
+		RetentionIPAfter:           getDurationEnv("RETENTION_IP_AFTER", 0),
+		RetentionWechatAfter:       getDurationEnv("RETENTION_WECHAT_AFTER", 0),
+		RetentionPurgeDeletedAfter: getDurationEnv("RETENTION_PURGE_DELETED_AFTER", 0),
+		// 敏感字段可见策略
+		FieldVisibility: getEnv("FIELD_VISIBILITY", "author_id=admin,apply_to=admin,ip=admin,wechat=masked"),
 	}
 }
 
@@ -55,6 +200,15 @@ func getIntEnv(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getFloatEnv(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
 func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
@@ -63,3 +217,13 @@ func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+func getListEnv(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,76 @@
+package csrf
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	m := NewManager("0123456789abcdef0123456789abcdef", time.Hour)
+	token, expiresAt, err := m.Issue("identity:abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Until(expiresAt) <= 0 {
+		t.Fatalf("expires_at %v is not in the future", expiresAt)
+	}
+
+	expired, _, err := NewManager("0123456789abcdef0123456789abcdef", -time.Second).Issue("identity:abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSecret, _, err := NewManager("fedcba9876543210fedcba9876543210", time.Hour).Issue("identity:abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+	extended := strconv.FormatInt(time.Now().Add(24*time.Hour).Unix(), 10) + "." + parts[1] + "." + parts[2]
+	last := "0"
+	if strings.HasSuffix(token, "0") {
+		last = "1"
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		binding string
+		want    error
+	}{
+		{"valid", token, "identity:abc", nil},
+		{"other binding", token, "identity:def", ErrInvalidToken},
+		{"empty binding", token, "", ErrInvalidToken},
+		{"admin binding", token, "admin:hash|identity:abc", ErrInvalidToken},
+		{"other secret", otherSecret, "identity:abc", ErrInvalidToken},
+		{"expired", expired, "identity:abc", ErrExpiredToken},
+		{"tampered expiry", extended, "identity:abc", ErrInvalidToken},
+		{"tampered signature", token[:len(token)-1] + last, "identity:abc", ErrInvalidToken},
+		{"missing parts", parts[0] + "." + parts[1], "identity:abc", ErrInvalidToken},
+		{"short nonce", parts[0] + ".abcd." + parts[2], "identity:abc", ErrInvalidToken},
+		{"empty", "", "identity:abc", ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := m.Verify(tt.token, tt.binding); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIssueUnique(t *testing.T) {
+	m := NewManager("0123456789abcdef0123456789abcdef", time.Hour)
+	a, _, err := m.Issue("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _, err := m.Issue("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b {
+		t.Errorf("two tokens are identical: %s", a)
+	}
+}
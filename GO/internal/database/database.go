@@ -1,6 +1,7 @@
 package database
 
 import (
+	"log"
 	"strings"
 	"time"
 	"treehole/internal/models"
@@ -88,15 +89,127 @@ func Migrate(db *gorm.DB) error {
 	
 	if count > 0 {
 		// 表已存在，执行兼容性检查和迁移
-		return migrateExistingTables(db)
+		if err := migrateExistingTables(db); err != nil {
+			return err
+		}
+	} else {
+		// 表不存在，执行标准迁移
+		if err := db.AutoMigrate(
+			&models.Post{},
+			&models.Reply{},
+			&models.SyncStatus{},
+		); err != nil {
+			return err
+		}
 	}
-	
-	// 表不存在，执行标准迁移
-	return db.AutoMigrate(
-		&models.Post{},
-		&models.Reply{},
-		&models.SyncStatus{},
-	)
+
+	// 附加功能表与核心表无历史包袱，直接 AutoMigrate
+	if err := db.AutoMigrate(auxiliaryModels...); err != nil {
+		return err
+	}
+
+	return migratePostTags(db)
+}
+
+// auxiliaryModels 附加功能使用的数据表
+var auxiliaryModels = []interface{}{
+	&models.Like{},
+	&models.Media{},
+	&models.AdminAPIKey{},
+	&models.AdminSession{},
+	&models.ModerationItem{},
+	&models.AuditLog{},
+	&models.Report{},
+	&models.Tag{},
+	&models.PostTag{},
+	&models.PostMetric{},
+	&models.TrendingPost{},
+	&models.PostFingerprint{},
+	&models.DuplicateCluster{},
+	&models.SearchQuery{},
+	&models.SearchQuerySearcher{},
+	&models.SavedSearch{},
+	&models.Notification{},
+	&models.ThreadSubscription{},
+	&models.Webhook{},
+	&models.WebhookDelivery{},
+	&models.BookmarkCollection{},
+	&models.Bookmark{},
+	&models.PostArchive{},
+	&models.Backup{},
+}
+
+// addedColumns 在核心表创建之后新增的字段，旧库迁移时逐个补齐
+var addedColumns = []struct {
+	Table      string
+	Column     string
+	Definition string
+}{
+	{"posts", "local_like_num", "INTEGER DEFAULT 0"},
+	{"replies", "local_like_num", "INTEGER DEFAULT 0"},
+	{"posts", "tag_source", "VARCHAR(16) DEFAULT ''"},
+	{"replies", "tag_source", "VARCHAR(16) DEFAULT ''"},
+	{"posts", "duplicate_of", "INTEGER"},
+}
+
+// migratePostTags 根据帖子的 tag 字段生成 tags 和 post_tags 表，只在标签表为空时执行
+func migratePostTags(db *gorm.DB) error {
+	var tagCount int64
+	if err := db.Model(&models.Tag{}).Count(&tagCount).Error; err != nil {
+		return err
+	}
+	if tagCount > 0 {
+		return nil
+	}
+
+	var values []string
+	if err := db.Unscoped().Model(&models.Post{}).
+		Where("tag != '' AND tag != ?", models.UntaggedTag).
+		Distinct().Pluck("tag", &values).Error; err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	return SafeTransaction(db, func(tx *gorm.DB) error {
+		tagIDs := make(map[string]uint)
+		for _, value := range values {
+			seen := make(map[string]bool)
+			for _, name := range strings.Split(value, ",") {
+				name = strings.TrimSpace(name)
+				if name == "" || name == models.UntaggedTag || seen[name] {
+					continue
+				}
+				seen[name] = true
+				tagID, ok := tagIDs[name]
+				if !ok {
+					tag := models.Tag{Name: name}
+					if err := tx.Create(&tag).Error; err != nil {
+						return err
+					}
+					tagID = tag.ID
+					tagIDs[name] = tagID
+				}
+				if err := tx.Exec(
+					"INSERT INTO post_tags (post_id, tag_id, created_at) SELECT id, ?, CURRENT_TIMESTAMP FROM posts WHERE tag = ?",
+					tagID, value,
+				).Error; err != nil {
+					return err
+				}
+			}
+		}
+		log.Printf("Migrated %d tags from posts.tag", len(tagIDs))
+		return nil
+	})
+}
+
+// ensureColumn 如果字段不存在则添加
+func ensureColumn(db *gorm.DB, table, column, definition string) error {
+	if db.Migrator().HasColumn(table, column) {
+		return nil
+	}
+	return db.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + definition).Error
 }
 
 // migrateExistingTables 迁移现有表
@@ -140,6 +253,13 @@ func migrateExistingTables(db *gorm.DB) error {
 			return err
 		}
 	}
+
+	// 补齐后续版本新增的字段
+	for _, col := range addedColumns {
+		if err := ensureColumn(db, col.Table, col.Column, col.Definition); err != nil {
+			return err
+		}
+	}
 	
 	// 确保sync_statuses表存在
 	var syncCount int64
@@ -0,0 +1,264 @@
+package dedup
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"treehole/internal/classifier"
+	"treehole/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// MaxDistance 判定为重复内容的最大汉明距离
+const MaxDistance = 3
+
+// minTokens 词数少于该值的短文本只把指纹完全相同的帖子视为重复，避免短句误判
+const minTokens = 10
+
+// bandBits 指纹每段的位数
+const bandBits = 16
+
+// SimHash 计算标题和内容的 64 位 SimHash 指纹，同时返回参与计算的词数
+func SimHash(title, content string) (uint64, int) {
+	tokens := classifier.Tokenize(title + "\n" + content)
+	if len(tokens) == 0 {
+		return 0, 0
+	}
+
+	var weights [64]int
+	for _, token := range tokens {
+		h := fnv.New64a()
+		h.Write([]byte(token))
+		sum := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if sum&(1<<uint(i)) != 0 {
+				weights[i]++
+			} else {
+				weights[i]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for i, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << uint(i)
+		}
+	}
+	return fingerprint, len(tokens)
+}
+
+// Distance 两个指纹的汉明距离
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Assign 计算帖子的指纹并归入相近内容所在的重复组，内容没有变化时不做处理
+// 帖子的 DuplicateOf 会同步更新为所在组最早发布的帖子
+func Assign(db *gorm.DB, post *models.Post) error {
+	simhash, tokens := SimHash(post.Title, post.Content)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var fp models.PostFingerprint
+		err := tx.Where("post_id = ?", post.ID).First(&fp).Error
+		exists := err == nil
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		if exists && uint64(fp.SimHash) == simhash && fp.Tokens == tokens {
+			return nil
+		}
+
+		// 内容变化后先离开原来的组，再重新查找
+		if exists {
+			if err := detach(tx, &fp); err != nil {
+				return err
+			}
+		}
+		post.DuplicateOf = nil
+		if tokens == 0 {
+			if exists {
+				return tx.Delete(&fp).Error
+			}
+			return nil
+		}
+
+		fp.PostID = post.ID
+		fp.SimHash = int64(simhash)
+		fp.Tokens = tokens
+		fp.Band0, fp.Band1, fp.Band2, fp.Band3 = bands(simhash)
+		fp.ClusterID = nil
+		if err := tx.Save(&fp).Error; err != nil {
+			return err
+		}
+
+		match, err := findMatch(tx, &fp)
+		if err != nil || match == nil {
+			return err
+		}
+		return join(tx, &fp, match, post)
+	})
+}
+
+// Remove 永久删除帖子时移除其指纹，必要时为所在的组重新选择代表帖子
+func Remove(tx *gorm.DB, postID uint) error {
+	var fp models.PostFingerprint
+	if err := tx.Where("post_id = ?", postID).First(&fp).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+	if err := detach(tx, &fp); err != nil {
+		return err
+	}
+	return tx.Delete(&fp).Error
+}
+
+// bands 将指纹拆分为四段
+func bands(simhash uint64) (int, int, int, int) {
+	mask := uint64(1<<bandBits - 1)
+	return int(simhash & mask),
+		int(simhash >> bandBits & mask),
+		int(simhash >> (2 * bandBits) & mask),
+		int(simhash >> (3 * bandBits) & mask)
+}
+
+// findMatch 查找与指纹最相近的未隐藏帖子的指纹
+func findMatch(tx *gorm.DB, fp *models.PostFingerprint) (*models.PostFingerprint, error) {
+	var candidates []models.PostFingerprint
+	if err := tx.Table("post_fingerprints").
+		Select("post_fingerprints.*").
+		Joins("JOIN posts ON posts.id = post_fingerprints.post_id AND posts.deleted_at IS NULL").
+		Where("post_fingerprints.post_id <> ?", fp.PostID).
+		Where("post_fingerprints.band0 = ? OR post_fingerprints.band1 = ? OR post_fingerprints.band2 = ? OR post_fingerprints.band3 = ?",
+			fp.Band0, fp.Band1, fp.Band2, fp.Band3).
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	var best *models.PostFingerprint
+	bestDistance := MaxDistance + 1
+	for i := range candidates {
+		candidate := &candidates[i]
+		limit := MaxDistance
+		if fp.Tokens < minTokens || candidate.Tokens < minTokens {
+			limit = 0
+		}
+		distance := Distance(uint64(fp.SimHash), uint64(candidate.SimHash))
+		if distance > limit {
+			continue
+		}
+		// 距离相同时优先归入已有的组
+		if distance < bestDistance || (distance == bestDistance && best.ClusterID == nil && candidate.ClusterID != nil) {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best, nil
+}
+
+// join 将帖子加入匹配指纹所在的组，匹配的帖子还没有组时新建
+func join(tx *gorm.DB, fp, match *models.PostFingerprint, post *models.Post) error {
+	var cluster models.DuplicateCluster
+	if match.ClusterID == nil {
+		cluster = models.DuplicateCluster{CanonicalPostID: match.PostID, Size: 1}
+		if err := tx.Create(&cluster).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(match).Update("cluster_id", cluster.ID).Error; err != nil {
+			return err
+		}
+	} else if err := tx.First(&cluster, *match.ClusterID).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Model(fp).Update("cluster_id", cluster.ID).Error; err != nil {
+		return err
+	}
+	cluster.Size++
+
+	// 比当前代表更早发布的帖子成为新的代表
+	var canonical models.Post
+	err := tx.Unscoped().Select("id, created_at").First(&canonical, cluster.CanonicalPostID).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	if err == gorm.ErrRecordNotFound || post.CreatedAt.Before(canonical.CreatedAt) {
+		cluster.CanonicalPostID = post.ID
+	}
+	if err := tx.Save(&cluster).Error; err != nil {
+		return err
+	}
+	if cluster.CanonicalPostID != post.ID {
+		canonicalID := cluster.CanonicalPostID
+		post.DuplicateOf = &canonicalID
+	}
+	return markMembers(tx, &cluster)
+}
+
+// detach 将指纹移出所在的组，组内只剩一个帖子时解散
+func detach(tx *gorm.DB, fp *models.PostFingerprint) error {
+	if fp.ClusterID == nil {
+		return nil
+	}
+	clusterID := *fp.ClusterID
+	if err := tx.Model(fp).Update("cluster_id", nil).Error; err != nil {
+		return err
+	}
+	fp.ClusterID = nil
+	if err := setDuplicateOf(tx, "id = ?", nil, fp.PostID); err != nil {
+		return err
+	}
+
+	var cluster models.DuplicateCluster
+	if err := tx.First(&cluster, clusterID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+
+	var members []uint
+	if err := tx.Model(&models.PostFingerprint{}).Where("cluster_id = ?", clusterID).Pluck("post_id", &members).Error; err != nil {
+		return err
+	}
+	if len(members) <= 1 {
+		if err := tx.Model(&models.PostFingerprint{}).Where("cluster_id = ?", clusterID).Update("cluster_id", nil).Error; err != nil {
+			return err
+		}
+		if len(members) == 1 {
+			if err := setDuplicateOf(tx, "id = ?", nil, members[0]); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&cluster).Error
+	}
+
+	cluster.Size = len(members)
+	if cluster.CanonicalPostID == fp.PostID {
+		var earliest models.Post
+		if err := tx.Unscoped().Select("id").Where("id IN ?", members).
+			Order("created_at asc, id asc").First(&earliest).Error; err != nil {
+			return err
+		}
+		cluster.CanonicalPostID = earliest.ID
+	}
+	if err := tx.Save(&cluster).Error; err != nil {
+		return err
+	}
+	return markMembers(tx, &cluster)
+}
+
+// markMembers 将组内除代表以外的帖子标记为代表帖子的重复
+func markMembers(tx *gorm.DB, cluster *models.DuplicateCluster) error {
+	members := tx.Model(&models.PostFingerprint{}).Select("post_id").Where("cluster_id = ?", cluster.ID)
+	if err := setDuplicateOf(tx, "id IN (?) AND id <> ?", cluster.CanonicalPostID, members, cluster.CanonicalPostID); err != nil {
+		return err
+	}
+	return setDuplicateOf(tx, "id = ?", nil, cluster.CanonicalPostID)
+}
+
+// setDuplicateOf 更新帖子的 duplicate_of 字段，不修改更新时间
+func setDuplicateOf(tx *gorm.DB, query string, value interface{}, args ...interface{}) error {
+	return tx.Unscoped().Model(&models.Post{}).Where(query, args...).UpdateColumn("duplicate_of", value).Error
+}
@@ -0,0 +1,327 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"treehole/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 导出格式
+const (
+	FormatJSONL  = "jsonl"
+	FormatCSV    = "csv"
+	FormatSQLite = "sqlite"
+)
+
+// batchSize 每批读取的帖子数量
+const batchSize = 500
+
+// ErrUnsupportedFormat 不支持的导出格式
+var ErrUnsupportedFormat = errors.New("format must be jsonl, csv or sqlite")
+
+// Options 导出的筛选和脱敏选项
+type Options struct {
+	From          time.Time // 发布时间下限（含），零值表示不限制
+	To            time.Time // 发布时间上限（不含），零值表示不限制
+	States        []string  // 只导出这些状态的帖子，为空表示全部
+	Redact        bool      // 清除 IP、作者ID、回复对象和微信号
+	IncludeHidden bool      // 包括被管理员隐藏的帖子和回复
+}
+
+// PostRecord JSONL 中的一行，deleted_at 为帖子被管理员隐藏的时间，未隐藏时为 null
+type PostRecord struct {
+	models.Post
+	DeletedAt *time.Time    `json:"deleted_at"`
+	Replies   []ReplyRecord `json:"replies"`
+}
+
+// ReplyRecord 导出的回复，deleted_at 含义与帖子相同
+// parent_id 是本实例的ID，parent_original_id 为父回复的 original_id，供其他实例在父回复不在文件中时查找
+type ReplyRecord struct {
+	models.Reply
+	DeletedAt        *time.Time `json:"deleted_at"`
+	ParentOriginalID string     `json:"parent_original_id,omitempty"`
+}
+
+// ParseDate 解析日期参数，支持 2006-01-02 和 RFC3339
+// end 为 true 时只有日期的参数表示当天结束，返回第二天零点作为不含的上限
+func ParseDate(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC3339", value)
+	}
+	return t, nil
+}
+
+// ParseStates 拆分逗号分隔的状态列表
+func ParseStates(value string) []string {
+	var states []string
+	for _, state := range strings.Split(value, ",") {
+		if state = strings.TrimSpace(state); state != "" {
+			states = append(states, state)
+		}
+	}
+	return states
+}
+
+// WriteJSONL 以 JSONL 格式导出帖子，每行一个帖子，回复嵌套在 replies 字段中，返回导出的帖子数量
+func WriteJSONL(db *gorm.DB, w io.Writer, opts Options) (int, error) {
+	buffered := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffered)
+	encoder.SetEscapeHTML(false)
+
+	count, err := eachBatch(db, opts, func(records []PostRecord) error {
+		for i := range records {
+			if err := encoder.Encode(&records[i]); err != nil {
+				return err
+			}
+		}
+		return buffered.Flush()
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, buffered.Flush()
+}
+
+// csvHeader CSV 的列，帖子和回复共用，type 区分记录类型，回复的 post_id 指向所属帖子
+var csvHeader = []string{
+	"type", "id", "post_id", "parent_id", "original_id", "title", "content", "author", "author_id",
+	"apply_to", "ip", "wechat", "radio_group", "campus_group", "region", "price", "tag", "state",
+	"like_num", "reply_count", "view_count", "images", "created_at", "deleted_at", "parent_original_id",
+}
+
+// WriteCSV 以 CSV 格式导出帖子，每个帖子之后紧跟其回复，返回导出的帖子数量
+func WriteCSV(db *gorm.DB, w io.Writer, opts Options) (int, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return 0, err
+	}
+
+	count, err := eachBatch(db, opts, func(records []PostRecord) error {
+		for _, record := range records {
+			post := record.Post
+			if err := writer.Write([]string{
+				"post", uintString(post.ID), uintString(post.ID), "", post.OriginalID, post.Title, post.Content,
+				post.Author, post.AuthorID, "", post.IP, post.Wechat, post.RadioGroup, post.CampusGroup,
+				post.Region, post.Price, post.Tag, post.State, strconv.Itoa(post.LikeNum),
+				strconv.Itoa(post.ReplyCount), strconv.Itoa(post.ViewCount), post.Images, formatTime(post.CreatedAt),
+				formatTimePtr(record.DeletedAt), "",
+			}); err != nil {
+				return err
+			}
+			for _, replyRecord := range record.Replies {
+				reply := replyRecord.Reply
+				if err := writer.Write([]string{
+					"reply", uintString(reply.ID), uintString(reply.PostID), strconv.Itoa(reply.ParentID), reply.OriginalID,
+					"", reply.Content, reply.Author, reply.AuthorID, reply.ApplyTo, "", "", "", "", "", "", reply.Tag,
+					"", strconv.Itoa(reply.LikeNum), "", "", reply.Images, formatTime(reply.CreatedAt),
+					formatTimePtr(replyRecord.DeletedAt), replyRecord.ParentOriginalID,
+				}); err != nil {
+					return err
+				}
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	})
+	if err != nil {
+		return count, err
+	}
+	writer.Flush()
+	return count, writer.Error()
+}
+
+// eachBatch 按ID顺序分批读取符合条件的帖子及其回复，脱敏并转换为导出记录后交给 fn 处理
+func eachBatch(db *gorm.DB, opts Options, fn func(records []PostRecord) error) (int, error) {
+	count := 0
+	var after uint
+	for {
+		var posts []models.Post
+		if err := postScope(db, opts).Where("id > ?", after).Order("id asc").Limit(batchSize).Find(&posts).Error; err != nil {
+			return count, err
+		}
+		if len(posts) == 0 {
+			return count, nil
+		}
+
+		postIDs := make([]uint, 0, len(posts))
+		for _, post := range posts {
+			postIDs = append(postIDs, post.ID)
+		}
+		replyQuery := db
+		if opts.IncludeHidden {
+			replyQuery = replyQuery.Unscoped()
+		}
+		var replies []models.Reply
+		if err := replyQuery.Where("post_id IN ?", postIDs).Order("id asc").Find(&replies).Error; err != nil {
+			return count, err
+		}
+		index := make(map[uint]int, len(posts))
+		for i := range posts {
+			index[posts[i].ID] = i
+			posts[i].Replies = []models.Reply{}
+		}
+		for _, reply := range replies {
+			i := index[reply.PostID]
+			posts[i].Replies = append(posts[i].Replies, reply)
+		}
+		if opts.Redact {
+			for i := range posts {
+				redactPost(&posts[i])
+			}
+		}
+		parents, err := parentOriginalIDs(db, replies)
+		if err != nil {
+			return count, err
+		}
+		records := make([]PostRecord, 0, len(posts))
+		for i := range posts {
+			records = append(records, newRecord(&posts[i], parents))
+		}
+
+		if err := fn(records); err != nil {
+			return count, err
+		}
+		count += len(posts)
+		after = posts[len(posts)-1].ID
+		if len(posts) < batchSize {
+			return count, nil
+		}
+	}
+}
+
+// postScope 按筛选条件查询帖子
+func postScope(db *gorm.DB, opts Options) *gorm.DB {
+	query := db.Model(&models.Post{})
+	if opts.IncludeHidden {
+		query = query.Unscoped()
+	}
+	if !opts.From.IsZero() {
+		query = query.Where("created_at >= ?", opts.From)
+	}
+	if !opts.To.IsZero() {
+		query = query.Where("created_at < ?", opts.To)
+	}
+	if len(opts.States) > 0 {
+		query = query.Where("state IN ?", opts.States)
+	}
+	return query
+}
+
+// redactPost 清除帖子和回复中可以关联到个人的字段
+func redactPost(post *models.Post) {
+	post.IP = ""
+	post.AuthorID = ""
+	post.Wechat = ""
+	for i := range post.Replies {
+		post.Replies[i].AuthorID = ""
+		post.Replies[i].ApplyTo = ""
+	}
+}
+
+// parentOriginalIDs 查找回复的父回复的 original_id，包括未导出的隐藏回复
+func parentOriginalIDs(db *gorm.DB, replies []models.Reply) (map[uint]string, error) {
+	parents := make(map[uint]string)
+	loaded := make(map[uint]string, len(replies))
+	for _, reply := range replies {
+		loaded[reply.ID] = reply.OriginalID
+	}
+	var missing []uint
+	for _, reply := range replies {
+		if reply.ParentID <= 0 {
+			continue
+		}
+		id := uint(reply.ParentID)
+		if originalID, ok := loaded[id]; ok {
+			parents[id] = originalID
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		var rows []models.Reply
+		if err := db.Unscoped().Select("id, original_id").Where("id IN ?", missing).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			parents[row.ID] = row.OriginalID
+		}
+	}
+	return parents, nil
+}
+
+// newRecord 把帖子转换为导出记录，带上帖子和回复的隐藏时间以及父回复的 original_id
+func newRecord(post *models.Post, parents map[uint]string) PostRecord {
+	record := PostRecord{
+		Post:      *post,
+		DeletedAt: deletedTime(post.DeletedAt),
+		Replies:   make([]ReplyRecord, 0, len(post.Replies)),
+	}
+	record.Post.Replies = nil
+	for _, reply := range post.Replies {
+		replyRecord := ReplyRecord{Reply: reply, DeletedAt: deletedTime(reply.DeletedAt)}
+		if reply.ParentID > 0 {
+			replyRecord.ParentOriginalID = parents[uint(reply.ParentID)]
+		}
+		record.Replies = append(record.Replies, replyRecord)
+	}
+	return record
+}
+
+// deletedTime 隐藏时间，未隐藏时返回 nil
+func deletedTime(deletedAt gorm.DeletedAt) *time.Time {
+	if !deletedAt.Valid {
+		return nil
+	}
+	t := deletedAt.Time
+	return &t
+}
+
+// formatTimePtr 以 RFC3339 格式化可能为空的时间，为空时返回空字符串
+func formatTimePtr(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatTime(*t)
+}
+
+// uintString 格式化ID
+func uintString(value uint) string {
+	return strconv.FormatUint(uint64(value), 10)
+}
+
+// formatTime 以 RFC3339 格式化时间
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+
+// Write 按格式导出到 w，只支持流式格式 jsonl 和 csv
+func Write(db *gorm.DB, w io.Writer, format string, opts Options) (int, error) {
+	switch format {
+	case FormatJSONL:
+		return WriteJSONL(db, w, opts)
+	case FormatCSV:
+		return WriteCSV(db, w, opts)
+	default:
+		return 0, ErrUnsupportedFormat
+	}
+}
@@ -0,0 +1,81 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"path/filepath"
+	"testing"
+	"treehole/internal/database"
+	"treehole/internal/models"
+
+	"gorm.io/gorm/logger"
+)
+
+func TestWriteCSVHiddenContent(t *testing.T) {
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	post := models.Post{OriginalID: "100", Title: "标题", Content: "内容", State: "normal"}
+	if err := db.Create(&post).Error; err != nil {
+		t.Fatal(err)
+	}
+	parent := models.Reply{PostID: post.ID, OriginalID: "1001", Content: "被隐藏的回复"}
+	if err := db.Create(&parent).Error; err != nil {
+		t.Fatal(err)
+	}
+	child := models.Reply{PostID: post.ID, OriginalID: "1002", Content: "子回复", ParentID: int(parent.ID)}
+	if err := db.Create(&child).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete(&parent).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		includeHidden bool
+		want          [][2]string // 每行的 original_id 和 deleted_at 是否为空
+		parent        string      // 子回复的 parent_original_id
+	}{
+		{"visible only", false, [][2]string{{"100", ""}, {"1002", ""}}, "1001"},
+		{"include hidden", true, [][2]string{{"100", ""}, {"1001", "set"}, {"1002", ""}}, "1001"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if _, err := WriteCSV(db, &buf, Options{IncludeHidden: tt.includeHidden}); err != nil {
+				t.Fatal(err)
+			}
+			rows, err := csv.NewReader(&buf).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			column := make(map[string]int, len(rows[0]))
+			for i, name := range rows[0] {
+				column[name] = i
+			}
+			rows = rows[1:]
+			if len(rows) != len(tt.want) {
+				t.Fatalf("got %d rows, want %d", len(rows), len(tt.want))
+			}
+			for i, row := range rows {
+				deleted := ""
+				if row[column["deleted_at"]] != "" {
+					deleted = "set"
+				}
+				if row[column["original_id"]] != tt.want[i][0] || deleted != tt.want[i][1] {
+					t.Errorf("row %d = %s deleted_at=%q, want %s %s", i, row[column["original_id"]], row[column["deleted_at"]], tt.want[i][0], tt.want[i][1])
+				}
+			}
+			if got := rows[len(rows)-1][column["parent_original_id"]]; got != tt.parent {
+				t.Errorf("parent_original_id = %q, want %q", got, tt.parent)
+			}
+		})
+	}
+}
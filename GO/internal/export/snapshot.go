@@ -0,0 +1,114 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"treehole/internal/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// ErrSnapshotUnsupported 数据库不是 SQLite 时无法生成快照
+var ErrSnapshotUnsupported = errors.New("sqlite snapshot requires a SQLite database")
+
+// datasetTables 快照中保留的数据表，其余表（管理员密钥、用户订阅、收藏、举报等）一律删除
+var datasetTables = map[string]bool{
+	"posts":              true,
+	"replies":            true,
+	"tags":               true,
+	"post_tags":          true,
+	"post_metrics":       true,
+	"trending_posts":     true,
+	"post_fingerprints":  true,
+	"duplicate_clusters": true,
+	"media":              true,
+	"sync_statuses":      true,
+}
+
+// postTables 通过 post_id 关联帖子的数据表，筛选帖子后同步清理
+var postTables = []string{"replies", "post_tags", "post_metrics", "trending_posts", "post_fingerprints"}
+
+// Snapshot 用 VACUUM INTO 生成数据库的一致性快照，再在副本上应用筛选和脱敏
+// 已存在的目标文件会被覆盖
+func Snapshot(db *gorm.DB, path string, opts Options) error {
+	if db.Dialector.Name() != "sqlite" {
+		return ErrSnapshotUnsupported
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := db.Exec("VACUUM INTO ?", path).Error; err != nil {
+		return fmt.Errorf("vacuum into: %w", err)
+	}
+
+	snapshot, err := gorm.Open(sqlite.Dialector{DriverName: "sqlite", DSN: path}, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return err
+	}
+	sqlDB, err := snapshot.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	if err := prune(snapshot, opts); err != nil {
+		os.Remove(path)
+		return err
+	}
+	// 回收删除的数据占用的空间，被删除的内容不会残留在文件中
+	return snapshot.Exec("VACUUM").Error
+}
+
+// prune 删除快照中不属于数据集的表和不符合筛选条件的记录
+func prune(snapshot *gorm.DB, opts Options) error {
+	var tables []string
+	if err := snapshot.Raw("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'").Scan(&tables).Error; err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if datasetTables[table] {
+			continue
+		}
+		if err := snapshot.Exec("DROP TABLE " + snapshot.Statement.Quote(table)).Error; err != nil {
+			return err
+		}
+	}
+
+	return snapshot.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("id NOT IN (?)", postScope(tx, opts).Select("id")).Delete(&models.Post{}).Error; err != nil {
+			return err
+		}
+		if !opts.IncludeHidden {
+			if err := tx.Unscoped().Where("deleted_at IS NOT NULL").Delete(&models.Reply{}).Error; err != nil {
+				return err
+			}
+		}
+		for _, table := range postTables {
+			if err := tx.Exec("DELETE FROM " + tx.Statement.Quote(table) + " WHERE post_id NOT IN (SELECT id FROM posts)").Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Exec("DELETE FROM media WHERE (owner_type = 'post' AND owner_id NOT IN (SELECT id FROM posts)) " +
+			"OR (owner_type = 'reply' AND owner_id NOT IN (SELECT id FROM replies))").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM duplicate_clusters WHERE canonical_post_id NOT IN (SELECT id FROM posts)").Error; err != nil {
+			return err
+		}
+
+		if opts.Redact {
+			if err := tx.Exec("UPDATE posts SET ip = '', author_id = '', wechat = ''").Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("UPDATE replies SET author_id = '', apply_to = ''").Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
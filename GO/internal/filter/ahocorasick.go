@@ -0,0 +1,118 @@
+package filter
+
+import "unicode"
+
+// matcher Aho-Corasick 多模式匹配自动机，按 rune 匹配以支持中文
+type matcher struct {
+	nodes    []acNode
+	patterns []string
+	lengths  []int // 规范化后的模式长度
+}
+
+// acNode 自动机节点
+type acNode struct {
+	next   map[rune]int
+	fail   int
+	output []int // 以该节点结尾的模式下标
+}
+
+// Match 单次匹配结果
+type Match struct {
+	Pattern string
+	Start   int // 以 rune 计的起始位置
+}
+
+// newMatcher 根据模式列表构建自动机
+func newMatcher(patterns []string) *matcher {
+	m := &matcher{
+		nodes:    []acNode{{next: map[rune]int{}}},
+		patterns: patterns,
+		lengths:  make([]int, len(patterns)),
+	}
+
+	for i, pattern := range patterns {
+		runes := normalizeRunes(pattern)
+		m.lengths[i] = len(runes)
+		state := 0
+		for _, r := range runes {
+			next, ok := m.nodes[state].next[r]
+			if !ok {
+				next = len(m.nodes)
+				m.nodes = append(m.nodes, acNode{next: map[rune]int{}})
+				m.nodes[state].next[r] = next
+			}
+			state = next
+		}
+		if state != 0 {
+			m.nodes[state].output = append(m.nodes[state].output, i)
+		}
+	}
+
+	// 广度优先构建失败指针
+	queue := make([]int, 0, len(m.nodes))
+	for _, child := range m.nodes[0].next {
+		queue = append(queue, child)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for r, child := range m.nodes[state].next {
+			queue = append(queue, child)
+
+			fail := m.nodes[state].fail
+			for fail != 0 {
+				if _, ok := m.nodes[fail].next[r]; ok {
+					break
+				}
+				fail = m.nodes[fail].fail
+			}
+			if target, ok := m.nodes[fail].next[r]; ok && target != child {
+				m.nodes[child].fail = target
+			}
+			m.nodes[child].output = append(m.nodes[child].output, m.nodes[m.nodes[child].fail].output...)
+		}
+	}
+
+	return m
+}
+
+// find 返回文本中出现的所有模式
+func (m *matcher) find(text string) []Match {
+	if m == nil || len(m.nodes) <= 1 {
+		return nil
+	}
+
+	var matches []Match
+	state := 0
+	for pos, r := range normalizeRunes(text) {
+		for state != 0 {
+			if _, ok := m.nodes[state].next[r]; ok {
+				break
+			}
+			state = m.nodes[state].fail
+		}
+		if next, ok := m.nodes[state].next[r]; ok {
+			state = next
+		}
+		for _, idx := range m.nodes[state].output {
+			matches = append(matches, Match{Pattern: m.patterns[idx], Start: pos - m.lengths[idx] + 1})
+		}
+	}
+	return matches
+}
+
+// normalizeRunes 统一大小写和全角字符，去掉空白和常见分隔符，防止用空格拆字绕过
+func normalizeRunes(text string) []rune {
+	runes := make([]rune, 0, len(text))
+	for _, r := range text {
+		// 全角 ASCII 转半角
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+		if unicode.IsSpace(r) || r == '*' || r == '-' || r == '_' || r == '.' || r == '·' {
+			continue
+		}
+		runes = append(runes, unicode.ToLower(r))
+	}
+	return runes
+}
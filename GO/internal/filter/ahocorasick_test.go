@@ -0,0 +1,84 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatcherFind(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		text     string
+		want     []Match
+	}{
+		{
+			name:     "no patterns",
+			patterns: nil,
+			text:     "任何内容",
+			want:     nil,
+		},
+		{
+			name:     "chinese pattern",
+			patterns: []string{"代写"},
+			text:     "专业代写论文",
+			want:     []Match{{Pattern: "代写", Start: 2}},
+		},
+		{
+			name:     "overlapping patterns",
+			patterns: []string{"he", "she", "his", "hers"},
+			text:     "ushers",
+			want: []Match{
+				{Pattern: "she", Start: 1},
+				{Pattern: "he", Start: 2},
+				{Pattern: "hers", Start: 2},
+			},
+		},
+		{
+			name:     "pattern repeated",
+			patterns: []string{"ab"},
+			text:     "abab",
+			want:     []Match{{Pattern: "ab", Start: 0}, {Pattern: "ab", Start: 2}},
+		},
+		{
+			name:     "separators and case ignored",
+			patterns: []string{"BadWord"},
+			text:     "b a-d_W.o*r·d",
+			want:     []Match{{Pattern: "BadWord", Start: 0}},
+		},
+		{
+			name:     "full width characters",
+			patterns: []string{"vx"},
+			text:     "加ＶＸ",
+			want:     []Match{{Pattern: "vx", Start: 1}},
+		},
+		{
+			name:     "failure link to shorter pattern",
+			patterns: []string{"abcd", "bc"},
+			text:     "abce",
+			want:     []Match{{Pattern: "bc", Start: 1}},
+		},
+		{
+			name:     "no match",
+			patterns: []string{"代写"},
+			text:     "代课写作",
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newMatcher(tt.patterns).find(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("find(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNilMatcher(t *testing.T) {
+	var m *matcher
+	if got := m.find("anything"); got != nil {
+		t.Errorf("nil matcher find = %v, want nil", got)
+	}
+}
@@ -0,0 +1,343 @@
+package filter
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 过滤结论，严重程度递增
+const (
+	VerdictAllow  = "allow"
+	VerdictReview = "review"
+	VerdictReject = "reject"
+)
+
+// 同一内容被多少个不同客户端发布时转人工审核
+const duplicateClientThreshold = 3
+
+// 联系方式检测规则，预先编译避免每次请求重复编译
+var contactPatterns = []struct {
+	Name    string
+	Pattern *regexp.Regexp
+}{
+	{"phone number", regexp.MustCompile(`(?:^|\D)(?:\+?86[\s-]?)?1[3-9]\d(?:[\s-]?\d){8}(?:\D|$)`)},
+	{"qq number", regexp.MustCompile(`(?i)(?:qq|扣扣|企鹅)\s*(?:号|群)?\s*[:：]?\s*\d{5,11}`)},
+	{"wechat id", regexp.MustCompile(`(?i)(?:微信|威信|薇信|vx|wx|v信|weixin|wechat)\s*号?\s*[:：]?\s*[a-z][-_a-z0-9]{5,19}`)},
+	{"email address", regexp.MustCompile(`(?i)[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}`)},
+	{"link", regexp.MustCompile(`(?i)(?:https?://|www\.)\S+`)},
+}
+
+// ContainsContact 文本是否包含手机号、QQ、微信、邮箱或链接
+func ContainsContact(text string) bool {
+	for _, contact := range contactPatterns {
+		if contact.Pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Result 过滤结果
+type Result struct {
+	Verdict string   `json:"verdict"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// escalate 提升结论等级并记录原因
+func (r *Result) escalate(verdict, reason string) {
+	if severity(verdict) > severity(r.Verdict) {
+		r.Verdict = verdict
+	}
+	r.Reasons = append(r.Reasons, reason)
+}
+
+// severity 结论的严重程度
+func severity(verdict string) int {
+	switch verdict {
+	case VerdictReject:
+		return 2
+	case VerdictReview:
+		return 1
+	}
+	return 0
+}
+
+// Config 过滤配置
+type Config struct {
+	BlockWordsFile  string        // 命中即拒绝的词表
+	ReviewWordsFile string        // 命中转人工审核的词表
+	DuplicateWindow time.Duration // 重复内容检测的时间窗口
+	MaxRepeatRun    int           // 同一字符连续出现的最大次数
+}
+
+// Pipeline 发帖和回复的内容过滤流水线
+// 依次执行敏感词、联系方式、重复字符和重复发帖检测，汇总为一个结论
+type Pipeline struct {
+	config Config
+
+	mutex  sync.RWMutex
+	block  *matcher
+	review *matcher
+
+	recent *recentContent
+}
+
+// New 创建过滤流水线并加载词表
+func New(cfg Config) *Pipeline {
+	p := &Pipeline{
+		config: cfg,
+		recent: newRecentContent(cfg.DuplicateWindow),
+	}
+	if blockCount, reviewCount, err := p.Reload(); err != nil {
+		log.Printf("Failed to load filter word lists: %v", err)
+	} else {
+		log.Printf("Filter word lists loaded: %d block words, %d review words", blockCount, reviewCount)
+	}
+	return p
+}
+
+// Reload 重新加载词表，加载失败时保留原有词表
+func (p *Pipeline) Reload() (blockCount, reviewCount int, err error) {
+	blockWords, err := loadWordList(p.config.BlockWordsFile)
+	if err != nil {
+		return 0, 0, err
+	}
+	reviewWords, err := loadWordList(p.config.ReviewWordsFile)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	block := newMatcher(blockWords)
+	review := newMatcher(reviewWords)
+
+	p.mutex.Lock()
+	p.block = block
+	p.review = review
+	p.mutex.Unlock()
+
+	return len(blockWords), len(reviewWords), nil
+}
+
+// Check 检查一段待发布的文本，clientHash 用于识别同一客户端的重复发帖
+// 检查本身不记录内容，发布成功后调用 Record
+func (p *Pipeline) Check(text, clientHash string) Result {
+	return p.check(text, clientHash, true)
+}
+
+// Record 记录已发布的内容，用于之后的重复发帖检测
+// 只在内容保存成功后调用，避免保存失败或被拒绝的内容让用户重试时被判为重复
+func (p *Pipeline) Record(text, clientHash string) {
+	p.recent.add(contentFingerprint(text), clientHash)
+}
+
+// Preview 检查文本但不参与重复发帖检测，用于管理员测试规则
+func (p *Pipeline) Preview(text string) Result {
+	return p.check(text, "", false)
+}
+
+// check 执行过滤流水线，duplicates 为 true 时检测重复发帖
+func (p *Pipeline) check(text, clientHash string, duplicates bool) Result {
+	result := Result{Verdict: VerdictAllow}
+
+	p.mutex.RLock()
+	block, review := p.block, p.review
+	p.mutex.RUnlock()
+
+	for _, word := range uniquePatterns(block.find(text)) {
+		result.escalate(VerdictReject, "blocked word: "+word)
+	}
+	for _, word := range uniquePatterns(review.find(text)) {
+		result.escalate(VerdictReview, "sensitive word: "+word)
+	}
+
+	for _, contact := range contactPatterns {
+		if contact.Pattern.MatchString(text) {
+			result.escalate(VerdictReview, "contains "+contact.Name)
+		}
+	}
+
+	if p.config.MaxRepeatRun > 0 {
+		if r, run := longestRun(text); run > p.config.MaxRepeatRun {
+			result.escalate(VerdictReview, fmt.Sprintf("character %q repeated %d times", r, run))
+		}
+	}
+	if lowDiversity(text) {
+		result.escalate(VerdictReject, "content consists of repeated characters")
+	}
+
+	if !duplicates {
+		return result
+	}
+
+	sameClient, clients := p.recent.seen(contentFingerprint(text), clientHash)
+	if sameClient {
+		result.escalate(VerdictReject, "duplicate post")
+	} else if clients+1 >= duplicateClientThreshold {
+		result.escalate(VerdictReview, "same content posted by multiple clients")
+	}
+
+	return result
+}
+
+// loadWordList 读取词表文件，每行一个词，# 开头为注释
+func loadWordList(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var words []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") || seen[word] {
+			continue
+		}
+		seen[word] = true
+		words = append(words, word)
+	}
+	return words, scanner.Err()
+}
+
+// uniquePatterns 去重后的命中词列表
+func uniquePatterns(matches []Match) []string {
+	var words []string
+	seen := make(map[string]bool)
+	for _, match := range matches {
+		if !seen[match.Pattern] {
+			seen[match.Pattern] = true
+			words = append(words, match.Pattern)
+		}
+	}
+	return words
+}
+
+// longestRun 找出连续重复次数最多的字符，忽略空白
+func longestRun(text string) (rune, int) {
+	var best, prev rune
+	bestRun, run := 0, 0
+	for _, r := range normalizeRunes(text) {
+		if r == prev {
+			run++
+		} else {
+			prev, run = r, 1
+		}
+		if run > bestRun {
+			best, bestRun = r, run
+		}
+	}
+	return best, bestRun
+}
+
+// lowDiversity 判断较长的文本是否只由一两个字符构成
+func lowDiversity(text string) bool {
+	runes := normalizeRunes(text)
+	if len(runes) < 20 {
+		return false
+	}
+	distinct := make(map[rune]bool)
+	for _, r := range runes {
+		distinct[r] = true
+		if len(distinct) > 2 {
+			return false
+		}
+	}
+	return true
+}
+
+// contentFingerprint 规范化后的内容指纹
+func contentFingerprint(text string) string {
+	sum := sha256.Sum256([]byte(string(normalizeRunes(text))))
+	return hex.EncodeToString(sum[:])
+}
+
+// recentContent 记录时间窗口内发布过的内容指纹
+type recentContent struct {
+	window  time.Duration
+	mutex   sync.Mutex
+	entries map[string][]recentEntry
+	pruned  time.Time
+}
+
+// recentEntry 某个客户端发布内容的时间
+type recentEntry struct {
+	client string
+	at     time.Time
+}
+
+// newRecentContent 创建重复内容记录
+func newRecentContent(window time.Duration) *recentContent {
+	return &recentContent{
+		window:  window,
+		entries: make(map[string][]recentEntry),
+		pruned:  time.Now(),
+	}
+}
+
+// seen 返回同一客户端是否发布过该内容，以及发布过该内容的其他客户端数量
+func (rc *recentContent) seen(fingerprint, client string) (bool, int) {
+	if rc.window <= 0 {
+		return false, 0
+	}
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	cutoff := time.Now().Add(-rc.window)
+	sameClient := false
+	others := make(map[string]bool)
+	for _, entry := range rc.entries[fingerprint] {
+		if entry.at.Before(cutoff) {
+			continue
+		}
+		if entry.client == client {
+			sameClient = true
+		} else {
+			others[entry.client] = true
+		}
+	}
+	return sameClient, len(others)
+}
+
+// add 记录一次发布，并定期清理过期记录
+func (rc *recentContent) add(fingerprint, client string) {
+	if rc.window <= 0 {
+		return
+	}
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	now := time.Now()
+	rc.entries[fingerprint] = append(rc.entries[fingerprint], recentEntry{client: client, at: now})
+
+	if now.Sub(rc.pruned) < rc.window {
+		return
+	}
+	cutoff := now.Add(-rc.window)
+	for key, entries := range rc.entries {
+		kept := entries[:0]
+		for _, entry := range entries {
+			if entry.at.After(cutoff) {
+				kept = append(kept, entry)
+			}
+		}
+		if len(kept) == 0 {
+			delete(rc.entries, key)
+		} else {
+			rc.entries[key] = kept
+		}
+	}
+	rc.pruned = now
+}
@@ -0,0 +1,93 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestPipeline 使用临时词表创建过滤流水线
+func newTestPipeline(t *testing.T, block, review string) *Pipeline {
+	t.Helper()
+	dir := t.TempDir()
+	blockFile := filepath.Join(dir, "block.txt")
+	reviewFile := filepath.Join(dir, "review.txt")
+	if err := os.WriteFile(blockFile, []byte(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(reviewFile, []byte(review), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return New(Config{
+		BlockWordsFile:  blockFile,
+		ReviewWordsFile: reviewFile,
+		DuplicateWindow: time.Hour,
+		MaxRepeatRun:    10,
+	})
+}
+
+func TestPipelineCheck(t *testing.T) {
+	p := newTestPipeline(t, "# 注释\n代写\n代写\n", "兼职\n")
+
+	tests := []struct {
+		name    string
+		text    string
+		verdict string
+		reasons int
+	}{
+		{"clean", "今天食堂的饭很好吃", VerdictAllow, 0},
+		{"blocked word", "专业代 写论文", VerdictReject, 1},
+		{"review word", "招聘兼职", VerdictReview, 1},
+		{"block wins over review", "兼职代写", VerdictReject, 2},
+		{"phone number", "联系 13812345678", VerdictReview, 1},
+		{"wechat id", "加微信: abc_123456", VerdictReview, 1},
+		{"email address", "发到 someone@example.com", VerdictReview, 1},
+		{"link", "看这里 https://example.com/x", VerdictReview, 1},
+		{"short number is not a phone", "房间号 12345", VerdictAllow, 0},
+		{"long repeated run", "好好好好好好好好好好好好", VerdictReview, 1},
+		{"low diversity", "哈哈哈哈哈哈哈哈哈哈啊啊啊啊啊啊啊啊啊啊", VerdictReject, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.Preview(tt.text)
+			if got.Verdict != tt.verdict || len(got.Reasons) != tt.reasons {
+				t.Errorf("Preview(%q) = %s %v, want %s with %d reasons", tt.text, got.Verdict, got.Reasons, tt.verdict, tt.reasons)
+			}
+		})
+	}
+}
+
+func TestPipelineDuplicates(t *testing.T) {
+	p := newTestPipeline(t, "", "")
+	text := "出一台二手自行车，九成新"
+
+	// 只检查不记录，保存失败后重试不应被判为重复
+	for i := 0; i < 2; i++ {
+		if got := p.Check(text, "client-a"); got.Verdict != VerdictAllow {
+			t.Fatalf("unrecorded check %d = %s, want allow", i, got.Verdict)
+		}
+	}
+
+	p.Record(text, "client-a")
+	if got := p.Check(text, "client-a"); got.Verdict != VerdictReject {
+		t.Errorf("same client after record = %s, want reject", got.Verdict)
+	}
+	if got := p.Check(" 出一台二手自行车，九成新 ", "client-a"); got.Verdict != VerdictReject {
+		t.Errorf("whitespace variant after record = %s, want reject", got.Verdict)
+	}
+	if got := p.Check(text, "client-b"); got.Verdict != VerdictAllow {
+		t.Errorf("second client = %s, want allow", got.Verdict)
+	}
+
+	p.Record(text, "client-b")
+	if got := p.Check(text, "client-c"); got.Verdict != VerdictReview {
+		t.Errorf("third client = %s, want review", got.Verdict)
+	}
+
+	// Preview 不参与重复检测
+	if got := p.Preview(text); got.Verdict != VerdictAllow {
+		t.Errorf("preview of recorded text = %s, want allow", got.Verdict)
+	}
+}
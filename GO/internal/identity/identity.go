@@ -0,0 +1,126 @@
+package identity
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PosterName 帖子作者在自己帖子中的固定称呼
+const PosterName = "洞主"
+
+// authorIDPrefix 本地匿名作者ID前缀，用于和主站 openid 区分
+const authorIDPrefix = "anon_"
+
+// pseudonyms 回复者的候选昵称，按 HMAC 结果选取
+var pseudonyms = []string{
+	"Alice", "Bob", "Carol", "Dave", "Eve", "Frank", "Grace", "Heidi",
+	"Ivan", "Judy", "Kevin", "Linda", "Mallory", "Nick", "Olivia", "Peggy",
+	"Quinn", "Rupert", "Sybil", "Trent", "Ursula", "Victor", "Walter", "Xavier",
+	"Yvonne", "Zoe",
+}
+
+// MinSecretLength 签名密钥的最小字节数
+const MinSecretLength = 32
+
+// placeholderSecret 示例配置中曾使用的占位密钥
+const placeholderSecret = "change-me"
+
+// ValidateSecret 检查签名密钥，拒绝空密钥、占位密钥和过短的密钥
+func ValidateSecret(secret string) error {
+	switch {
+	case secret == "":
+		return errors.New("secret is not set")
+	case secret == placeholderSecret:
+		return errors.New("secret is still the example placeholder")
+	case len(secret) < MinSecretLength:
+		return fmt.Errorf("secret must be at least %d bytes", MinSecretLength)
+	}
+	return nil
+}
+
+// Manager 匿名身份令牌管理器
+// 令牌格式为 随机数.签名，服务端只需密钥即可校验，不保存任何令牌
+type Manager struct {
+	secret []byte
+}
+
+// NewManager 创建身份管理器，密钥需先通过 ValidateSecret 检查
+func NewManager(secret string) *Manager {
+	return &Manager{secret: []byte(secret)}
+}
+
+// Issue 签发新的匿名身份令牌
+func (m *Manager) Issue() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	encoded := hex.EncodeToString(nonce)
+	return encoded + "." + m.sign("token", encoded)[:32], nil
+}
+
+// Verify 校验令牌签名
+func (m *Manager) Verify(token string) bool {
+	nonce, sig, ok := strings.Cut(token, ".")
+	if !ok || len(nonce) != 32 || len(sig) != 32 {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(m.sign("token", nonce)[:32]))
+}
+
+// Subject 令牌对应的稳定标识，用于点赞去重等需要按身份保存的数据
+func (m *Manager) Subject(token string) string {
+	return m.sign("subject", token)
+}
+
+// NetworkHash 客户端网络地址的带密钥哈希，用于按来源去重而不保存原始 IP
+func (m *Manager) NetworkHash(ip string) string {
+	return m.sign("network", ip)
+}
+
+// ClientHash 未携带令牌的客户端的带密钥哈希，由网络地址和 User-Agent 计算
+// 不带密钥的哈希可以通过枚举 IP 和常见 UA 反推，因此必须使用 HMAC
+func (m *Manager) ClientHash(ip, userAgent string) string {
+	return m.sign("client", ip+"|"+userAgent)
+}
+
+// ThreadAuthorID 令牌在指定帖子中的作者ID，不同帖子之间无法关联
+func (m *Manager) ThreadAuthorID(token string, postID uint) string {
+	return authorIDPrefix + m.sign("thread", token+"|"+strconv.FormatUint(uint64(postID), 10))[:24]
+}
+
+// IsAnonymousAuthorID 判断作者ID是否由本站匿名身份生成
+func IsAnonymousAuthorID(authorID string) bool {
+	return strings.HasPrefix(authorID, authorIDPrefix)
+}
+
+// Pseudonym 根据作者ID推导帖子内昵称
+// attempt 用于在同一帖子内发生昵称冲突时依次尝试下一个候选
+func Pseudonym(authorID string, attempt int) string {
+	raw, err := hex.DecodeString(strings.TrimPrefix(authorID, authorIDPrefix))
+	var seed uint32
+	if err == nil && len(raw) >= 4 {
+		seed = binary.BigEndian.Uint32(raw[:4])
+	}
+
+	n := len(pseudonyms)
+	name := pseudonyms[(int(seed%uint32(n))+attempt)%n]
+	if round := attempt / n; round > 0 {
+		name += strconv.Itoa(round + 1)
+	}
+	return name
+}
+
+// sign 计算带用途前缀的 HMAC
+func (m *Manager) sign(purpose, value string) string {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(purpose + ":" + value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
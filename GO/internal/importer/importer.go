@@ -0,0 +1,574 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+	"treehole/internal/bookmark"
+	"treehole/internal/classifier"
+	"treehole/internal/database"
+	"treehole/internal/dedup"
+	"treehole/internal/export"
+	"treehole/internal/metrics"
+	"treehole/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 冲突处理策略，已有记录的内容与导入的不同时使用
+const (
+	PolicyNewer     = "newer"     // 更新时间较晚的一方为准
+	PolicyKeep      = "keep"      // 保留已有内容
+	PolicyOverwrite = "overwrite" // 使用导入的内容
+)
+
+// maxConflicts 报告中最多列出的冲突数量，超出的只计数
+const maxConflicts = 1000
+
+// ErrRunning 已有导入任务在运行
+var ErrRunning = errors.New("import is already running")
+
+// ErrInvalidPolicy 不支持的冲突处理策略
+var ErrInvalidPolicy = errors.New("policy must be newer, keep or overwrite")
+
+// errDryRun 试运行时回滚事务
+var errDryRun = errors.New("dry run")
+
+// Options 导入选项
+type Options struct {
+	Policy string // 冲突处理策略，默认 newer
+	DryRun bool   // 只生成报告，不写入数据库
+}
+
+// Conflict 已有记录与导入内容不一致
+type Conflict struct {
+	Line       int      `json:"line"`
+	Type       string   `json:"type"` // post, reply
+	OriginalID string   `json:"original_id"`
+	LocalID    uint     `json:"local_id"`
+	Fields     []string `json:"fields"`
+	Resolution string   `json:"resolution"` // kept, overwritten
+}
+
+// LineError 无法导入的行
+type LineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// Report 导入结果
+type Report struct {
+	DryRun            bool        `json:"dry_run"`
+	Policy            string      `json:"policy"`
+	Lines             int         `json:"lines"`
+	PostsCreated      int         `json:"posts_created"`
+	PostsUpdated      int         `json:"posts_updated"`
+	PostsUnchanged    int         `json:"posts_unchanged"`
+	RepliesCreated    int         `json:"replies_created"`
+	RepliesUpdated    int         `json:"replies_updated"`
+	RepliesUnchanged  int         `json:"replies_unchanged"`
+	UnresolvedParents int         `json:"unresolved_parents"` // 父回复不在导入文件和数据库中，parent_id 置为 0
+	ConflictCount     int         `json:"conflict_count"`
+	Conflicts         []Conflict  `json:"conflicts"`
+	Errors            []LineError `json:"errors"`
+	Duration          string      `json:"duration"`
+}
+
+// Service 导入服务
+type Service struct {
+	db      *gorm.DB
+	running sync.Mutex
+}
+
+// NewService 创建导入服务
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// ValidPolicy 检查冲突处理策略
+func ValidPolicy(policy string) bool {
+	return policy == PolicyNewer || policy == PolicyKeep || policy == PolicyOverwrite
+}
+
+// Import 逐行读取 JSONL 导出文件（每行一个帖子，回复嵌套在 replies 中）并写入数据库
+// 帖子和回复按 original_id 匹配已有记录，本地发布的内容没有 original_id，按作者、标题或内容和发布时间匹配
+// 每个帖子及其回复在一个事务中写入，单行失败不影响其他行
+func (s *Service) Import(r io.Reader, opts Options) (*Report, error) {
+	if opts.Policy == "" {
+		opts.Policy = PolicyNewer
+	}
+	if !ValidPolicy(opts.Policy) {
+		return nil, ErrInvalidPolicy
+	}
+	if !s.running.TryLock() {
+		return nil, ErrRunning
+	}
+	defer s.running.Unlock()
+
+	start := time.Now()
+	report := &Report{DryRun: opts.DryRun, Policy: opts.Policy, Conflicts: []Conflict{}, Errors: []LineError{}}
+	ix, err := classifier.LoadTagIndex(s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(r)
+	for line := 1; ; line++ {
+		data, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return nil, readErr
+		}
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			report.Lines++
+			if err := s.importLine(ix, line, data, opts, report); err != nil {
+				report.Errors = append(report.Errors, LineError{Line: line, Error: err.Error()})
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	report.Duration = time.Since(start).Round(time.Millisecond).String()
+	log.Printf("Import finished: %d lines, %d posts created, %d updated, %d replies created, %d updated, %d conflicts, %d errors (dry run: %v)",
+		report.Lines, report.PostsCreated, report.PostsUpdated, report.RepliesCreated, report.RepliesUpdated,
+		report.ConflictCount, len(report.Errors), opts.DryRun)
+	return report, nil
+}
+
+// importLine 导入一行，成功后才把本行的统计合并到报告中
+func (s *Service) importLine(ix *classifier.TagIndex, line int, data []byte, opts Options, report *Report) error {
+	var record export.PostRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	post := record.Post
+	if post.Title == "" && post.Content == "" {
+		return errors.New("post has no title or content")
+	}
+	post.DeletedAt = deletedAt(record.DeletedAt)
+	normalizeTimes(&post.CreatedAt, &post.UpdatedAt, &post.DeletedAt.Time)
+	replies := record.Replies
+	for i := range replies {
+		replies[i].Reply.DeletedAt = deletedAt(replies[i].DeletedAt)
+		normalizeTimes(&replies[i].Reply.CreatedAt, &replies[i].Reply.UpdatedAt, &replies[i].Reply.DeletedAt.Time)
+	}
+
+	var result *Report
+	err := database.SafeTransaction(s.db, func(tx *gorm.DB) error {
+		result = &Report{}
+		if err := importPost(tx, ix, line, &post, replies, opts.Policy, result); err != nil {
+			return err
+		}
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return err
+	}
+	report.merge(result)
+	return nil
+}
+
+// merge 合并一行的统计
+func (r *Report) merge(line *Report) {
+	r.PostsCreated += line.PostsCreated
+	r.PostsUpdated += line.PostsUpdated
+	r.PostsUnchanged += line.PostsUnchanged
+	r.RepliesCreated += line.RepliesCreated
+	r.RepliesUpdated += line.RepliesUpdated
+	r.RepliesUnchanged += line.RepliesUnchanged
+	r.UnresolvedParents += line.UnresolvedParents
+	for _, conflict := range line.Conflicts {
+		r.ConflictCount++
+		if len(r.Conflicts) < maxConflicts {
+			r.Conflicts = append(r.Conflicts, conflict)
+		}
+	}
+}
+
+// importPost 写入帖子及其回复，最后按实际回复数重算 reply_count
+func importPost(tx *gorm.DB, ix *classifier.TagIndex, line int, incoming *models.Post, replies []export.ReplyRecord, policy string, report *Report) error {
+	incoming.Replies = nil
+
+	existing, err := findPost(tx, incoming)
+	if err != nil {
+		return err
+	}
+	var post *models.Post
+	if existing == nil {
+		post, err = createPost(tx, ix, incoming)
+		if err != nil {
+			return err
+		}
+		report.PostsCreated++
+	} else {
+		post = existing
+		changed, err := updatePost(tx, line, post, incoming, policy, report)
+		if err != nil {
+			return err
+		}
+		if changed {
+			report.PostsUpdated++
+		} else {
+			report.PostsUnchanged++
+		}
+	}
+
+	if err := importReplies(tx, line, post.ID, replies, policy, report); err != nil {
+		return err
+	}
+
+	var count int64
+	if err := tx.Model(&models.Reply{}).Where("post_id = ?", post.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Model(&models.Post{}).Where("id = ?", post.ID).UpdateColumn("reply_count", count).Error
+}
+
+// findPost 查找与导入帖子对应的已有帖子，包括被管理员隐藏的帖子
+func findPost(tx *gorm.DB, incoming *models.Post) (*models.Post, error) {
+	var candidates []models.Post
+	if hasOriginalID(incoming.OriginalID) {
+		if err := tx.Unscoped().Where("original_id = ?", incoming.OriginalID).Order("id asc").Limit(1).Find(&candidates).Error; err != nil {
+			return nil, err
+		}
+		if len(candidates) == 0 {
+			return nil, nil
+		}
+		return &candidates[0], nil
+	}
+
+	if err := tx.Unscoped().
+		Where("original_id IN ? AND author_id = ? AND title = ?", []string{"", "0"}, incoming.AuthorID, incoming.Title).
+		Order("id asc").
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+	for i := range candidates {
+		if sameTime(candidates[i].CreatedAt, incoming.CreatedAt) {
+			return &candidates[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// createPost 创建帖子，保留来源的隐藏状态，人工标注的标签保留，其余交由自动分类重新处理
+func createPost(tx *gorm.DB, ix *classifier.TagIndex, incoming *models.Post) (*models.Post, error) {
+	post := *incoming
+	post.ID = 0
+	post.LocalLikeNum = 0
+	post.DuplicateOf = nil
+	if !hasOriginalID(post.OriginalID) {
+		post.OriginalID = "0"
+	}
+	manualTags := classifier.SplitTags(post.Tag)
+	if post.TagSource != classifier.SourceManual || len(manualTags) == 0 {
+		post.Tag = classifier.Untagged
+		post.TagSource = ""
+		manualTags = nil
+	}
+	if post.CreatedAt.IsZero() {
+		post.CreatedAt = time.Now()
+	}
+
+	if err := tx.Create(&post).Error; err != nil {
+		return nil, err
+	}
+	if len(manualTags) > 0 {
+		names, err := classifier.SetPostTags(tx, ix, post.ID, manualTags)
+		if err != nil {
+			return nil, err
+		}
+		post.Tag = classifier.JoinTags(names)
+		if err := tx.Model(&models.Post{}).Where("id = ?", post.ID).UpdateColumn("tag", post.Tag).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := metrics.Record(tx, &post); err != nil {
+		return nil, err
+	}
+	if err := dedup.Assign(tx, &post); err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+// updatePost 合并导入的帖子，返回是否有修改
+// 计数取较大值，作者ID、IP和微信号只补全空值，来源隐藏时一并隐藏，内容字段不一致时记录冲突并按策略处理
+func updatePost(tx *gorm.DB, line int, post, incoming *models.Post, policy string, report *Report) (bool, error) {
+	changed := mergeHidden(&post.DeletedAt, incoming.DeletedAt)
+	if incoming.LikeNum > post.LikeNum {
+		post.LikeNum = incoming.LikeNum
+		changed = true
+	}
+	if incoming.ViewCount > post.ViewCount {
+		post.ViewCount = incoming.ViewCount
+		changed = true
+	}
+	for _, field := range []struct{ current, value *string }{
+		{&post.AuthorID, &incoming.AuthorID},
+		{&post.IP, &incoming.IP},
+		{&post.Wechat, &incoming.Wechat},
+	} {
+		if *field.current == "" && *field.value != "" {
+			*field.current = *field.value
+			changed = true
+		}
+	}
+
+	fields := []struct {
+		name           string
+		current, value *string
+	}{
+		{"title", &post.Title, &incoming.Title},
+		{"content", &post.Content, &incoming.Content},
+		{"author", &post.Author, &incoming.Author},
+		{"state", &post.State, &incoming.State},
+		{"images", &post.Images, &incoming.Images},
+		{"cover", &post.Cover, &incoming.Cover},
+		{"radio_group", &post.RadioGroup, &incoming.RadioGroup},
+		{"campus_group", &post.CampusGroup, &incoming.CampusGroup},
+		{"region", &post.Region, &incoming.Region},
+		{"price", &post.Price, &incoming.Price},
+	}
+	var differing []string
+	for _, field := range fields {
+		if *field.current != *field.value {
+			differing = append(differing, field.name)
+		}
+	}
+
+	contentChanged := false
+	if len(differing) > 0 {
+		overwrite := policy == PolicyOverwrite || (policy == PolicyNewer && incoming.UpdatedAt.After(post.UpdatedAt))
+		conflict := Conflict{Line: line, Type: "post", OriginalID: post.OriginalID, LocalID: post.ID, Fields: differing, Resolution: "kept"}
+		if overwrite {
+			conflict.Resolution = "overwritten"
+			contentChanged = post.Title != incoming.Title || post.Content != incoming.Content
+			for _, field := range fields {
+				*field.current = *field.value
+			}
+			changed = true
+		}
+		report.Conflicts = append(report.Conflicts, conflict)
+	}
+	if !changed {
+		return false, nil
+	}
+
+	if contentChanged && post.TagSource != classifier.SourceManual {
+		post.Tag = classifier.Untagged
+		post.TagSource = ""
+	}
+	// 本站点赞数由点赞接口原子递增，不随整行写回
+	if err := tx.Unscoped().Omit("local_like_num").Save(post).Error; err != nil {
+		return false, err
+	}
+	if err := metrics.Record(tx, post); err != nil {
+		return false, err
+	}
+	if err := dedup.Assign(tx, post); err != nil {
+		return false, err
+	}
+	return true, bookmark.Refresh(tx, post)
+}
+
+// importReplies 写入帖子的回复，保留来源的隐藏状态
+// 导出文件中的 parent_id 是来源实例的本地ID，先写入全部回复建立ID映射，再通过映射解析父回复；
+// 父回复不在文件中时按导出的 parent_original_id 在数据库中查找
+func importReplies(tx *gorm.DB, line int, postID uint, records []export.ReplyRecord, policy string, report *Report) error {
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Reply.ID < records[j].Reply.ID })
+	localIDs := make(map[uint]uint, len(records))
+	targets := make([]*models.Reply, len(records))
+
+	for i := range records {
+		incoming := &records[i].Reply
+
+		existing, err := findReply(tx, postID, incoming)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			reply := *incoming
+			reply.ID = 0
+			reply.PostID = postID
+			reply.ParentID = 0
+			reply.LocalLikeNum = 0
+			reply.Post = models.Post{}
+			if reply.TagSource != classifier.SourceManual || reply.Tag == "" {
+				reply.Tag = classifier.Untagged
+				reply.TagSource = ""
+			}
+			if reply.CreatedAt.IsZero() {
+				reply.CreatedAt = time.Now()
+			}
+			if err := tx.Create(&reply).Error; err != nil {
+				return err
+			}
+			targets[i] = &reply
+			report.RepliesCreated++
+		} else {
+			changed, err := updateReply(tx, line, existing, incoming, policy, report)
+			if err != nil {
+				return err
+			}
+			targets[i] = existing
+			if changed {
+				report.RepliesUpdated++
+			} else {
+				report.RepliesUnchanged++
+			}
+		}
+		localIDs[incoming.ID] = targets[i].ID
+	}
+
+	for i := range records {
+		incoming := &records[i].Reply
+		if incoming.ParentID <= 0 {
+			continue
+		}
+		parentID, ok := localIDs[uint(incoming.ParentID)]
+		if !ok && records[i].ParentOriginalID != "" {
+			var ids []uint
+			if err := tx.Unscoped().Model(&models.Reply{}).
+				Where("post_id = ? AND original_id = ?", postID, records[i].ParentOriginalID).
+				Limit(1).Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			if len(ids) > 0 {
+				parentID, ok = ids[0], true
+			}
+		}
+		if !ok {
+			report.UnresolvedParents++
+			continue
+		}
+		if targets[i].ParentID == int(parentID) {
+			continue
+		}
+		if err := tx.Unscoped().Model(&models.Reply{}).Where("id = ?", targets[i].ID).UpdateColumn("parent_id", parentID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findReply 查找帖子下与导入回复对应的已有回复
+func findReply(tx *gorm.DB, postID uint, incoming *models.Reply) (*models.Reply, error) {
+	var candidates []models.Reply
+	if incoming.OriginalID != "" {
+		if err := tx.Unscoped().Where("post_id = ? AND original_id = ?", postID, incoming.OriginalID).
+			Order("id asc").Limit(1).Find(&candidates).Error; err != nil {
+			return nil, err
+		}
+		if len(candidates) == 0 {
+			return nil, nil
+		}
+		return &candidates[0], nil
+	}
+
+	if err := tx.Unscoped().
+		Where("post_id = ? AND original_id = '' AND author_id = ? AND content = ?", postID, incoming.AuthorID, incoming.Content).
+		Order("id asc").
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+	for i := range candidates {
+		if sameTime(candidates[i].CreatedAt, incoming.CreatedAt) {
+			return &candidates[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// updateReply 合并导入的回复，规则与帖子相同
+func updateReply(tx *gorm.DB, line int, reply, incoming *models.Reply, policy string, report *Report) (bool, error) {
+	changed := mergeHidden(&reply.DeletedAt, incoming.DeletedAt)
+	if incoming.LikeNum > reply.LikeNum {
+		reply.LikeNum = incoming.LikeNum
+		changed = true
+	}
+	for _, field := range []struct{ current, value *string }{
+		{&reply.AuthorID, &incoming.AuthorID},
+		{&reply.ApplyTo, &incoming.ApplyTo},
+	} {
+		if *field.current == "" && *field.value != "" {
+			*field.current = *field.value
+			changed = true
+		}
+	}
+
+	var differing []string
+	if reply.Content != incoming.Content {
+		differing = append(differing, "content")
+	}
+	if reply.Images != incoming.Images {
+		differing = append(differing, "images")
+	}
+	if len(differing) > 0 {
+		overwrite := policy == PolicyOverwrite || (policy == PolicyNewer && incoming.UpdatedAt.After(reply.UpdatedAt))
+		conflict := Conflict{Line: line, Type: "reply", OriginalID: reply.OriginalID, LocalID: reply.ID, Fields: differing, Resolution: "kept"}
+		if overwrite {
+			conflict.Resolution = "overwritten"
+			if reply.Content != incoming.Content && reply.TagSource != classifier.SourceManual {
+				reply.Tag = classifier.Untagged
+				reply.TagSource = ""
+			}
+			reply.Content = incoming.Content
+			reply.Images = incoming.Images
+			changed = true
+		}
+		report.Conflicts = append(report.Conflicts, conflict)
+	}
+	if !changed {
+		return false, nil
+	}
+	return true, tx.Unscoped().Omit("Post", "local_like_num").Save(reply).Error
+}
+
+// mergeHidden 来源中被隐藏的内容在本地也隐藏，本地已隐藏的内容不会因导入而恢复，返回是否有修改
+func mergeHidden(current *gorm.DeletedAt, incoming gorm.DeletedAt) bool {
+	if current.Valid || !incoming.Valid {
+		return false
+	}
+	*current = incoming
+	return true
+}
+
+// deletedAt 把导出记录中的隐藏时间转换为软删除字段
+func deletedAt(t *time.Time) gorm.DeletedAt {
+	if t == nil {
+		return gorm.DeletedAt{}
+	}
+	return gorm.DeletedAt{Time: *t, Valid: true}
+}
+
+// hasOriginalID 是否为同步自主站的内容，本地发布的帖子 original_id 为 0
+func hasOriginalID(originalID string) bool {
+	return originalID != "" && originalID != "0"
+}
+
+// sameTime 比较发布时间，忽略不同数据库保存精度的差异
+func sameTime(a, b time.Time) bool {
+	return a.Truncate(time.Second).Equal(b.Truncate(time.Second))
+}
+
+// normalizeTimes 把导入的时间转换为本地时区
+// JSON 中的固定偏移时区没有名称，SQLite 驱动写入后无法再解析
+func normalizeTimes(times ...*time.Time) {
+	for _, t := range times {
+		if !t.IsZero() {
+			*t = t.In(time.Local)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"treehole/internal/database"
+	"treehole/internal/export"
+	"treehole/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB 在临时目录中创建迁移好的 SQLite 数据库
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// seed 写入两个帖子：帖子 100 下有一条隐藏的回复 1002，其子回复 1003 可见；帖子 200 被隐藏
+func seed(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	post := models.Post{OriginalID: "100", Title: "出自行车", Content: "九成新，价格可议", AuthorID: "author-a", State: "normal"}
+	hiddenPost := models.Post{OriginalID: "200", Title: "广告", Content: "加微信领红包", AuthorID: "author-b", State: "normal"}
+	mustCreate(t, db, &post, &hiddenPost)
+
+	r1 := models.Reply{PostID: post.ID, OriginalID: "1001", Content: "多少钱", AuthorID: "author-c"}
+	mustCreate(t, db, &r1)
+	r2 := models.Reply{PostID: post.ID, OriginalID: "1002", Content: "违规内容", AuthorID: "author-d", ParentID: int(r1.ID)}
+	mustCreate(t, db, &r2)
+	r3 := models.Reply{PostID: post.ID, OriginalID: "1003", Content: "楼上说什么了", AuthorID: "author-e", ParentID: int(r2.ID)}
+	r4 := models.Reply{PostID: hiddenPost.ID, OriginalID: "2001", Content: "举报了", AuthorID: "author-f"}
+	mustCreate(t, db, &r3, &r4)
+
+	if err := db.Delete(&r2).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete(&hiddenPost).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustCreate(t *testing.T, db *gorm.DB, values ...interface{}) {
+	t.Helper()
+	for _, value := range values {
+		if err := db.Create(value).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// exportJSONL 导出为 JSONL
+func exportJSONL(t *testing.T, db *gorm.DB, includeHidden bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := export.WriteJSONL(db, &buf, export.Options{IncludeHidden: includeHidden}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// importJSONL 导入 JSONL，行错误视为测试失败
+func importJSONL(t *testing.T, db *gorm.DB, data []byte) *Report {
+	t.Helper()
+	report, err := NewService(db).Import(bytes.NewReader(data), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Errors) > 0 {
+		t.Fatalf("import errors: %+v", report.Errors)
+	}
+	return report
+}
+
+// summarize 按 original_id 概括导出内容的隐藏状态和父回复，与实例的本地ID无关
+func summarize(t *testing.T, data []byte) []string {
+	t.Helper()
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var record export.PostRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, fmt.Sprintf("post %s hidden=%v", record.OriginalID, record.DeletedAt != nil))
+		for _, reply := range record.Replies {
+			lines = append(lines, fmt.Sprintf("reply %s hidden=%v parent=%s", reply.OriginalID, reply.DeletedAt != nil, reply.ParentOriginalID))
+		}
+	}
+	return lines
+}
+
+func TestRoundTripKeepsHiddenContent(t *testing.T) {
+	source := openTestDB(t)
+	seed(t, source)
+	data := exportJSONL(t, source, true)
+
+	want := []string{
+		"post 100 hidden=false",
+		"reply 1001 hidden=false parent=",
+		"reply 1002 hidden=true parent=1001",
+		"reply 1003 hidden=false parent=1002",
+		"post 200 hidden=true",
+		"reply 2001 hidden=false parent=",
+	}
+	if got := summarize(t, data); !reflect.DeepEqual(got, want) {
+		t.Fatalf("source export = %v, want %v", got, want)
+	}
+
+	target := openTestDB(t)
+	report := importJSONL(t, target, data)
+	if report.PostsCreated != 2 || report.RepliesCreated != 4 || report.UnresolvedParents != 0 {
+		t.Errorf("report = %+v, want 2 posts and 4 replies created", report)
+	}
+	if got := summarize(t, exportJSONL(t, target, true)); !reflect.DeepEqual(got, want) {
+		t.Errorf("target export = %v, want %v", got, want)
+	}
+
+	var post models.Post
+	if err := target.Where("original_id = ?", "100").First(&post).Error; err != nil {
+		t.Fatal(err)
+	}
+	if post.ReplyCount != 2 {
+		t.Errorf("reply_count = %d, want 2 visible replies", post.ReplyCount)
+	}
+	var visible int64
+	target.Model(&models.Post{}).Count(&visible)
+	if visible != 1 {
+		t.Errorf("visible posts = %d, want 1", visible)
+	}
+
+	again := importJSONL(t, target, data)
+	if again.PostsCreated != 0 || again.RepliesCreated != 0 || again.PostsUpdated != 0 || again.RepliesUpdated != 0 {
+		t.Errorf("second import = %+v, want nothing created or updated", again)
+	}
+}
+
+func TestImportResolvesParentByOriginalID(t *testing.T) {
+	source := openTestDB(t)
+	seed(t, source)
+
+	// 不含隐藏内容的导出中没有回复 1002，1003 只能按 parent_original_id 查找父回复
+	visibleOnly := exportJSONL(t, source, false)
+
+	target := openTestDB(t)
+	importJSONL(t, target, exportJSONL(t, source, true))
+	report := importJSONL(t, target, visibleOnly)
+	if report.UnresolvedParents != 0 {
+		t.Errorf("unresolved parents = %d, want 0", report.UnresolvedParents)
+	}
+	var parent, child models.Reply
+	if err := target.Unscoped().Where("original_id = ?", "1002").First(&parent).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := target.Where("original_id = ?", "1003").First(&child).Error; err != nil {
+		t.Fatal(err)
+	}
+	if child.ParentID != int(parent.ID) {
+		t.Errorf("parent_id = %d, want %d", child.ParentID, parent.ID)
+	}
+
+	empty := openTestDB(t)
+	if report := importJSONL(t, empty, visibleOnly); report.UnresolvedParents != 1 {
+		t.Errorf("unresolved parents in empty database = %d, want 1", report.UnresolvedParents)
+	}
+}
+
+func TestImportMergesHiddenState(t *testing.T) {
+	source := openTestDB(t)
+	seed(t, source)
+	target := openTestDB(t)
+	importJSONL(t, target, exportJSONL(t, source, true))
+
+	// 来源中隐藏的内容在本地也隐藏
+	if err := source.Where("original_id = ?", "100").Delete(&models.Post{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	report := importJSONL(t, target, exportJSONL(t, source, true))
+	if report.PostsUpdated != 1 {
+		t.Errorf("posts updated = %d, want 1", report.PostsUpdated)
+	}
+	var count int64
+	target.Model(&models.Post{}).Where("original_id = ?", "100").Count(&count)
+	if count != 0 {
+		t.Errorf("post 100 is still visible after importing it hidden")
+	}
+
+	// 本地隐藏的内容不会因导入可见的版本而恢复
+	if err := source.Unscoped().Model(&models.Post{}).Where("original_id IN ?", []string{"100", "200"}).
+		Update("deleted_at", nil).Error; err != nil {
+		t.Fatal(err)
+	}
+	importJSONL(t, target, exportJSONL(t, source, true))
+	target.Model(&models.Post{}).Count(&count)
+	if count != 0 {
+		t.Errorf("visible posts = %d after importing visible versions, want 0", count)
+	}
+}
@@ -0,0 +1,251 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+)
+
+// errInvalidGIF GIF 块结构无法解析
+var errInvalidGIF = errors.New("invalid gif")
+
+// thumbnail 按比例缩小图片，最长边不超过 maxSize
+// 使用区域平均采样，透明区域以白色填充，便于统一编码为 JPEG
+func thumbnail(src image.Image, maxSize int) image.Image {
+	rgba := toRGBA(src)
+	bounds := rgba.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	tw, th := w, h
+	if w > maxSize || h > maxSize {
+		if w >= h {
+			tw, th = maxSize, h*maxSize/w
+		} else {
+			tw, th = w*maxSize/h, maxSize
+		}
+	}
+	if tw < 1 {
+		tw = 1
+	}
+	if th < 1 {
+		th = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for y := 0; y < th; y++ {
+		y0 := y * h / th
+		y1 := (y + 1) * h / th
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < tw; x++ {
+			x0 := x * w / tw
+			x1 := (x + 1) * w / tw
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				offset := rgba.PixOffset(bounds.Min.X+x0, bounds.Min.Y+sy)
+				for sx := x0; sx < x1; sx++ {
+					r += uint32(rgba.Pix[offset])
+					g += uint32(rgba.Pix[offset+1])
+					b += uint32(rgba.Pix[offset+2])
+					a += uint32(rgba.Pix[offset+3])
+					offset += 4
+					n++
+				}
+			}
+			// 颜色是预乘透明度的，叠加白底只需补上透明部分
+			white := 0xff - a/n
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r/n + white)
+			dst.Pix[i+1] = uint8(g/n + white)
+			dst.Pix[i+2] = uint8(b/n + white)
+			dst.Pix[i+3] = 0xff
+		}
+	}
+
+	return dst
+}
+
+// applyOrientation 按 EXIF 方向标记旋转或翻转图片
+func applyOrientation(src image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	rgba := toRGBA(src)
+	bounds := rgba.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	// 5-8 需要交换宽高
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 水平翻转
+				dx, dy = w-1-x, y
+			case 3: // 旋转180度
+				dx, dy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				dx, dy = x, h-1-y
+			case 5: // 沿左上-右下对角线翻转
+				dx, dy = y, x
+			case 6: // 顺时针旋转90度
+				dx, dy = h-1-y, x
+			case 7: // 沿右上-左下对角线翻转
+				dx, dy = h-1-y, w-1-x
+			case 8: // 逆时针旋转90度
+				dx, dy = y, w-1-x
+			}
+			i := rgba.PixOffset(bounds.Min.X+x, bounds.Min.Y+y)
+			j := dst.PixOffset(dx, dy)
+			copy(dst.Pix[j:j+4], rgba.Pix[i:i+4])
+		}
+	}
+
+	return dst
+}
+
+// toRGBA 转换为 RGBA 图像以便直接读取像素，已是 RGBA 的图像不再复制
+func toRGBA(src image.Image) *image.RGBA {
+	if rgba, ok := src.(*image.RGBA); ok {
+		return rgba
+	}
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(bounds)
+	draw.Draw(rgba, bounds, src, bounds.Min, draw.Src)
+	return rgba
+}
+
+// gifPixels 不解码图像数据，按块结构统计 GIF 的帧数，返回解码所有帧需要的像素总数
+// 每帧按画布和帧自身中较大的面积计算，帧数超过 maxFrames 时返回 ErrTooManyFrames
+func gifPixels(data []byte, screen int64, maxFrames int) (int64, error) {
+	// 文件头 6 字节，逻辑屏幕描述符 7 字节，其后可能有全局颜色表
+	if len(data) < 13 {
+		return 0, errInvalidGIF
+	}
+	pos := 13
+	if data[10]&0x80 != 0 {
+		pos += 3 << (data[10]&0x07 + 1)
+	}
+
+	var pixels int64
+	frames := 0
+	for pos < len(data) {
+		switch data[pos] {
+		case 0x21: // 扩展块：标签之后是数据子块
+			pos = skipSubBlocks(data, pos+2)
+		case 0x2C: // 图像描述符
+			if pos+10 > len(data) {
+				return 0, errInvalidGIF
+			}
+			frames++
+			if frames > maxFrames {
+				return 0, ErrTooManyFrames
+			}
+			width := int64(binary.LittleEndian.Uint16(data[pos+5 : pos+7]))
+			height := int64(binary.LittleEndian.Uint16(data[pos+7 : pos+9]))
+			pixels += max(screen, width*height)
+			packed := data[pos+9]
+			pos += 10
+			if packed&0x80 != 0 {
+				pos += 3 << (packed&0x07 + 1)
+			}
+			// 跳过 LZW 最小码长和压缩数据
+			pos = skipSubBlocks(data, pos+1)
+		case 0x3B: // 文件结束
+			return pixels, nil
+		default:
+			return 0, errInvalidGIF
+		}
+	}
+	// 文件被截断时由解码器报错
+	return pixels, nil
+}
+
+// skipSubBlocks 跳过从 pos 开始的数据子块序列，返回终止块之后的位置
+func skipSubBlocks(data []byte, pos int) int {
+	for pos < len(data) {
+		size := int(data[pos])
+		pos++
+		if size == 0 {
+			return pos
+		}
+		pos += size
+	}
+	return len(data)
+}
+
+// jpegOrientation 读取 JPEG 中 EXIF 的方向标记，读取失败时返回 1
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		// 到达图像数据部分，之后不会再有 EXIF
+		if marker == 0xDA || marker == 0xD9 {
+			return 1
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		if length < 2 || pos+2+length > len(data) {
+			return 1
+		}
+		segment := data[pos+4 : pos+2+length]
+		if marker == 0xE1 && len(segment) > 6 && string(segment[:6]) == "Exif\x00\x00" {
+			return exifOrientation(segment[6:])
+		}
+		pos += 2 + length
+	}
+
+	return 1
+}
+
+// exifOrientation 在 TIFF 结构的 IFD0 中查找方向标记 (0x0112)
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset : offset+2]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8 : entry+10]))
+		}
+	}
+
+	return 1
+}
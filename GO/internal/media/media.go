@@ -0,0 +1,228 @@
+package media
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// 上传校验错误
+var (
+	ErrUnsupportedType = errors.New("unsupported image type, only JPEG, PNG and GIF are allowed")
+	ErrTooLarge        = errors.New("image file is too large")
+	ErrDimensions      = errors.New("image dimensions exceed the allowed limit")
+	ErrTooManyFrames   = errors.New("animated image has too many frames")
+)
+
+// thumbnailSize 缩略图最长边像素
+const thumbnailSize = 320
+
+// 支持的图片类型及对应扩展名
+var allowedTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// Limits 上传限制
+type Limits struct {
+	MaxBytes     int64 // 单张图片最大字节数
+	MaxDimension int   // 单边最大像素
+	MaxPixels    int64 // 单张图片解码的像素总数上限，动图按帧累计
+	MaxFrames    int   // 动图最大帧数
+}
+
+// Store 本地媒体存储
+// 文件按内容哈希存放，相同图片只保存一份
+type Store struct {
+	dir     string
+	baseURL string
+	limits  Limits
+}
+
+// Stored 处理并保存后的图片信息
+type Stored struct {
+	Hash         string
+	MimeType     string
+	Width        int
+	Height       int
+	Size         int64
+	URL          string
+	ThumbnailURL string
+}
+
+// NewStore 创建媒体存储
+func NewStore(dir, baseURL string, limits Limits) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, baseURL: baseURL, limits: limits}, nil
+}
+
+// Dir 媒体文件根目录
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Save 校验、清理并保存一张图片，同时生成缩略图
+func (s *Store) Save(data []byte) (*Stored, error) {
+	if int64(len(data)) > s.limits.MaxBytes {
+		return nil, ErrTooLarge
+	}
+
+	// 根据文件内容判断类型，不信任客户端提供的 Content-Type
+	mimeType := http.DetectContentType(data)
+	ext, ok := allowedTypes[mimeType]
+	if !ok {
+		return nil, ErrUnsupportedType
+	}
+
+	// 解码前先检查尺寸，防止解压炸弹
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %v", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > s.limits.MaxDimension || cfg.Height > s.limits.MaxDimension {
+		return nil, ErrDimensions
+	}
+
+	// 动图的每一帧都会被解码，按帧累计像素，帧数和像素总数都要在解码前检查
+	pixels := int64(cfg.Width) * int64(cfg.Height)
+	if mimeType == "image/gif" {
+		if pixels, err = gifPixels(data, pixels, s.limits.MaxFrames); err != nil {
+			return nil, err
+		}
+	}
+	if pixels > s.limits.MaxPixels {
+		return nil, ErrDimensions
+	}
+
+	// 重新编码图片，去除 EXIF 等元数据
+	cleaned, preview, err := sanitize(data, mimeType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to process image: %v", err)
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	name := hash + ext
+	thumbName := hash + "_thumb.jpg"
+
+	if err := s.writeFile(hash[:2], name, cleaned); err != nil {
+		return nil, err
+	}
+
+	var thumb bytes.Buffer
+	if err := jpeg.Encode(&thumb, thumbnail(preview, thumbnailSize), &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	if err := s.writeFile(hash[:2], thumbName, thumb.Bytes()); err != nil {
+		return nil, err
+	}
+
+	bounds := preview.Bounds()
+	return &Stored{
+		Hash:         hash,
+		MimeType:     mimeType,
+		Width:        bounds.Dx(),
+		Height:       bounds.Dy(),
+		Size:         int64(len(cleaned)),
+		URL:          path.Join(s.baseURL, hash[:2], name),
+		ThumbnailURL: path.Join(s.baseURL, hash[:2], thumbName),
+	}, nil
+}
+
+// Remove 删除图片及其缩略图，用于保存记录失败后清理没有归属的文件
+// 文件按内容哈希共享，调用方需确认没有其他记录引用同一图片
+func (s *Store) Remove(item *Stored) error {
+	dir := filepath.Join(s.dir, item.Hash[:2])
+	var firstErr error
+	for _, name := range []string{item.Hash + allowedTypes[item.MimeType], item.Hash + "_thumb.jpg"} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// writeFile 原子写入文件，已存在则跳过
+func (s *Store) writeFile(subdir, name string, data []byte) error {
+	dir := filepath.Join(s.dir, subdir)
+	target := filepath.Join(dir, name)
+	if _, err := os.Stat(target); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, name+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// sanitize 解码后重新编码图片，返回清理后的数据和用于生成缩略图的图像
+func sanitize(data []byte, mimeType string) ([]byte, image.Image, error) {
+	var buf bytes.Buffer
+
+	switch mimeType {
+	case "image/jpeg":
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		// 丢弃 EXIF 前先按方向标记旋转，避免图片方向错误
+		img = applyOrientation(img, jpegOrientation(data))
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+			return nil, nil, err
+		}
+		return buf.Bytes(), img, nil
+
+	case "image/png":
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, nil, err
+		}
+		return buf.Bytes(), img, nil
+
+	case "image/gif":
+		// 保留动图的所有帧，只丢弃注释等扩展块
+		anim, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(anim.Image) == 0 {
+			return nil, nil, errors.New("empty gif")
+		}
+		if err := gif.EncodeAll(&buf, anim); err != nil {
+			return nil, nil, err
+		}
+		return buf.Bytes(), anim.Image[0], nil
+	}
+
+	return nil, nil, ErrUnsupportedType
+}
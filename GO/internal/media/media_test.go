@@ -0,0 +1,104 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"testing"
+)
+
+// encodeGIF 生成指定尺寸和帧数的动图
+func encodeGIF(t *testing.T, width, height, frames int) []byte {
+	t.Helper()
+	anim := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		anim.Image = append(anim.Image, image.NewPaletted(image.Rect(0, 0, width, height), palette.Plan9))
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSaveLimits(t *testing.T) {
+	var transparent bytes.Buffer
+	if err := png.Encode(&transparent, image.NewNRGBA(image.Rect(0, 0, 40, 20))); err != nil {
+		t.Fatal(err)
+	}
+
+	limits := Limits{MaxBytes: 1 << 20, MaxDimension: 100, MaxPixels: 10000, MaxFrames: 5}
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"png", transparent.Bytes(), nil},
+		{"gif within budget", encodeGIF(t, 40, 40, 5), nil},
+		{"too many frames", encodeGIF(t, 10, 10, 6), ErrTooManyFrames},
+		{"frames over pixel budget", encodeGIF(t, 60, 60, 3), ErrDimensions},
+		{"dimension over limit", encodeGIF(t, 101, 10, 1), ErrDimensions},
+		{"unsupported type", []byte("plain text"), ErrUnsupportedType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewStore(t.TempDir(), "/media", limits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = store.Save(tt.data)
+			if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Save() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGIFPixelsTruncated(t *testing.T) {
+	data := encodeGIF(t, 10, 10, 3)
+	if _, err := gifPixels(data[:12], 100, 10); !errors.Is(err, errInvalidGIF) {
+		t.Errorf("short header error = %v, want errInvalidGIF", err)
+	}
+	pixels, err := gifPixels(data, 100, 10)
+	if err != nil || pixels != 300 {
+		t.Errorf("gifPixels() = %d, %v, want 300", pixels, err)
+	}
+}
+
+func TestThumbnailTransparentIsWhite(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 640, 320))
+	for x := 0; x < 320; x++ {
+		for y := 0; y < 320; y++ {
+			src.Set(x, y, color.NRGBA{R: 0xff, A: 0xff})
+		}
+	}
+	thumb := thumbnail(src, 320)
+	if got := thumb.Bounds().Size(); got != image.Pt(320, 160) {
+		t.Fatalf("thumbnail size = %v, want 320x160", got)
+	}
+	if got := color.RGBAModel.Convert(thumb.At(0, 0)); got != (color.RGBA{R: 0xff, A: 0xff}) {
+		t.Errorf("opaque pixel = %v, want red", got)
+	}
+	if got := color.RGBAModel.Convert(thumb.At(319, 0)); got != (color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}) {
+		t.Errorf("transparent pixel = %v, want white", got)
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	src.Set(0, 0, color.RGBA{R: 0xff, A: 0xff})
+
+	rotated := applyOrientation(src, 6)
+	if got := rotated.Bounds().Size(); got != image.Pt(2, 3) {
+		t.Fatalf("rotated size = %v, want 2x3", got)
+	}
+	// 顺时针旋转 90 度后左上角移到右上角
+	if got := color.RGBAModel.Convert(rotated.At(1, 0)); got != (color.RGBA{R: 0xff, A: 0xff}) {
+		t.Errorf("rotated pixel = %v, want red", got)
+	}
+}
@@ -0,0 +1,228 @@
+package metrics
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+	"treehole/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 查询时的聚合粒度
+const (
+	IntervalRaw  = "raw"
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+)
+
+// deleteBatchSize 每次删除的快照数量
+const deleteBatchSize = 500
+
+// compactPostBatch 压缩时每批处理的帖子数量
+const compactPostBatch = 200
+
+// ErrRunning 已有压缩任务在运行
+var ErrRunning = errors.New("metrics compaction is already running")
+
+// Config 快照保留策略
+type Config struct {
+	HourlyAfter time.Duration // 超过该时间的快照每小时只保留一个
+	DailyAfter  time.Duration // 超过该时间的快照每天只保留一个
+	Retention   time.Duration // 超过该时间的快照删除，0 表示永久保留
+}
+
+// CompactResult 一次压缩任务的统计
+type CompactResult struct {
+	Downsampled int64 `json:"downsampled"`
+	Expired     int64 `json:"expired"`
+}
+
+// Service 帖子计数快照服务
+// 同步时记录计数快照，定期按时间降低旧快照的精度并清理过期数据
+type Service struct {
+	db     *gorm.DB
+	config Config
+
+	running sync.Mutex
+}
+
+// NewService 创建快照服务
+func NewService(db *gorm.DB, cfg Config) *Service {
+	return &Service{db: db, config: cfg}
+}
+
+// Record 记录帖子当前的点赞、回复和浏览数快照
+func Record(db *gorm.DB, post *models.Post) error {
+	return db.Create(&models.PostMetric{
+		PostID:     post.ID,
+		LikeNum:    post.LikeNum,
+		ReplyCount: post.ReplyCount,
+		ViewCount:  post.ViewCount,
+		CapturedAt: time.Now(),
+	}).Error
+}
+
+// Series 查询帖子自 since 以来的快照，interval 为 hour 或 day 时每个时间段只返回最后一个快照
+func (s *Service) Series(postID uint, since time.Time, interval string) ([]models.PostMetric, error) {
+	var snapshots []models.PostMetric
+	if err := s.db.Where("post_id = ? AND captured_at >= ?", postID, since).
+		Order("captured_at asc").
+		Find(&snapshots).Error; err != nil {
+		return nil, err
+	}
+
+	bucket := bucketSize(interval)
+	if bucket == 0 {
+		return snapshots, nil
+	}
+	result := make([]models.PostMetric, 0, len(snapshots))
+	for i, snapshot := range snapshots {
+		if i+1 < len(snapshots) && sameBucket(snapshot.CapturedAt, snapshots[i+1].CapturedAt, bucket) {
+			continue
+		}
+		result = append(result, snapshot)
+	}
+	return result, nil
+}
+
+// ValidInterval 检查聚合粒度
+func ValidInterval(interval string) bool {
+	return interval == IntervalRaw || interval == IntervalHour || interval == IntervalDay
+}
+
+// Compact 按保留策略压缩和清理快照，同一时间只允许一个任务运行
+func (s *Service) Compact() (*CompactResult, error) {
+	if !s.running.TryLock() {
+		return nil, ErrRunning
+	}
+	defer s.running.Unlock()
+
+	now := time.Now()
+	result := &CompactResult{}
+
+	// 先删除过期快照，剩余的再按小时和天降低精度
+	if s.config.Retention > 0 {
+		expired := s.db.Where("captured_at < ?", now.Add(-s.config.Retention)).Delete(&models.PostMetric{})
+		if expired.Error != nil {
+			return nil, expired.Error
+		}
+		result.Expired = expired.RowsAffected
+	}
+
+	if s.config.DailyAfter > 0 {
+		count, err := s.downsample(time.Time{}, now.Add(-s.config.DailyAfter), 24*time.Hour)
+		result.Downsampled += count
+		if err != nil {
+			return result, err
+		}
+	}
+	if s.config.HourlyAfter > 0 {
+		from := time.Time{}
+		if s.config.DailyAfter > 0 {
+			from = now.Add(-s.config.DailyAfter)
+		}
+		count, err := s.downsample(from, now.Add(-s.config.HourlyAfter), time.Hour)
+		result.Downsampled += count
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// CompactJob 供定时任务调用的压缩任务
+func (s *Service) CompactJob() {
+	result, err := s.Compact()
+	if errors.Is(err, ErrRunning) {
+		log.Println("Previous metrics compaction is still running, skipping this execution")
+		return
+	}
+	if err != nil {
+		log.Printf("Metrics compaction failed: %v", err)
+		return
+	}
+	if result.Downsampled > 0 || result.Expired > 0 {
+		log.Printf("Metrics compaction removed %d downsampled and %d expired snapshots", result.Downsampled, result.Expired)
+	}
+}
+
+// downsample 在 [from, to) 时间范围内，每个帖子每个时间段只保留最后一个快照
+func (s *Service) downsample(from, to time.Time, bucket time.Duration) (int64, error) {
+	scope := s.db.Model(&models.PostMetric{}).Where("captured_at < ?", to)
+	if !from.IsZero() {
+		scope = scope.Where("captured_at >= ?", from)
+	}
+
+	var postIDs []uint
+	if err := scope.Distinct().Order("post_id asc").Pluck("post_id", &postIDs).Error; err != nil {
+		return 0, err
+	}
+
+	var removed int64
+	for start := 0; start < len(postIDs); start += compactPostBatch {
+		end := start + compactPostBatch
+		if end > len(postIDs) {
+			end = len(postIDs)
+		}
+
+		var snapshots []models.PostMetric
+		query := s.db.Select("id, post_id, captured_at").
+			Where("post_id IN ? AND captured_at < ?", postIDs[start:end], to)
+		if !from.IsZero() {
+			query = query.Where("captured_at >= ?", from)
+		}
+		if err := query.Order("post_id asc, captured_at asc").Find(&snapshots).Error; err != nil {
+			return removed, err
+		}
+
+		var redundant []uint
+		for i, snapshot := range snapshots {
+			if i+1 < len(snapshots) && snapshots[i+1].PostID == snapshot.PostID &&
+				sameBucket(snapshot.CapturedAt, snapshots[i+1].CapturedAt, bucket) {
+				redundant = append(redundant, snapshot.ID)
+			}
+		}
+
+		for len(redundant) > 0 {
+			n := deleteBatchSize
+			if n > len(redundant) {
+				n = len(redundant)
+			}
+			result := s.db.Where("id IN ?", redundant[:n]).Delete(&models.PostMetric{})
+			if result.Error != nil {
+				return removed, result.Error
+			}
+			removed += result.RowsAffected
+			redundant = redundant[n:]
+		}
+	}
+	return removed, nil
+}
+
+// bucketSize 聚合粒度对应的时间段长度，raw 返回 0
+func bucketSize(interval string) time.Duration {
+	switch interval {
+	case IntervalHour:
+		return time.Hour
+	case IntervalDay:
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// sameBucket 两个时间是否落在同一个时间段内，按本地时间对齐
+func sameBucket(a, b time.Time, bucket time.Duration) bool {
+	return truncateLocal(a, bucket).Equal(truncateLocal(b, bucket))
+}
+
+// truncateLocal 按本地时区截断时间，使按天聚合以本地零点为界
+func truncateLocal(t time.Time, bucket time.Duration) time.Time {
+	t = t.Local()
+	if bucket >= 24*time.Hour {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+	return t.Truncate(bucket)
+}
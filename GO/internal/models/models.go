@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
@@ -8,49 +9,57 @@ import (
 
 // Post 树洞帖子模型
 type Post struct {
-	ID          uint           `json:"id" gorm:"primaryKey"`
-	OriginalID  string         `json:"original_id" gorm:"not null"`
-	Title       string         `json:"title"`
-	Content     string         `json:"content" gorm:"type:text"`
-	Author      string         `json:"author"`
-	AuthorID    string         `json:"author_id"` // openid
-	IP          string         `json:"ip"`
-	LikeNum     int            `json:"like_num" gorm:"default:0"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
-	ReplyCount  int            `json:"reply_count" gorm:"default:0"`
-	ViewCount   int            `json:"view_count" gorm:"default:0"`
-	RadioGroup  string         `json:"radio_group"` // 帖子分组
-	CampusGroup string         `json:"campus_group"` // 校区分组
-	Region      string         `json:"region"`
-	Price       string         `json:"price"`
-	Wechat      string         `json:"wechat"`
-	Images      string         `json:"images" gorm:"type:text"` // JSON 格式存储图片URL列表
-	Cover       string         `json:"cover"`
-	State       string         `json:"state"` // normal, deleted, complaint, chosen, hot
-	Tag         string         `json:"tag"` // 标签
-	Replies     []Reply        `json:"replies,omitempty"`
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	OriginalID   string         `json:"original_id" gorm:"not null"`
+	Title        string         `json:"title"`
+	Content      string         `json:"content" gorm:"type:text"`
+	Author       string         `json:"author"`
+	AuthorID     string         `json:"author_id"` // openid
+	IP           string         `json:"ip"`
+	LikeNum      int            `json:"like_num" gorm:"default:0"`
+	LocalLikeNum int            `json:"local_like_num" gorm:"default:0"` // 镜像站本地点赞数
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	ReplyCount   int            `json:"reply_count" gorm:"default:0"`
+	ViewCount    int            `json:"view_count" gorm:"default:0"`
+	RadioGroup   string         `json:"radio_group"`  // 帖子分组
+	CampusGroup  string         `json:"campus_group"` // 校区分组
+	Region       string         `json:"region"`
+	Price        string         `json:"price"`
+	Wechat       string         `json:"wechat"`
+	Images       string         `json:"images" gorm:"type:text"` // JSON 格式存储图片URL列表
+	Cover        string         `json:"cover"`
+	State        string         `json:"state"`                     // normal, deleted, complaint, chosen, hot
+	Tag          string         `json:"tag"`                       // 标签，多个标签以逗号分隔
+	TagSource    string         `json:"tag_source" gorm:"size:16"` // auto: 自动分类, manual: 人工标注
+	DuplicateOf  *uint          `json:"duplicate_of" gorm:"index"` // 内容重复时指向最早发布的帖子
+	Replies      []Reply        `json:"replies,omitempty"`
 }
 
+// UntaggedTag 尚未分类的内容在 tag 字段中的占位符
+const UntaggedTag = "未分析"
+
 // Reply 回复模型
 type Reply struct {
-	ID         uint           `json:"id" gorm:"primaryKey"`
-	PostID     uint           `json:"post_id" gorm:"not null"`
-	OriginalID string         `json:"original_id" gorm:"not null"`
-	Content    string         `json:"content" gorm:"type:text"`
-	Author     string         `json:"author"`
-	AuthorID   string         `json:"author_id"` // openid
-	ApplyTo    string         `json:"apply_to"`  // 回复给谁的 openid
-	Level      int            `json:"level" gorm:"default:1"` // 回复层级
-	ParentID   int            `json:"parent_id" gorm:"default:0"` // 父评论ID (pid)
-	LikeNum    int            `json:"like_num" gorm:"default:0"`
-	Images     string         `json:"images" gorm:"type:text"` // JSON 格式存储图片URL列表
-	Tag        string         `json:"tag"` // 标签
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
-	Post       Post           `json:"-" gorm:"foreignKey:PostID"`
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	PostID       uint           `json:"post_id" gorm:"not null"`
+	OriginalID   string         `json:"original_id" gorm:"not null"`
+	Content      string         `json:"content" gorm:"type:text"`
+	Author       string         `json:"author"`
+	AuthorID     string         `json:"author_id"`                  // openid
+	ApplyTo      string         `json:"apply_to"`                   // 回复给谁的 openid
+	Level        int            `json:"level" gorm:"default:1"`     // 回复层级
+	ParentID     int            `json:"parent_id" gorm:"default:0"` // 父评论ID (pid)
+	LikeNum      int            `json:"like_num" gorm:"default:0"`
+	LocalLikeNum int            `json:"local_like_num" gorm:"default:0"` // 镜像站本地点赞数
+	Images       string         `json:"images" gorm:"type:text"`         // JSON 格式存储图片URL列表
+	Tag          string         `json:"tag"`                             // 标签，多个标签以逗号分隔
+	TagSource    string         `json:"tag_source" gorm:"size:16"`       // auto: 自动分类, manual: 人工标注
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+	Post         Post           `json:"-" gorm:"foreignKey:PostID"`
 }
 
 // SyncStatus 同步状态模型
@@ -64,3 +73,307 @@ type SyncStatus struct {
 	ErrorMessage string    `json:"error_message,omitempty"`
 	CreatedAt    time.Time `json:"created_at"`
 }
+
+// Tag 标签，通过 ParentID 组成层级，别名在查询时解析为同一个标签
+type Tag struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"size:64;uniqueIndex;not null"`
+	Description string    `json:"description" gorm:"type:text"`
+	Aliases     string    `json:"aliases" gorm:"type:text"` // 逗号分隔的别名
+	ParentID    *uint     `json:"parent_id" gorm:"index"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PostTag 帖子和标签的多对多关联
+// 帖子的 tag 字段保存同样的标签名，作为列表展示用的冗余字段
+type PostTag struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	PostID    uint      `json:"post_id" gorm:"not null;uniqueIndex:idx_post_tags_post_tag"`
+	TagID     uint      `json:"tag_id" gorm:"not null;uniqueIndex:idx_post_tags_post_tag;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PostMetric 同步时记录的帖子计数快照，用于计算热度和增长趋势
+type PostMetric struct {
+	ID         uint      `json:"-" gorm:"primaryKey"`
+	PostID     uint      `json:"post_id" gorm:"not null;index:idx_post_metrics_post_time"`
+	LikeNum    int       `json:"like_num"`
+	ReplyCount int       `json:"reply_count"`
+	ViewCount  int       `json:"view_count"`
+	CapturedAt time.Time `json:"captured_at" gorm:"not null;index:idx_post_metrics_post_time;index"`
+}
+
+// TrendingPost 预先计算的热度排行，每个时间窗口保存一份
+type TrendingPost struct {
+	ID         uint      `json:"-" gorm:"primaryKey"`
+	Window     string    `json:"window" gorm:"column:time_window;size:16;not null;uniqueIndex:idx_trending_window_rank"` // window 和 rank 是 MySQL 保留字
+	Rank       int       `json:"rank" gorm:"column:ranking;not null;uniqueIndex:idx_trending_window_rank"`
+	PostID     uint      `json:"post_id" gorm:"not null;index"`
+	Score      float64   `json:"score"`
+	ReplyDelta int       `json:"reply_delta"`
+	LikeDelta  int       `json:"like_delta"`
+	ViewDelta  int       `json:"view_delta"`
+	ComputedAt time.Time `json:"computed_at"`
+}
+
+// PostFingerprint 帖子标题和内容的 SimHash 指纹
+// 64 位指纹按 16 位分为四段并分别建索引，汉明距离不超过 3 的指纹至少有一段完全相同
+type PostFingerprint struct {
+	ID        uint      `json:"-" gorm:"primaryKey"`
+	PostID    uint      `json:"post_id" gorm:"not null;uniqueIndex"`
+	SimHash   int64     `json:"simhash"`
+	Tokens    int       `json:"tokens"`
+	Band0     int       `json:"-" gorm:"index"`
+	Band1     int       `json:"-" gorm:"index"`
+	Band2     int       `json:"-" gorm:"index"`
+	Band3     int       `json:"-" gorm:"index"`
+	ClusterID *uint     `json:"cluster_id" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DuplicateCluster 内容相近的一组帖子，最早发布的帖子作为代表
+type DuplicateCluster struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	CanonicalPostID uint      `json:"canonical_post_id" gorm:"not null;index"`
+	Size            int       `json:"size"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// SearchQuery 按天汇总的搜索词次数，不记录搜索者身份和具体时间
+type SearchQuery struct {
+	ID    uint   `json:"-" gorm:"primaryKey"`
+	Query string `json:"query" gorm:"size:64;not null;uniqueIndex:idx_search_queries_query_day"`
+	Day   string `json:"day" gorm:"size:10;not null;uniqueIndex:idx_search_queries_query_day;index"` // YYYY-MM-DD
+	Count int    `json:"count"`
+}
+
+// SearchQuerySearcher 按天记录搜索过某个词的不同客户端，用于统计搜索人数
+// 客户端标识与搜索词一起哈希，无法关联同一客户端的不同搜索
+type SearchQuerySearcher struct {
+	ID       uint   `json:"-" gorm:"primaryKey"`
+	Query    string `json:"query" gorm:"size:64;not null;uniqueIndex:idx_search_query_searchers"`
+	Day      string `json:"day" gorm:"size:10;not null;uniqueIndex:idx_search_query_searchers;index"` // YYYY-MM-DD
+	Searcher string `json:"-" gorm:"size:32;not null;uniqueIndex:idx_search_query_searchers"`
+}
+
+// SavedSearch 按匿名身份保存的关键词订阅，同步后新的帖子和回复命中时发送通知
+type SavedSearch struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	Subscriber    string    `json:"-" gorm:"size:64;not null;index"`
+	Name          string    `json:"name" gorm:"size:64"`
+	Keywords      string    `json:"keywords" gorm:"size:200;not null"` // 空格分隔，全部包含才算命中
+	RadioGroup    string    `json:"radio_group" gorm:"size:32"`        // 为空时不限分组
+	MatchReplies  bool      `json:"match_replies"`                     // 是否同时匹配回复内容
+	WebhookURL    string    `json:"webhook_url" gorm:"size:512"`
+	WebhookSecret string    `json:"webhook_secret,omitempty" gorm:"size:128"` // 推送签名密钥，只返回给订阅者本人
+	LastPostID    uint      `json:"-"`                                        // 已检查过的最大帖子ID
+	LastReplyID   uint      `json:"-"`                                        // 已检查过的最大回复ID
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ThreadSubscription 匿名身份对帖子的订阅，帖子有新回复时发送通知
+type ThreadSubscription struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Subscriber  string    `json:"-" gorm:"size:64;not null;uniqueIndex:idx_thread_subscriptions_subscriber_post"`
+	PostID      uint      `json:"post_id" gorm:"not null;uniqueIndex:idx_thread_subscriptions_subscriber_post;index"`
+	AuthorID    string    `json:"-" gorm:"size:64"` // 订阅者在该帖子中的匿名作者ID，用于识别回复给自己的内容
+	LastReplyID uint      `json:"-"`                // 已检查过的最大回复ID
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Notification 匿名身份的站内通知
+type Notification struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	Subscriber    string     `json:"-" gorm:"size:64;not null;index"`
+	Type          string     `json:"type" gorm:"size:32;not null"` // saved_search, thread_reply, reply_to_you
+	PostID        uint       `json:"post_id"`
+	ReplyID       *uint      `json:"reply_id,omitempty"`
+	SavedSearchID *uint      `json:"saved_search_id,omitempty"`
+	Title         string     `json:"title" gorm:"size:200"`
+	Excerpt       string     `json:"excerpt" gorm:"size:500"`
+	ReadAt        *time.Time `json:"read_at"` // 为空表示未读
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// BookmarkCollection 匿名身份的收藏夹
+type BookmarkCollection struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Subscriber string    `json:"-" gorm:"size:64;not null;index"`
+	Name       string    `json:"name" gorm:"size:64;not null"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Bookmark 收藏夹中的帖子
+type Bookmark struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	CollectionID uint      `json:"collection_id" gorm:"not null;uniqueIndex:idx_bookmarks_collection_post"`
+	PostID       uint      `json:"post_id" gorm:"not null;uniqueIndex:idx_bookmarks_collection_post;index"`
+	Note         string    `json:"note" gorm:"size:200"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// PostArchive 被收藏帖子的内容存档，主站删除帖子后仍可从存档查看
+type PostArchive struct {
+	ID            uint      `json:"-" gorm:"primaryKey"`
+	PostID        uint      `json:"post_id" gorm:"not null;uniqueIndex"`
+	OriginalID    string    `json:"original_id"`
+	Title         string    `json:"title"`
+	Content       string    `json:"content" gorm:"type:text"`
+	Author        string    `json:"author"`
+	RadioGroup    string    `json:"radio_group"`
+	Tag           string    `json:"tag"`
+	Images        string    `json:"images" gorm:"type:text"`
+	Cover         string    `json:"cover"`
+	PostCreatedAt time.Time `json:"post_created_at"`
+	ArchivedAt    time.Time `json:"archived_at"`
+}
+
+// Backup 数据库备份记录
+type Backup struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	FileName       string     `json:"file_name" gorm:"size:128"`
+	TriggeredBy    string     `json:"triggered_by" gorm:"size:64"`          // schedule 或执行备份的管理员
+	Status         string     `json:"status" gorm:"size:16;not null;index"` // running, success, failed
+	Size           int64      `json:"size"`                                 // 数据库快照大小
+	CompressedSize int64      `json:"compressed_size"`
+	SHA256         string     `json:"sha256" gorm:"size:64"`      // 压缩文件的校验和
+	Integrity      string     `json:"integrity" gorm:"type:text"` // PRAGMA integrity_check 的结果
+	Error          string     `json:"error,omitempty" gorm:"type:text"`
+	StartedAt      time.Time  `json:"started_at" gorm:"index"`
+	FinishedAt     *time.Time `json:"finished_at"`
+	RemovedAt      *time.Time `json:"removed_at"` // 轮换时删除文件的时间
+}
+
+// Webhook 管理员注册的出站 Webhook
+type Webhook struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Name       string    `json:"name" gorm:"size:64;not null"`
+	URL        string    `json:"url" gorm:"size:512;not null"`
+	Secret     string    `json:"-" gorm:"size:128;not null"` // 签名密钥，只在创建时返回
+	Events     string    `json:"events" gorm:"size:255"`     // 逗号分隔的事件类型，为空表示全部事件
+	Tags       string    `json:"tags" gorm:"size:255"`       // 逗号分隔，帖子带有其中任一标签才推送
+	RadioGroup string    `json:"radio_group" gorm:"size:32"`
+	Keywords   string    `json:"keywords" gorm:"size:200"` // 空格分隔，内容包含全部关键词才推送
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery Webhook 投递记录，失败时按退避时间重试
+type WebhookDelivery struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	WebhookID     uint       `json:"webhook_id" gorm:"not null;index"`
+	SavedSearchID uint       `json:"saved_search_id,omitempty" gorm:"index"` // 关键词订阅的推送，此时 WebhookID 为 0
+	Event         string     `json:"event" gorm:"size:32;not null"`
+	Payload       string     `json:"payload" gorm:"type:text"`
+	Status        string     `json:"status" gorm:"size:16;not null;index"` // pending, success, failed
+	Attempts      int        `json:"attempts" gorm:"default:0"`
+	ResponseCode  int        `json:"response_code"`
+	Error         string     `json:"error" gorm:"size:500"`
+	NextAttemptAt *time.Time `json:"next_attempt_at" gorm:"index"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// Like 本地点赞记录，按匿名客户端身份去重
+type Like struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	TargetType string    `json:"target_type" gorm:"size:16;not null;uniqueIndex:idx_likes_target_client"` // post, reply
+	TargetID   uint      `json:"target_id" gorm:"not null;uniqueIndex:idx_likes_target_client"`
+	ClientHash string    `json:"-" gorm:"size:64;not null;uniqueIndex:idx_likes_target_client"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Media 本地上传的图片
+type Media struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	Hash         string    `json:"hash" gorm:"size:64;index"` // 原始文件 SHA-256
+	MimeType     string    `json:"mime_type"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Size         int64     `json:"size"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	OwnerType    string    `json:"owner_type" gorm:"size:16;index:idx_media_owner"` // post, reply
+	OwnerID      uint      `json:"owner_id" gorm:"index:idx_media_owner"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AdminAPIKey 管理员 API 密钥，只保存哈希
+type AdminAPIKey struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	Name       string     `json:"name" gorm:"not null"`
+	KeyHash    string     `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// AdminSession 管理员登录会话，只保存令牌哈希
+type AdminSession struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TokenHash string    `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	Actor     string    `json:"actor"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ModerationItem 审核队列条目，每个帖子或回复最多一条
+type ModerationItem struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	TargetType  string     `json:"target_type" gorm:"size:16;not null;uniqueIndex:idx_moderation_target"` // post, reply
+	TargetID    uint       `json:"target_id" gorm:"not null;uniqueIndex:idx_moderation_target"`
+	Sources     string     `json:"sources"` // 进入队列的来源，逗号分隔：report, filter
+	Reason      string     `json:"reason" gorm:"type:text"`
+	ReportCount int        `json:"report_count" gorm:"default:0"`
+	AutoHidden  bool       `json:"auto_hidden" gorm:"default:false"`            // 是否因举报数达到阈值被自动隐藏
+	Held        bool       `json:"held" gorm:"default:false"`                   // 命中过滤规则、尚未发布过的新内容
+	Status      string     `json:"status" gorm:"size:16;index;default:pending"` // pending, resolved, dismissed
+	ResolvedBy  string     `json:"resolved_by"`
+	ResolvedAt  *time.Time `json:"resolved_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Report 用户举报记录，按匿名客户端身份去重
+type Report struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	TargetType  string    `json:"target_type" gorm:"size:16;not null;uniqueIndex:idx_reports_target_client"` // post, reply
+	TargetID    uint      `json:"target_id" gorm:"not null;uniqueIndex:idx_reports_target_client"`
+	ClientHash  string    `json:"-" gorm:"size:64;not null;uniqueIndex:idx_reports_target_client"`
+	NetworkHash string    `json:"-" gorm:"size:64"` // 举报来源 IP 的带密钥哈希，自动隐藏按不同来源计数
+	Category    string    `json:"category" gorm:"size:32"`
+	Detail      string    `json:"detail" gorm:"type:text"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ErrAuditLogImmutable 审计日志不允许修改或删除
+var ErrAuditLogImmutable = errors.New("audit log entries are immutable")
+
+// AuditLog 管理操作审计日志，只允许追加
+type AuditLog struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Actor      string    `json:"actor" gorm:"index"`
+	Action     string    `json:"action" gorm:"index"`
+	TargetType string    `json:"target_type"`
+	TargetID   uint      `json:"target_id"`
+	Reason     string    `json:"reason" gorm:"type:text"`
+	Details    string    `json:"details" gorm:"type:text"` // JSON 格式的附加信息
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
+}
+
+// BeforeUpdate 禁止修改审计日志
+func (AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+// BeforeDelete 禁止删除审计日志
+func (AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
@@ -0,0 +1,344 @@
+package moderation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"treehole/internal/bookmark"
+	"treehole/internal/database"
+	"treehole/internal/dedup"
+	"treehole/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 审核对象类型
+const (
+	TargetPost  = "post"
+	TargetReply = "reply"
+)
+
+// 进入审核队列的来源
+const (
+	SourceReport = "report"
+	SourceFilter = "filter"
+)
+
+// 审核队列状态
+const (
+	StatusPending   = "pending"
+	StatusResolved  = "resolved"
+	StatusDismissed = "dismissed"
+)
+
+// 审核操作
+const (
+	ActionHide    = "hide"
+	ActionRestore = "restore"
+	ActionDelete  = "delete"
+	ActionDismiss = "dismiss"
+)
+
+// ErrInvalidTarget 不支持的审核对象
+var ErrInvalidTarget = errors.New("invalid moderation target")
+
+// ErrInvalidAction 不支持的审核操作
+var ErrInvalidAction = errors.New("invalid moderation action")
+
+// Service 内容审核服务
+// 隐藏使用 DeletedAt 软删除，删除为永久删除，所有操作都会写入审计日志
+type Service struct {
+	db        *gorm.DB
+	onRelease []func(targetType string, targetID uint) // 待审核的新内容首次发布后调用
+}
+
+// NewService 创建审核服务
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// OnRelease 注册待审核的新内容被恢复或驳回、首次发布后执行的处理函数，例如同步到主站
+func (s *Service) OnRelease(fn func(targetType string, targetID uint)) {
+	s.onRelease = append(s.onRelease, fn)
+}
+
+// ValidTarget 检查审核对象类型
+func ValidTarget(targetType string) bool {
+	return targetType == TargetPost || targetType == TargetReply
+}
+
+// targetModel 返回审核对象对应的模型
+func targetModel(targetType string) (interface{}, error) {
+	switch targetType {
+	case TargetPost:
+		return &models.Post{}, nil
+	case TargetReply:
+		return &models.Reply{}, nil
+	}
+	return nil, ErrInvalidTarget
+}
+
+// Enqueue 将内容加入审核队列，已在队列中的条目会合并来源并重新置为待处理
+func (s *Service) Enqueue(tx *gorm.DB, targetType string, targetID uint, source, reason string) (*models.ModerationItem, error) {
+	if !ValidTarget(targetType) {
+		return nil, ErrInvalidTarget
+	}
+
+	var item models.ModerationItem
+	err := tx.Where("target_type = ? AND target_id = ?", targetType, targetID).First(&item).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		item = models.ModerationItem{
+			TargetType: targetType,
+			TargetID:   targetID,
+			Sources:    source,
+			Reason:     reason,
+			Status:     StatusPending,
+		}
+		return &item, tx.Create(&item).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !containsSource(item.Sources, source) {
+		if item.Sources == "" {
+			item.Sources = source
+		} else {
+			item.Sources += "," + source
+		}
+	}
+	if reason != "" {
+		item.Reason = reason
+	}
+	item.Status = StatusPending
+	item.ResolvedBy = ""
+	item.ResolvedAt = nil
+	return &item, tx.Save(&item).Error
+}
+
+// Queue 分页获取审核队列，status 为空时返回全部
+func (s *Service) Queue(status string, page, limit int) ([]models.ModerationItem, int64, error) {
+	var items []models.ModerationItem
+	var total int64
+
+	query := s.db.Model(&models.ModerationItem{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("report_count desc, updated_at desc").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&items).Error
+	return items, total, err
+}
+
+// Apply 对帖子或回复执行审核操作，并结案对应的队列条目
+func (s *Service) Apply(actor, action, targetType string, targetID uint, reason string) error {
+	model, err := targetModel(targetType)
+	if err != nil {
+		return err
+	}
+
+	released := false
+	err = database.SafeTransaction(s.db, func(tx *gorm.DB) error {
+		// 操作前确认目标存在（包括已隐藏的内容）
+		if err := tx.Unscoped().First(model, targetID).Error; err != nil {
+			return err
+		}
+
+		details := map[string]interface{}{}
+		switch action {
+		case ActionHide:
+			if err := tx.Where("id = ?", targetID).Delete(model).Error; err != nil {
+				return err
+			}
+		case ActionRestore:
+			if err := tx.Unscoped().Model(model).Where("id = ?", targetID).Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+			if released, err = s.release(tx, targetType, targetID); err != nil {
+				return err
+			}
+		case ActionDelete:
+			// 永久删除帖子时一并删除其回复、标签关联、内容指纹、订阅和收藏
+			if targetType == TargetPost {
+				result := tx.Unscoped().Where("post_id = ?", targetID).Delete(&models.Reply{})
+				if result.Error != nil {
+					return result.Error
+				}
+				details["deleted_replies"] = result.RowsAffected
+				if err := tx.Where("post_id = ?", targetID).Delete(&models.PostTag{}).Error; err != nil {
+					return err
+				}
+				if err := dedup.Remove(tx, targetID); err != nil {
+					return err
+				}
+				if err := tx.Where("post_id = ?", targetID).Delete(&models.ThreadSubscription{}).Error; err != nil {
+					return err
+				}
+				if err := bookmark.Remove(tx, targetID); err != nil {
+					return err
+				}
+			}
+			if err := tx.Unscoped().Where("id = ?", targetID).Delete(model).Error; err != nil {
+				return err
+			}
+		default:
+			return ErrInvalidAction
+		}
+
+		if err := s.resolve(tx, actor, targetType, targetID, StatusResolved); err != nil {
+			return err
+		}
+		return s.Record(tx, actor, action, targetType, targetID, reason, details)
+	})
+	if err == nil && released {
+		s.released(targetType, targetID)
+	}
+	return err
+}
+
+// Dismiss 驳回审核队列条目，因举报被自动隐藏或被过滤规则拦下的内容会恢复显示
+func (s *Service) Dismiss(actor string, itemID uint, reason string) error {
+	var item models.ModerationItem
+	released := false
+	err := database.SafeTransaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.First(&item, itemID).Error; err != nil {
+			return err
+		}
+		// 驳回举报时撤销自动隐藏
+		if item.AutoHidden && item.Status == StatusPending {
+			model, err := targetModel(item.TargetType)
+			if err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(model).Where("id = ?", item.TargetID).Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+			if released, err = s.release(tx, item.TargetType, item.TargetID); err != nil {
+				return err
+			}
+		}
+		if err := s.resolve(tx, actor, item.TargetType, item.TargetID, StatusDismissed); err != nil {
+			return err
+		}
+		return s.Record(tx, actor, ActionDismiss, item.TargetType, item.TargetID, reason, map[string]interface{}{
+			"moderation_item_id": item.ID,
+			"sources":            item.Sources,
+		})
+	})
+	if err == nil && released {
+		s.released(item.TargetType, item.TargetID)
+	}
+	return err
+}
+
+// release 首次发布被过滤规则拦下的内容，补上创建时跳过的重复检测和回复计数
+// 内容不是待发布状态时返回 false
+func (s *Service) release(tx *gorm.DB, targetType string, targetID uint) (bool, error) {
+	result := tx.Model(&models.ModerationItem{}).
+		Where("target_type = ? AND target_id = ? AND held = ?", targetType, targetID, true).
+		Update("held", false)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return false, result.Error
+	}
+
+	switch targetType {
+	case TargetPost:
+		var post models.Post
+		if err := tx.First(&post, targetID).Error; err != nil {
+			return false, err
+		}
+		if err := dedup.Assign(tx, &post); err != nil {
+			return false, err
+		}
+	case TargetReply:
+		var reply models.Reply
+		if err := tx.First(&reply, targetID).Error; err != nil {
+			return false, err
+		}
+		if err := tx.Model(&models.Post{}).Where("id = ?", reply.PostID).
+			Update("reply_count", gorm.Expr("reply_count + ?", 1)).Error; err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// released 调用内容发布后的处理函数
+func (s *Service) released(targetType string, targetID uint) {
+	for _, fn := range s.onRelease {
+		fn(targetType, targetID)
+	}
+}
+
+// resolve 结案队列中的条目，没有条目时忽略
+func (s *Service) resolve(tx *gorm.DB, actor, targetType string, targetID uint, status string) error {
+	now := time.Now()
+	return tx.Model(&models.ModerationItem{}).
+		Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetID, StatusPending).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"resolved_by": actor,
+			"resolved_at": &now,
+		}).Error
+}
+
+// Record 写入一条审计日志
+func (s *Service) Record(tx *gorm.DB, actor, action, targetType string, targetID uint, reason string, details map[string]interface{}) error {
+	encoded := ""
+	if len(details) > 0 {
+		data, err := json.Marshal(details)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit details: %v", err)
+		}
+		encoded = string(data)
+	}
+
+	entry := models.AuditLog{
+		Actor:      actor,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Reason:     reason,
+		Details:    encoded,
+	}
+	return tx.Create(&entry).Error
+}
+
+// AuditLogs 分页获取审计日志，可按操作者和操作类型过滤
+func (s *Service) AuditLogs(actor, action string, page, limit int) ([]models.AuditLog, int64, error) {
+	var logs []models.AuditLog
+	var total int64
+
+	query := s.db.Model(&models.AuditLog{})
+	if actor != "" {
+		query = query.Where("actor = ?", actor)
+	}
+	if action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id desc").
+		Limit(limit).
+		Offset((page - 1) * limit).
+		Find(&logs).Error
+	return logs, total, err
+}
+
+// containsSource 检查来源列表中是否已包含指定来源
+func containsSource(sources, source string) bool {
+	for _, item := range strings.Split(sources, ",") {
+		if item == source {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"treehole/internal/database"
+	"treehole/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// SystemActor 自动执行的审核操作在审计日志中的操作者
+const SystemActor = "system"
+
+// ReportCategories 可选的举报类别
+var ReportCategories = map[string]string{
+	"spam":       "垃圾广告",
+	"abuse":      "人身攻击",
+	"privacy":    "泄露隐私",
+	"illegal":    "违法违规",
+	"harassment": "骚扰引战",
+	"other":      "其他",
+}
+
+// ErrDuplicateReport 同一客户端重复举报
+var ErrDuplicateReport = errors.New("already reported")
+
+// ErrInvalidCategory 不支持的举报类别
+var ErrInvalidCategory = errors.New("invalid report category")
+
+// ReportResult 举报处理结果
+type ReportResult struct {
+	ReportCount int  `json:"report_count"`
+	Hidden      bool `json:"hidden"`
+}
+
+// Report 记录一次举报并加入审核队列
+// 匿名身份可以随意签发，来自不同来源 IP 的举报数达到 threshold 时才自动隐藏内容，等待管理员复核
+func (s *Service) Report(targetType string, targetID uint, clientHash, networkHash, category, detail string, threshold int) (*ReportResult, error) {
+	model, err := targetModel(targetType)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := ReportCategories[category]; !ok {
+		return nil, ErrInvalidCategory
+	}
+
+	result := &ReportResult{}
+	err = database.SafeTransaction(s.db, func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&models.Report{}).
+			Where("target_type = ? AND target_id = ? AND client_hash = ?", targetType, targetID, clientHash).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrDuplicateReport
+		}
+
+		report := models.Report{
+			TargetType:  targetType,
+			TargetID:    targetID,
+			ClientHash:  clientHash,
+			NetworkHash: networkHash,
+			Category:    category,
+			Detail:      detail,
+		}
+		if err := tx.Create(&report).Error; err != nil {
+			return err
+		}
+
+		summary, count, err := reportSummary(tx, targetType, targetID)
+		if err != nil {
+			return err
+		}
+		item, err := s.Enqueue(tx, targetType, targetID, SourceReport, summary)
+		if err != nil {
+			return err
+		}
+		item.ReportCount = count
+		result.ReportCount = count
+
+		// 达到阈值时自动隐藏，每条内容只自动隐藏一次，管理员复核恢复后不再自动隐藏
+		sources, err := reportSources(tx, targetType, targetID)
+		if err != nil {
+			return err
+		}
+		if threshold > 0 && sources >= int64(threshold) && !item.AutoHidden {
+			hidden := tx.Where("id = ?", targetID).Delete(model)
+			if hidden.Error != nil {
+				return hidden.Error
+			}
+			if hidden.RowsAffected > 0 {
+				item.AutoHidden = true
+				result.Hidden = true
+				if err := s.Record(tx, SystemActor, ActionHide, targetType, targetID, "report threshold reached", map[string]interface{}{
+					"report_count":   count,
+					"report_sources": sources,
+					"threshold":      threshold,
+				}); err != nil {
+					return err
+				}
+			}
+		}
+
+		return tx.Save(item).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// reportSources 统计举报来源 IP 的数量，没有记录来源的旧举报按客户端计数
+func reportSources(tx *gorm.DB, targetType string, targetID uint) (int64, error) {
+	var sources int64
+	err := tx.Model(&models.Report{}).
+		Select("COUNT(DISTINCT COALESCE(NULLIF(network_hash, ''), client_hash))").
+		Where("target_type = ? AND target_id = ?", targetType, targetID).
+		Scan(&sources).Error
+	return sources, err
+}
+
+// reportSummary 汇总各类别的举报数量
+func reportSummary(tx *gorm.DB, targetType string, targetID uint) (string, int, error) {
+	var rows []struct {
+		Category string
+		Count    int
+	}
+	if err := tx.Model(&models.Report{}).
+		Select("category, COUNT(*) AS count").
+		Where("target_type = ? AND target_id = ?", targetType, targetID).
+		Group("category").
+		Order("count desc").
+		Scan(&rows).Error; err != nil {
+		return "", 0, err
+	}
+
+	total := 0
+	parts := make([]string, 0, len(rows))
+	for _, row := range rows {
+		total += row.Count
+		parts = append(parts, fmt.Sprintf("%s:%d", row.Category, row.Count))
+	}
+	return strings.Join(parts, ", "), total, nil
+}
+
+// Hold 隐藏命中过滤规则的新内容并加入审核队列，管理员恢复或驳回后才会显示
+func (s *Service) Hold(tx *gorm.DB, targetType string, targetID uint, reasons []string) error {
+	model, err := targetModel(targetType)
+	if err != nil {
+		return err
+	}
+
+	reason := strings.Join(reasons, "; ")
+	item, err := s.Enqueue(tx, targetType, targetID, SourceFilter, reason)
+	if err != nil {
+		return err
+	}
+	if err := tx.Where("id = ?", targetID).Delete(model).Error; err != nil {
+		return err
+	}
+	item.AutoHidden = true
+	item.Held = true
+	if err := tx.Save(item).Error; err != nil {
+		return err
+	}
+	return s.Record(tx, SystemActor, ActionHide, targetType, targetID, "held by content filter", map[string]interface{}{
+		"reasons": reasons,
+	})
+}
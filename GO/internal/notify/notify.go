@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+	"treehole/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// streamBuffer 每个实时连接缓存的通知数量，客户端读取过慢时丢弃新通知，可从收件箱补回
+const streamBuffer = 16
+
+// TicketTTL 实时连接票据的有效期
+const TicketTTL = 30 * time.Second
+
+// Service 站内通知服务
+// 通知写入收件箱后推送给该身份的实时连接（SSE）
+type Service struct {
+	db *gorm.DB
+
+	mutex     sync.Mutex
+	listeners map[string]map[chan models.Notification]struct{}
+	tickets   map[string]ticket
+}
+
+// ticket 一次性的实时连接票据，代替令牌出现在 URL 中，避免令牌写入访问日志
+type ticket struct {
+	subscriber string
+	expiresAt  time.Time
+}
+
+// NewService 创建通知服务
+func NewService(db *gorm.DB) *Service {
+	return &Service{
+		db:        db,
+		listeners: make(map[string]map[chan models.Notification]struct{}),
+		tickets:   make(map[string]ticket),
+	}
+}
+
+// Send 保存通知并推送给实时连接
+func (s *Service) Send(notifications []models.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	if err := s.db.Create(&notifications).Error; err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, notification := range notifications {
+		for ch := range s.listeners[notification.Subscriber] {
+			select {
+			case ch <- notification:
+			default:
+			}
+		}
+	}
+	return nil
+}
+
+// Subscribe 订阅身份的实时通知，返回的函数用于取消订阅
+func (s *Service) Subscribe(subscriber string) (<-chan models.Notification, func()) {
+	ch := make(chan models.Notification, streamBuffer)
+
+	s.mutex.Lock()
+	if s.listeners[subscriber] == nil {
+		s.listeners[subscriber] = make(map[chan models.Notification]struct{})
+	}
+	s.listeners[subscriber][ch] = struct{}{}
+	s.mutex.Unlock()
+
+	return ch, func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		delete(s.listeners[subscriber], ch)
+		if len(s.listeners[subscriber]) == 0 {
+			delete(s.listeners, subscriber)
+		}
+	}
+}
+
+// IssueTicket 为身份签发实时连接票据，票据只能使用一次，TicketTTL 后过期
+func (s *Service) IssueTicket(subscriber string) (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	value := hex.EncodeToString(buf)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now()
+	for key, t := range s.tickets {
+		if now.After(t.expiresAt) {
+			delete(s.tickets, key)
+		}
+	}
+	s.tickets[value] = ticket{subscriber: subscriber, expiresAt: now.Add(TicketTTL)}
+	return value, nil
+}
+
+// RedeemTicket 使用票据，返回签发时的身份，票据不存在或已过期时返回 false
+func (s *Service) RedeemTicket(value string) (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	t, ok := s.tickets[value]
+	if !ok {
+		return "", false
+	}
+	delete(s.tickets, value)
+	if time.Now().After(t.expiresAt) {
+		return "", false
+	}
+	return t.subscriber, true
+}
@@ -0,0 +1,268 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// shardCount 令牌桶分片数量，不同分片的请求互不阻塞
+const shardCount = 64
+
+// Policy 限流策略：每个窗口恢复 Limit 个令牌，桶容量为 Burst，Burst 为 0 时等于 Limit
+type Policy struct {
+	Limit  int
+	Window time.Duration
+	Burst  int
+}
+
+// capacity 令牌桶容量
+func (p Policy) capacity() int {
+	if p.Burst > 0 {
+		return p.Burst
+	}
+	return p.Limit
+}
+
+// String 以 LIMIT/WINDOW[/BURST] 的形式输出策略
+func (p Policy) String() string {
+	s := strconv.Itoa(p.Limit) + "/" + p.Window.String()
+	if p.Burst > 0 {
+		s += "/" + strconv.Itoa(p.Burst)
+	}
+	return s
+}
+
+// ParsePolicy 解析 LIMIT/WINDOW[/BURST] 形式的策略，例如 100/1m、60/1m/10
+func ParsePolicy(value string) (Policy, error) {
+	parts := strings.Split(strings.TrimSpace(value), "/")
+	if len(parts) != 2 && len(parts) != 3 {
+		return Policy{}, fmt.Errorf("invalid rate limit policy %q, expected LIMIT/WINDOW[/BURST]", value)
+	}
+	var p Policy
+	var err error
+	if p.Limit, err = strconv.Atoi(parts[0]); err != nil || p.Limit <= 0 {
+		return Policy{}, fmt.Errorf("invalid limit in rate limit policy %q", value)
+	}
+	if p.Window, err = time.ParseDuration(parts[1]); err != nil || p.Window <= 0 {
+		return Policy{}, fmt.Errorf("invalid window in rate limit policy %q", value)
+	}
+	if len(parts) == 3 {
+		if p.Burst, err = strconv.Atoi(parts[2]); err != nil || p.Burst <= 0 {
+			return Policy{}, fmt.Errorf("invalid burst in rate limit policy %q", value)
+		}
+	}
+	return p, nil
+}
+
+// Rule 按请求方法和路由匹配的限流规则
+type Rule struct {
+	Name    string          // 规则原文中的 METHODS PATH 部分，同一规则下的请求共用令牌桶
+	Methods map[string]bool // 为空时匹配所有方法
+	Path    string          // gin 路由模式，例如 /api/v1/posts/:id/replies
+	Prefix  bool            // 路由以 * 结尾时按前缀匹配
+	Policy  Policy
+}
+
+// Match 判断请求是否匹配规则，route 为 gin 的路由模式
+func (r Rule) Match(method, route string) bool {
+	if len(r.Methods) > 0 && !r.Methods[method] {
+		return false
+	}
+	if r.Prefix {
+		return strings.HasPrefix(route, r.Path)
+	}
+	return route == r.Path
+}
+
+// Policies 按路由选择限流策略，规则按顺序匹配，都不匹配时读请求使用 Read，其余使用 Write
+type Policies struct {
+	Rules []Rule
+	Read  Policy
+	Write Policy
+}
+
+// Select 返回请求适用的策略名称和策略
+func (p Policies) Select(method, route string) (string, Policy) {
+	for _, rule := range p.Rules {
+		if rule.Match(method, route) {
+			return rule.Name, rule.Policy
+		}
+	}
+	switch method {
+	case "GET", "HEAD", "OPTIONS":
+		return "read", p.Read
+	}
+	return "write", p.Write
+}
+
+// ParsePolicies 解析默认读写策略和以分号分隔的路由规则
+// 规则格式为 METHODS PATH=LIMIT/WINDOW[/BURST]，多个方法以逗号分隔，* 匹配所有方法
+func ParsePolicies(rules, read, write string) (Policies, error) {
+	var p Policies
+	var err error
+	if p.Read, err = ParsePolicy(read); err != nil {
+		return Policies{}, err
+	}
+	if p.Write, err = ParsePolicy(write); err != nil {
+		return Policies{}, err
+	}
+	for _, entry := range strings.Split(rules, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		rule, err := parseRule(entry)
+		if err != nil {
+			return Policies{}, err
+		}
+		p.Rules = append(p.Rules, rule)
+	}
+	return p, nil
+}
+
+// parseRule 解析一条路由规则
+func parseRule(entry string) (Rule, error) {
+	target, policy, ok := strings.Cut(entry, "=")
+	fields := strings.Fields(target)
+	if !ok || len(fields) != 2 || !strings.HasPrefix(fields[1], "/") {
+		return Rule{}, fmt.Errorf("invalid rate limit rule %q, expected METHODS PATH=LIMIT/WINDOW[/BURST]", entry)
+	}
+	rule := Rule{Name: fields[0] + " " + fields[1], Path: fields[1]}
+	if fields[0] != "*" {
+		rule.Methods = make(map[string]bool)
+		for _, method := range strings.Split(fields[0], ",") {
+			if method = strings.ToUpper(strings.TrimSpace(method)); method != "" {
+				rule.Methods[method] = true
+			}
+		}
+	}
+	if strings.HasSuffix(rule.Path, "*") {
+		rule.Path = strings.TrimSuffix(rule.Path, "*")
+		rule.Prefix = true
+	}
+	var err error
+	if rule.Policy, err = ParsePolicy(policy); err != nil {
+		return Rule{}, err
+	}
+	return rule, nil
+}
+
+// Result 一次取令牌的结果
+type Result struct {
+	Allowed    bool
+	Limit      int           // 桶容量
+	Remaining  int           // 剩余令牌数
+	RetryAfter time.Duration // 被拒绝时到下一个令牌恢复的时间
+	Reset      time.Duration // 令牌桶恢复满的时间
+}
+
+// bucket 一个客户端在一条策略下的令牌桶
+type bucket struct {
+	tokens float64
+	last   time.Time // 上次计算令牌的时间
+	full   time.Time // 令牌恢复满的时间，之后的桶与新建的桶相同，可以回收
+}
+
+type shard struct {
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+}
+
+// Limiter 分片的令牌桶限流器
+// 令牌桶恢复满后不再有状态，后台定期回收，内存占用只与近期活跃的客户端数量有关
+type Limiter struct {
+	shards [shardCount]shard
+}
+
+// New 创建限流器
+func New() *Limiter {
+	l := &Limiter{}
+	for i := range l.shards {
+		l.shards[i].buckets = make(map[string]*bucket)
+	}
+	return l
+}
+
+// shard 按键的 FNV-1a 哈希选择分片
+func (l *Limiter) shard(key string) *shard {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return &l.shards[hash%shardCount]
+}
+
+// Take 按策略为键取一个令牌
+func (l *Limiter) Take(key string, p Policy) Result {
+	now := time.Now()
+	capacity := float64(p.capacity())
+	rate := float64(p.Limit) / p.Window.Seconds() // 每秒恢复的令牌数
+
+	s := l.shard(key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity}
+		s.buckets[key] = b
+	} else {
+		b.tokens = min(capacity, b.tokens+now.Sub(b.last).Seconds()*rate)
+	}
+	b.last = now
+
+	result := Result{Limit: p.capacity()}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((capacity - b.tokens) / rate)
+	b.full = now.Add(result.Reset)
+	return result
+}
+
+// Allow 检查是否允许请求，每个窗口最多 maxRequests 次
+func (l *Limiter) Allow(key string, maxRequests int, window time.Duration) bool {
+	return l.Take(key, Policy{Limit: maxRequests, Window: window}).Allowed
+}
+
+// Evict 回收已恢复满的令牌桶，返回回收的数量
+func (l *Limiter) Evict() int {
+	now := time.Now()
+	evicted := 0
+	for i := range l.shards {
+		s := &l.shards[i]
+		s.mutex.Lock()
+		for key, b := range s.buckets {
+			if !now.Before(b.full) {
+				delete(s.buckets, key)
+				evicted++
+			}
+		}
+		s.mutex.Unlock()
+	}
+	return evicted
+}
+
+// StartEviction 在后台按间隔回收令牌桶
+func (l *Limiter) StartEviction(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			l.Evict()
+		}
+	}()
+}
+
+// seconds 将秒数转换为时长
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Policy
+		wantErr bool
+	}{
+		{"100/1m", Policy{Limit: 100, Window: time.Minute}, false},
+		{" 60/1m/10 ", Policy{Limit: 60, Window: time.Minute, Burst: 10}, false},
+		{"100", Policy{}, true},
+		{"0/1m", Policy{}, true},
+		{"10/0s", Policy{}, true},
+		{"10/abc", Policy{}, true},
+		{"10/1m/0", Policy{}, true},
+		{"10/1m/5/1", Policy{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParsePolicy(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParsePolicy() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPoliciesSelect(t *testing.T) {
+	p, err := ParsePolicies("POST /api/v1/posts=5/10m; get,head /api/v1/search*=30/1m/5; * /api/v1/admin/*=20/1m", "100/1m", "10/1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		method, route string
+		name          string
+		limit         int
+	}{
+		{"POST", "/api/v1/posts", "POST /api/v1/posts", 5},
+		{"GET", "/api/v1/posts", "read", 100},
+		{"HEAD", "/api/v1/search/suggest", "get,head /api/v1/search*", 30},
+		{"POST", "/api/v1/search", "write", 10},
+		{"DELETE", "/api/v1/admin/posts/:id", "* /api/v1/admin/*", 20},
+		{"PUT", "/api/v1/posts/:id", "write", 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.route, func(t *testing.T) {
+			name, policy := p.Select(tt.method, tt.route)
+			if name != tt.name || policy.Limit != tt.limit {
+				t.Errorf("Select() = %q %s, want %q limit %d", name, policy, tt.name, tt.limit)
+			}
+		})
+	}
+
+	for _, rules := range []string{"POST=5/1m", "POST posts=5/1m", "POST /api/v1/posts", "POST /api/v1/posts=5"} {
+		if _, err := ParsePolicies(rules, "100/1m", "10/1m"); err == nil {
+			t.Errorf("ParsePolicies(%q) succeeded, want error", rules)
+		}
+	}
+}
+
+func TestTake(t *testing.T) {
+	l := New()
+	p := Policy{Limit: 60, Window: time.Minute, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		result := l.Take("client", p)
+		if !result.Allowed || result.Limit != 3 || result.Remaining != 2-i {
+			t.Fatalf("request %d = %+v, want allowed with %d remaining", i, result, 2-i)
+		}
+	}
+	result := l.Take("client", p)
+	if result.Allowed || result.Remaining != 0 {
+		t.Fatalf("request over burst = %+v, want denied", result)
+	}
+	if result.RetryAfter <= 0 || result.RetryAfter > time.Second {
+		t.Errorf("retry_after = %v, want at most one token interval", result.RetryAfter)
+	}
+	if result.Reset <= 2*time.Second || result.Reset > 3*time.Second {
+		t.Errorf("reset = %v, want about 3s to refill 3 tokens", result.Reset)
+	}
+
+	// 不同的键互不影响
+	if !l.Take("other", p).Allowed {
+		t.Error("other key was denied")
+	}
+}
+
+func TestTakeRefill(t *testing.T) {
+	l := New()
+	p := Policy{Limit: 1, Window: 50 * time.Millisecond}
+
+	if !l.Take("client", p).Allowed {
+		t.Fatal("first request was denied")
+	}
+	if l.Take("client", p).Allowed {
+		t.Fatal("second request was allowed before refill")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if !l.Take("client", p).Allowed {
+		t.Error("request after refill was denied")
+	}
+}
+
+func TestEvict(t *testing.T) {
+	l := New()
+	short := Policy{Limit: 1, Window: 20 * time.Millisecond}
+	long := Policy{Limit: 1, Window: time.Hour}
+
+	l.Take("short", short)
+	l.Take("long", long)
+	if n := l.Evict(); n != 0 {
+		t.Fatalf("evicted %d buckets before refill, want 0", n)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if n := l.Evict(); n != 1 {
+		t.Fatalf("evicted %d buckets, want 1", n)
+	}
+	if l.Take("long", long).Allowed {
+		t.Error("bucket that was not full was evicted")
+	}
+	if !l.Take("short", short).Allowed {
+		t.Error("evicted bucket did not start full")
+	}
+}
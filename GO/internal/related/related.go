@@ -0,0 +1,347 @@
+package related
+
+import (
+	"errors"
+	"hash/fnv"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+	"treehole/internal/classifier"
+	"treehole/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// batchSize 建立索引时每批读取的帖子数量
+const batchSize = 500
+
+// titleWeight 标题中的词按出现多次计算，标题更能概括帖子内容
+const titleWeight = 2
+
+// maxQueryTerms 查询时只使用权重最高的若干个词，避免常见词带来大量候选
+const maxQueryTerms = 32
+
+// minScore 低于该相似度的帖子不作为相关帖子返回
+const minScore = 0.1
+
+// ErrRunning 已有索引任务在运行
+var ErrRunning = errors.New("related index update is already running")
+
+// Match 相关帖子及相似度
+type Match struct {
+	PostID uint    `json:"post_id"`
+	Score  float64 `json:"score"`
+}
+
+// UpdateResult 一次索引更新的统计
+type UpdateResult struct {
+	Indexed int `json:"indexed"`
+	Total   int `json:"total"`
+}
+
+// document 已索引帖子的词频和向量长度
+type document struct {
+	hash  uint64
+	terms map[string]float64 // 对数词频
+	norm  float64
+}
+
+// Service 相关帖子服务
+// 在内存中维护帖子标题和内容的 TF-IDF 倒排索引，后台任务按更新时间增量索引新帖子和内容有变化的帖子，
+// 查询时按余弦相似度返回最相近的帖子
+type Service struct {
+	db *gorm.DB
+
+	mutex     sync.RWMutex
+	docs      map[uint]*document
+	postings  map[string]map[uint]float64
+	watermark time.Time
+	loaded    bool
+
+	running sync.Mutex
+}
+
+// NewService 创建相关帖子服务，索引在第一次更新时建立
+func NewService(db *gorm.DB) *Service {
+	return &Service{
+		db:       db,
+		docs:     make(map[uint]*document),
+		postings: make(map[string]map[uint]float64),
+	}
+}
+
+// Update 索引上次更新以来新增或修改的帖子，第一次调用时建立完整索引
+func (s *Service) Update() (*UpdateResult, error) {
+	if !s.running.TryLock() {
+		return nil, ErrRunning
+	}
+	defer s.running.Unlock()
+
+	s.mutex.RLock()
+	loaded := s.loaded
+	s.mutex.RUnlock()
+	if !loaded {
+		return s.rebuild()
+	}
+	return s.update()
+}
+
+// Rebuild 丢弃现有索引并重新索引全部帖子
+func (s *Service) Rebuild() (*UpdateResult, error) {
+	if !s.running.TryLock() {
+		return nil, ErrRunning
+	}
+	defer s.running.Unlock()
+	return s.rebuild()
+}
+
+// UpdateJob 供定时任务调用的索引任务
+func (s *Service) UpdateJob() {
+	result, err := s.Update()
+	if errors.Is(err, ErrRunning) {
+		log.Println("Previous related index update is still running, skipping this execution")
+		return
+	}
+	if err != nil {
+		log.Printf("Related index update failed: %v", err)
+		return
+	}
+	if result.Indexed > 0 {
+		log.Printf("Related index updated %d posts, %d posts indexed in total", result.Indexed, result.Total)
+	}
+}
+
+// Related 查询与帖子最相似的帖子，按相似度降序，不包含帖子本身
+// 帖子尚未索引时按当前标题和内容计算
+func (s *Service) Related(post *models.Post, limit int) []Match {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	terms := termFrequencies(post.Title, post.Content)
+	if doc, ok := s.docs[post.ID]; ok && doc.hash == contentHash(post.Title, post.Content) {
+		terms = doc.terms
+	}
+
+	// 计算查询向量，只保留权重最高的词
+	type weightedTerm struct {
+		term   string
+		weight float64
+	}
+	query := make([]weightedTerm, 0, len(terms))
+	var queryNorm float64
+	for term, tf := range terms {
+		weight := tf * s.idf(term)
+		queryNorm += weight * weight
+		if _, ok := s.postings[term]; ok {
+			query = append(query, weightedTerm{term: term, weight: weight})
+		}
+	}
+	if queryNorm == 0 {
+		return nil
+	}
+	queryNorm = math.Sqrt(queryNorm)
+	sort.Slice(query, func(i, j int) bool {
+		if query[i].weight != query[j].weight {
+			return query[i].weight > query[j].weight
+		}
+		return query[i].term < query[j].term
+	})
+	if len(query) > maxQueryTerms {
+		query = query[:maxQueryTerms]
+	}
+
+	scores := make(map[uint]float64)
+	for _, q := range query {
+		idf := s.idf(q.term)
+		for postID, tf := range s.postings[q.term] {
+			if postID != post.ID {
+				scores[postID] += q.weight * tf * idf
+			}
+		}
+	}
+
+	matches := make([]Match, 0, len(scores))
+	for postID, dot := range scores {
+		doc := s.docs[postID]
+		if doc == nil || doc.norm == 0 {
+			continue
+		}
+		score := dot / (queryNorm * doc.norm)
+		if score >= minScore {
+			matches = append(matches, Match{PostID: postID, Score: math.Round(score*1000) / 1000})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].PostID > matches[j].PostID
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// rebuild 重新索引全部未隐藏的帖子
+func (s *Service) rebuild() (*UpdateResult, error) {
+	started := time.Now()
+	docs := make(map[uint]*document)
+	postings := make(map[string]map[uint]float64)
+
+	var posts []models.Post
+	err := s.db.Select("id, title, content").
+		FindInBatches(&posts, batchSize, func(tx *gorm.DB, batch int) error {
+			for _, post := range posts {
+				doc := newDocument(post.Title, post.Content)
+				docs[post.ID] = doc
+				addPostings(postings, post.ID, doc)
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.docs = docs
+	s.postings = postings
+	s.watermark = started
+	s.loaded = true
+	s.computeNorms(nil)
+	return &UpdateResult{Indexed: len(docs), Total: len(docs)}, nil
+}
+
+// update 按更新时间索引新增和修改过的帖子，标题和内容没有变化的帖子跳过
+func (s *Service) update() (*UpdateResult, error) {
+	result := &UpdateResult{}
+	changed := make(map[uint]bool)
+
+	for {
+		var posts []models.Post
+		if err := s.db.Select("id, title, content, updated_at").
+			Where("updated_at > ?", s.watermark).
+			Order("updated_at asc, id asc").
+			Limit(batchSize).
+			Find(&posts).Error; err != nil {
+			return nil, err
+		}
+		if len(posts) == 0 {
+			break
+		}
+
+		s.mutex.Lock()
+		for _, post := range posts {
+			hash := contentHash(post.Title, post.Content)
+			if doc, ok := s.docs[post.ID]; ok && doc.hash == hash {
+				continue
+			}
+			if doc, ok := s.docs[post.ID]; ok {
+				removePostings(s.postings, post.ID, doc)
+			}
+			doc := newDocument(post.Title, post.Content)
+			s.docs[post.ID] = doc
+			addPostings(s.postings, post.ID, doc)
+			changed[post.ID] = true
+		}
+		previous := s.watermark
+		if last := posts[len(posts)-1].UpdatedAt; last.After(s.watermark) {
+			s.watermark = last
+		}
+		s.mutex.Unlock()
+
+		// 时间没有推进时停止，避免同一批帖子被反复读取
+		if len(posts) < batchSize || !s.watermark.After(previous) {
+			break
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(changed) > 0 {
+		s.computeNorms(changed)
+	}
+	result.Indexed = len(changed)
+	result.Total = len(s.docs)
+	return result, nil
+}
+
+// computeNorms 按当前的逆文档频率计算向量长度，only 为空时计算全部帖子
+// 增量更新时只重新计算变化的帖子，其余帖子的向量长度在下次重建时更新
+func (s *Service) computeNorms(only map[uint]bool) {
+	for postID, doc := range s.docs {
+		if only != nil && !only[postID] {
+			continue
+		}
+		var norm float64
+		for term, tf := range doc.terms {
+			weight := tf * s.idf(term)
+			norm += weight * weight
+		}
+		doc.norm = math.Sqrt(norm)
+	}
+}
+
+// idf 平滑后的逆文档频率
+func (s *Service) idf(term string) float64 {
+	df := len(s.postings[term])
+	return math.Log(float64(len(s.docs)+1)/float64(df+1)) + 1
+}
+
+// newDocument 计算帖子的词频
+func newDocument(title, content string) *document {
+	return &document{
+		hash:  contentHash(title, content),
+		terms: termFrequencies(title, content),
+	}
+}
+
+// termFrequencies 统计标题和内容中的词频，使用对数词频减弱重复词的影响
+func termFrequencies(title, content string) map[string]float64 {
+	counts := make(map[string]int)
+	for _, token := range classifier.Tokenize(title) {
+		counts[token] += titleWeight
+	}
+	for _, token := range classifier.Tokenize(content) {
+		counts[token]++
+	}
+	terms := make(map[string]float64, len(counts))
+	for term, count := range counts {
+		terms[term] = 1 + math.Log(float64(count))
+	}
+	return terms
+}
+
+// contentHash 标题和内容的哈希，用于判断帖子是否需要重新索引
+func contentHash(title, content string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(title))
+	h.Write([]byte{0})
+	h.Write([]byte(content))
+	return h.Sum64()
+}
+
+// addPostings 将帖子加入倒排索引
+func addPostings(postings map[string]map[uint]float64, postID uint, doc *document) {
+	for term, tf := range doc.terms {
+		list, ok := postings[term]
+		if !ok {
+			list = make(map[uint]float64)
+			postings[term] = list
+		}
+		list[postID] = tf
+	}
+}
+
+// removePostings 将帖子从倒排索引中移除
+func removePostings(postings map[string]map[uint]float64, postID uint, doc *document) {
+	for term := range doc.terms {
+		delete(postings[term], postID)
+		if len(postings[term]) == 0 {
+			delete(postings, term)
+		}
+	}
+}
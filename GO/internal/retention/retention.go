@@ -0,0 +1,323 @@
+package retention
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"time"
+	"treehole/internal/bookmark"
+	"treehole/internal/database"
+	"treehole/internal/models"
+	"treehole/internal/moderation"
+
+	"gorm.io/gorm"
+)
+
+// 作者ID的处理方式
+const (
+	ModeHash = "hash" // 替换为带密钥的哈希，同一作者的内容仍能关联
+	ModeDrop = "drop" // 清空
+)
+
+// hashPrefix 哈希后的作者ID前缀，已处理的记录不会重复处理
+const hashPrefix = "h_"
+
+// anonPrefix 本站发布内容的作者ID前缀，是按帖子派生的匿名ID，不属于主站 openid
+const anonPrefix = "anon_"
+
+// batchSize 每批处理的记录数量
+const batchSize = 500
+
+// purgeReason 清理记录在审计日志中的原因
+const purgeReason = "retention policy"
+
+// ErrRunning 已有清理任务在运行
+var ErrRunning = errors.New("retention job is already running")
+
+// ErrNoHashSecret 哈希作者ID需要专用的密钥
+var ErrNoHashSecret = errors.New("RETENTION_HASH_SECRET is required to hash openids")
+
+// Config 保留策略，时间为 0 的策略不执行
+type Config struct {
+	OpenIDAfter       time.Duration // 发布超过该时间的内容处理作者ID（包括回复对象）
+	OpenIDMode        string        // hash 或 drop
+	HashSecret        string
+	IPAfter           time.Duration // 发布超过该时间的帖子清空 IP
+	WechatAfter       time.Duration // 发布超过该时间的帖子清空微信号
+	PurgeDeletedAfter time.Duration // 隐藏超过该时间的帖子和回复永久删除
+}
+
+// ValidMode 检查作者ID的处理方式
+func ValidMode(mode string) bool {
+	return mode == ModeHash || mode == ModeDrop
+}
+
+// Report 一次执行的统计，试运行时为将要处理的数量
+type Report struct {
+	DryRun         bool   `json:"dry_run"`
+	PostOpenIDs    int64  `json:"post_openids"`
+	ReplyOpenIDs   int64  `json:"reply_openids"`
+	ReplyApplyTos  int64  `json:"reply_apply_tos"`
+	IPs            int64  `json:"ips"`
+	Wechats        int64  `json:"wechats"`
+	PurgedPosts    int64  `json:"purged_posts"`
+	PurgedReplies  int64  `json:"purged_replies"`
+	ProtectedPosts int64  `json:"protected_posts"` // 被收藏而跳过永久删除的隐藏帖子
+	UnderReview    int64  `json:"under_review"`    // 有待处理的举报或审核而跳过永久删除的隐藏帖子和回复
+	Duration       string `json:"duration"`
+}
+
+// Service 数据保留服务
+// 定期清理超过保留时间的个人信息和被隐藏的内容，每次执行写入审计日志
+type Service struct {
+	db         *gorm.DB
+	config     Config
+	moderation *moderation.Service
+
+	running sync.Mutex
+}
+
+// NewService 创建数据保留服务
+func NewService(db *gorm.DB, cfg Config) *Service {
+	return &Service{db: db, config: cfg, moderation: moderation.NewService(db)}
+}
+
+// Config 返回保留策略
+func (s *Service) Config() Config {
+	return s.config
+}
+
+// Run 执行所有启用的策略，dryRun 为 true 时只统计不修改
+func (s *Service) Run(actor string, dryRun bool) (*Report, error) {
+	if s.config.OpenIDAfter > 0 && s.config.OpenIDMode == ModeHash && s.config.HashSecret == "" {
+		return nil, ErrNoHashSecret
+	}
+	if !s.running.TryLock() {
+		return nil, ErrRunning
+	}
+	defer s.running.Unlock()
+
+	start := time.Now()
+	report := &Report{DryRun: dryRun}
+	steps := []func(*Report, time.Time, string, bool) error{s.openIDs, s.contacts, s.purge}
+	for _, step := range steps {
+		if err := step(report, start, actor, dryRun); err != nil {
+			return nil, err
+		}
+	}
+	report.Duration = time.Since(start).Round(time.Millisecond).String()
+
+	action := "retention_run"
+	if dryRun {
+		action = "retention_dry_run"
+	}
+	if err := s.moderation.Record(s.db, actor, action, "", 0, "", map[string]interface{}{
+		"post_openids":    report.PostOpenIDs,
+		"reply_openids":   report.ReplyOpenIDs,
+		"reply_apply_tos": report.ReplyApplyTos,
+		"ips":             report.IPs,
+		"wechats":         report.Wechats,
+		"purged_posts":    report.PurgedPosts,
+		"purged_replies":  report.PurgedReplies,
+		"protected_posts": report.ProtectedPosts,
+		"under_review":    report.UnderReview,
+	}); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// RunJob 定时任务入口
+func (s *Service) RunJob() {
+	report, err := s.Run(moderation.SystemActor, false)
+	if errors.Is(err, ErrRunning) {
+		log.Println("Previous retention job is still running, skipping this execution")
+		return
+	}
+	if err != nil {
+		log.Printf("Retention job failed: %v", err)
+		return
+	}
+	log.Printf("Retention job processed %d post openids, %d reply openids, %d IPs, %d wechats, purged %d posts and %d replies",
+		report.PostOpenIDs, report.ReplyOpenIDs+report.ReplyApplyTos, report.IPs, report.Wechats, report.PurgedPosts, report.PurgedReplies)
+}
+
+// openIDs 处理超过保留时间的主站作者ID和回复对象
+func (s *Service) openIDs(report *Report, now time.Time, actor string, dryRun bool) error {
+	if s.config.OpenIDAfter <= 0 {
+		return nil
+	}
+	cutoff := now.Add(-s.config.OpenIDAfter)
+	var err error
+	if report.PostOpenIDs, err = s.rewrite(&models.Post{}, "author_id", cutoff, dryRun); err != nil {
+		return err
+	}
+	if report.ReplyOpenIDs, err = s.rewrite(&models.Reply{}, "author_id", cutoff, dryRun); err != nil {
+		return err
+	}
+	report.ReplyApplyTos, err = s.rewrite(&models.Reply{}, "apply_to", cutoff, dryRun)
+	return err
+}
+
+// rewrite 哈希或清空一列中的 openid，返回处理的记录数
+func (s *Service) rewrite(model interface{}, column string, cutoff time.Time, dryRun bool) (int64, error) {
+	scope := func() *gorm.DB {
+		return s.db.Unscoped().Model(model).
+			Where("created_at < ?", cutoff).
+			Where(column+" <> '' AND "+column+" NOT LIKE ? AND "+column+" NOT LIKE ?", anonPrefix+"%", hashPrefix+"%")
+	}
+	if dryRun {
+		var count int64
+		err := scope().Count(&count).Error
+		return count, err
+	}
+	if s.config.OpenIDMode == ModeDrop {
+		result := scope().UpdateColumn(column, "")
+		return result.RowsAffected, result.Error
+	}
+
+	var total int64
+	var after uint
+	for {
+		var rows []struct {
+			ID    uint
+			Value string
+		}
+		if err := scope().Select("id, "+column+" AS value").Where("id > ?", after).Order("id asc").Limit(batchSize).Scan(&rows).Error; err != nil {
+			return total, err
+		}
+		if len(rows) == 0 {
+			return total, nil
+		}
+		err := database.SafeTransaction(s.db, func(tx *gorm.DB) error {
+			for _, row := range rows {
+				if err := tx.Unscoped().Model(model).Where("id = ?", row.ID).UpdateColumn(column, s.hash(row.Value)).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+		total += int64(len(rows))
+		after = rows[len(rows)-1].ID
+	}
+}
+
+// hash 计算作者ID的带密钥哈希，同一 openid 总是得到相同的结果
+func (s *Service) hash(openID string) string {
+	mac := hmac.New(sha256.New, []byte(s.config.HashSecret))
+	mac.Write([]byte(openID))
+	return hashPrefix + hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// contacts 清空超过保留时间的帖子 IP 和微信号
+func (s *Service) contacts(report *Report, now time.Time, actor string, dryRun bool) error {
+	var err error
+	if s.config.IPAfter > 0 {
+		if report.IPs, err = s.clear("ip", now.Add(-s.config.IPAfter), dryRun); err != nil {
+			return err
+		}
+	}
+	if s.config.WechatAfter > 0 {
+		report.Wechats, err = s.clear("wechat", now.Add(-s.config.WechatAfter), dryRun)
+	}
+	return err
+}
+
+// clear 清空帖子的一列，返回处理的记录数
+func (s *Service) clear(column string, cutoff time.Time, dryRun bool) (int64, error) {
+	query := s.db.Unscoped().Model(&models.Post{}).Where("created_at < ? AND "+column+" <> ''", cutoff)
+	if dryRun {
+		var count int64
+		err := query.Count(&count).Error
+		return count, err
+	}
+	result := query.UpdateColumn(column, "")
+	return result.RowsAffected, result.Error
+}
+
+// purge 永久删除隐藏超过保留时间的帖子和回复
+// 被收藏的帖子、有待处理的举报或审核的内容（包括其下有这类回复的帖子）保留，保留的帖子下其余隐藏回复单独删除
+// 删除通过审核服务执行，每条记录都写入审计日志
+func (s *Service) purge(report *Report, now time.Time, actor string, dryRun bool) error {
+	if s.config.PurgeDeletedAfter <= 0 {
+		return nil
+	}
+	cutoff := now.Add(-s.config.PurgeDeletedAfter)
+	hidden := func(model interface{}) *gorm.DB {
+		return s.db.Unscoped().Model(model).Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff)
+	}
+	reviewedReplies := s.underReview(moderation.TargetReply)
+	unreviewed := func() *gorm.DB {
+		return hidden(&models.Post{}).
+			Where("id NOT IN (?)", s.underReview(moderation.TargetPost)).
+			Where("id NOT IN (?)", s.db.Unscoped().Model(&models.Reply{}).Select("post_id").Where("id IN (?)", reviewedReplies))
+	}
+	purgeable := func() *gorm.DB {
+		return unreviewed().Where("id NOT IN (?)", bookmark.Protected(s.db))
+	}
+
+	if err := unreviewed().Where("id IN (?)", bookmark.Protected(s.db)).Count(&report.ProtectedPosts).Error; err != nil {
+		return err
+	}
+	var reviewedPosts, reviewed int64
+	if err := hidden(&models.Post{}).Where("id NOT IN (?)", unreviewed().Select("id")).Count(&reviewedPosts).Error; err != nil {
+		return err
+	}
+	if err := hidden(&models.Reply{}).Where("id IN (?)", reviewedReplies).Count(&reviewed).Error; err != nil {
+		return err
+	}
+	report.UnderReview = reviewedPosts + reviewed
+
+	posts := purgeable()
+	// 所属帖子会被一并删除的回复不单独计数，所属帖子被保留时单独删除
+	replies := hidden(&models.Reply{}).
+		Where("id NOT IN (?)", reviewedReplies).
+		Where("post_id NOT IN (?)", purgeable().Select("id"))
+	if dryRun {
+		if err := posts.Count(&report.PurgedPosts).Error; err != nil {
+			return err
+		}
+		return replies.Count(&report.PurgedReplies).Error
+	}
+
+	var err error
+	if report.PurgedPosts, err = s.purgeTargets(posts, moderation.TargetPost, actor); err != nil {
+		return err
+	}
+	report.PurgedReplies, err = s.purgeTargets(replies, moderation.TargetReply, actor)
+	return err
+}
+
+// underReview 有待处理的举报或审核、或者是尚未发布的待审核内容的目标ID
+func (s *Service) underReview(targetType string) *gorm.DB {
+	return s.db.Model(&models.ModerationItem{}).Select("target_id").
+		Where("target_type = ? AND (status = ? OR held = ?)", targetType, moderation.StatusPending, true)
+}
+
+// purgeTargets 分批永久删除查询到的记录
+func (s *Service) purgeTargets(query *gorm.DB, targetType, actor string) (int64, error) {
+	var total int64
+	var after uint
+	for {
+		var ids []uint
+		if err := query.Session(&gorm.Session{}).Where("id > ?", after).Order("id asc").Limit(batchSize).Pluck("id", &ids).Error; err != nil {
+			return total, err
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+		for _, id := range ids {
+			if err := s.moderation.Apply(actor, moderation.ActionDelete, targetType, id, purgeReason); err != nil {
+				return total, err
+			}
+			total++
+		}
+		after = ids[len(ids)-1]
+	}
+}
@@ -0,0 +1,310 @@
+package savedsearch
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"treehole/internal/models"
+	"treehole/internal/notify"
+	"treehole/internal/webhook"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// NotificationType 关键词订阅命中时的通知类型
+const NotificationType = "saved_search"
+
+// 订阅的数量和内容限制
+const (
+	MaxPerSubscriber = 20
+	MaxKeywords      = 10
+	maxKeywordLength = 32
+)
+
+// batchSize 每批检查的帖子或回复数量
+const batchSize = 500
+
+// maxMatchesPerRun 每个订阅在一次检查中最多产生的通知数量，避免大量同步时刷屏
+const maxMatchesPerRun = 50
+
+// 通知中标题和内容摘要的长度（按字符计）
+const (
+	titleLength   = 100
+	excerptLength = 100
+)
+
+// Service 关键词订阅服务
+// 同步结束和本地发帖、回复后检查各订阅上次检查之后的新帖子和回复，命中时写入收件箱并推送
+// 检查过程串行执行，运行期间的多次触发合并为一次
+// 订阅填写了 Webhook 地址时通过出站 Webhook 服务投递，与管理员注册的 Webhook 一样签名和重试
+type Service struct {
+	db       *gorm.DB
+	notify   *notify.Service
+	webhooks *webhook.Service
+
+	running sync.Mutex
+	pending atomic.Bool // 有未处理的触发
+}
+
+// NewService 创建关键词订阅服务
+func NewService(db *gorm.DB, notifier *notify.Service, webhooks *webhook.Service) *Service {
+	return &Service{db: db, notify: notifier, webhooks: webhooks}
+}
+
+// ParseKeywords 拆分并校验关键词
+func ParseKeywords(raw string) ([]string, error) {
+	keywords := strings.Fields(strings.ToLower(raw))
+	if len(keywords) == 0 {
+		return nil, errors.New("at least one keyword is required")
+	}
+	if len(keywords) > MaxKeywords {
+		return nil, errors.New("too many keywords")
+	}
+	for _, keyword := range keywords {
+		if utf8.RuneCountInString(keyword) > maxKeywordLength {
+			return nil, errors.New("keyword is too long")
+		}
+	}
+	return keywords, nil
+}
+
+// StartFrom 将订阅的检查位置设置为当前最新的帖子和回复，新订阅只通知之后的内容
+func StartFrom(db *gorm.DB, search *models.SavedSearch) error {
+	var maxPostID, maxReplyID uint
+	if err := db.Unscoped().Model(&models.Post{}).Select("COALESCE(MAX(id), 0)").Scan(&maxPostID).Error; err != nil {
+		return err
+	}
+	if err := db.Unscoped().Model(&models.Reply{}).Select("COALESCE(MAX(id), 0)").Scan(&maxReplyID).Error; err != nil {
+		return err
+	}
+	search.LastPostID = maxPostID
+	search.LastReplyID = maxReplyID
+	return nil
+}
+
+// Match 检查所有订阅，返回发送的通知数量
+func (s *Service) Match() (int, error) {
+	s.running.Lock()
+	defer s.running.Unlock()
+	return s.match()
+}
+
+// match 检查所有订阅，调用方需持有 running
+func (s *Service) match() (int, error) {
+	var searches []models.SavedSearch
+	if err := s.db.Find(&searches).Error; err != nil {
+		return 0, err
+	}
+	if len(searches) == 0 {
+		return 0, nil
+	}
+
+	matchers := make([]*matcher, 0, len(searches))
+	for i := range searches {
+		keywords, err := ParseKeywords(searches[i].Keywords)
+		if err != nil {
+			continue
+		}
+		matchers = append(matchers, &matcher{search: &searches[i], keywords: keywords})
+	}
+	if len(matchers) == 0 {
+		return 0, nil
+	}
+
+	if err := s.matchPosts(matchers); err != nil {
+		return 0, err
+	}
+	if err := s.matchReplies(matchers); err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, m := range matchers {
+		if err := s.notify.Send(m.notifications); err != nil {
+			return sent, err
+		}
+		sent += len(m.notifications)
+		if m.search.WebhookURL != "" && len(m.notifications) > 0 {
+			payloads := make([]map[string]interface{}, 0, len(m.notifications))
+			for _, notification := range m.notifications {
+				payloads = append(payloads, map[string]interface{}{
+					"saved_search": map[string]interface{}{"id": m.search.ID, "name": m.search.Name, "keywords": m.search.Keywords},
+					"notification": notification,
+				})
+			}
+			if err := s.webhooks.NotifySavedSearch(m.search, payloads); err != nil {
+				log.Printf("Failed to enqueue saved search %d webhook: %v", m.search.ID, err)
+			}
+		}
+		if err := s.db.Model(m.search).UpdateColumns(map[string]interface{}{
+			"last_post_id":  m.search.LastPostID,
+			"last_reply_id": m.search.LastReplyID,
+		}).Error; err != nil {
+			return sent, err
+		}
+	}
+	return sent, nil
+}
+
+// MatchJob 供同步结束和本地发帖、回复后调用的匹配任务
+// 已有匹配在运行时只标记待处理并立即返回，由运行中的匹配结束后再执行一次
+func (s *Service) MatchJob() {
+	s.pending.Store(true)
+	for s.pending.Load() && s.running.TryLock() {
+		for s.pending.Swap(false) {
+			sent, err := s.match()
+			if err != nil {
+				log.Printf("Saved search matching failed: %v", err)
+			} else if sent > 0 {
+				log.Printf("Saved search matching sent %d notifications", sent)
+			}
+		}
+		s.running.Unlock()
+	}
+}
+
+// matcher 一个订阅在本次检查中的状态
+type matcher struct {
+	search        *models.SavedSearch
+	keywords      []string
+	notifications []models.Notification
+}
+
+// matches 文本是否包含全部关键词
+func (m *matcher) matches(text string) bool {
+	text = strings.ToLower(text)
+	for _, keyword := range m.keywords {
+		if !strings.Contains(text, keyword) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPosts 检查各订阅上次检查之后的未隐藏帖子
+func (s *Service) matchPosts(matchers []*matcher) error {
+	after := matchers[0].search.LastPostID
+	for _, m := range matchers {
+		if m.search.LastPostID < after {
+			after = m.search.LastPostID
+		}
+	}
+
+	for {
+		var posts []models.Post
+		if err := s.db.Unscoped().Select("id, title, content, radio_group, deleted_at").
+			Where("id > ?", after).Order("id asc").Limit(batchSize).
+			Find(&posts).Error; err != nil {
+			return err
+		}
+		for _, post := range posts {
+			for _, m := range matchers {
+				if post.ID <= m.search.LastPostID {
+					continue
+				}
+				m.search.LastPostID = post.ID
+				if post.DeletedAt.Valid || len(m.notifications) >= maxMatchesPerRun {
+					continue
+				}
+				if m.search.RadioGroup != "" && m.search.RadioGroup != post.RadioGroup {
+					continue
+				}
+				if m.matches(post.Title + "\n" + post.Content) {
+					searchID := m.search.ID
+					m.notifications = append(m.notifications, models.Notification{
+						Subscriber:    m.search.Subscriber,
+						Type:          NotificationType,
+						PostID:        post.ID,
+						SavedSearchID: &searchID,
+						Title:         truncate(post.Title, titleLength),
+						Excerpt:       truncate(post.Content, excerptLength),
+					})
+				}
+			}
+			after = post.ID
+		}
+		if len(posts) < batchSize {
+			return nil
+		}
+	}
+}
+
+// matchReplies 检查开启了回复匹配的订阅上次检查之后的未隐藏回复
+func (s *Service) matchReplies(matchers []*matcher) error {
+	after := matchers[0].search.LastReplyID
+	for _, m := range matchers {
+		if m.search.LastReplyID < after {
+			after = m.search.LastReplyID
+		}
+	}
+
+	for {
+		var replies []models.Reply
+		if err := s.db.Unscoped().Select("id, post_id, content, deleted_at").
+			Where("id > ?", after).Order("id asc").Limit(batchSize).
+			Find(&replies).Error; err != nil {
+			return err
+		}
+		if len(replies) == 0 {
+			return nil
+		}
+
+		// 回复所在帖子的标题用于通知标题，帖子被隐藏时不通知
+		postIDs := make([]uint, 0, len(replies))
+		for _, reply := range replies {
+			postIDs = append(postIDs, reply.PostID)
+		}
+		var posts []models.Post
+		if err := s.db.Select("id, title, radio_group").Where("id IN ?", postIDs).Find(&posts).Error; err != nil {
+			return err
+		}
+		postByID := make(map[uint]models.Post, len(posts))
+		for _, post := range posts {
+			postByID[post.ID] = post
+		}
+
+		for _, reply := range replies {
+			post, visible := postByID[reply.PostID]
+			for _, m := range matchers {
+				if reply.ID <= m.search.LastReplyID {
+					continue
+				}
+				m.search.LastReplyID = reply.ID
+				if !m.search.MatchReplies || !visible || reply.DeletedAt.Valid || len(m.notifications) >= maxMatchesPerRun {
+					continue
+				}
+				if m.search.RadioGroup != "" && m.search.RadioGroup != post.RadioGroup {
+					continue
+				}
+				if m.matches(reply.Content) {
+					searchID, replyID := m.search.ID, reply.ID
+					m.notifications = append(m.notifications, models.Notification{
+						Subscriber:    m.search.Subscriber,
+						Type:          NotificationType,
+						PostID:        reply.PostID,
+						ReplyID:       &replyID,
+						SavedSearchID: &searchID,
+						Title:         truncate(post.Title, titleLength),
+						Excerpt:       truncate(reply.Content, excerptLength),
+					})
+				}
+			}
+			after = reply.ID
+		}
+		if len(replies) < batchSize {
+			return nil
+		}
+	}
+}
+
+// truncate 按字符截断文本
+func truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return string(runes[:length]) + "…"
+}
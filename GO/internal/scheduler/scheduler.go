@@ -28,10 +28,17 @@ func New(scraperService *scraper.Service) *Scheduler {
 	}
 }
 
-// Start 启动调度器
+// Start 启动调度器，未开启入站同步时只运行通过 AddJob 添加的任务
 func (s *Scheduler) Start() {
+	s.addSyncJob()
+	s.cron.Start()
+	log.Println("Scheduler started")
+}
+
+// addSyncJob 添加定时同步任务
+func (s *Scheduler) addSyncJob() {
 	if os.Getenv("INBOUND_SYNC_ENABLED") != "true" {
-		log.Println("INBOUND_SYNC_ENABLED is not set to true, scheduler will not start")
+		log.Println("INBOUND_SYNC_ENABLED is not set to true, automatic sync is disabled")
 		return
 	}
 	// 从环境变量获取同步间隔，默认为每30分钟
@@ -78,8 +85,7 @@ func (s *Scheduler) Start() {
 		return
 	}
 
-	s.cron.Start()
-	log.Printf("Scheduler started with cron spec: %s", cronSpec)
+	log.Printf("Sync job scheduled with cron spec: %s", cronSpec)
 }
 
 // Stop 停止调度器
@@ -10,9 +10,14 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"treehole/internal/bookmark"
+	"treehole/internal/classifier"
 	"treehole/internal/config"
 	"treehole/internal/database"
+	"treehole/internal/dedup"
+	"treehole/internal/metrics"
 	"treehole/internal/models"
+	"treehole/internal/webhook"
 
 	"gorm.io/gorm"
 )
@@ -24,9 +29,16 @@ type Service struct {
 	syncClient *http.Client // 用于同步到主站的客户端（使用代理）
 	baseURL    string
 	config     *config.Config
-	saveMux    sync.Mutex // 保护数据库写入操作的互斥锁
+	saveMux    sync.Mutex            // 保护数据库写入操作的互斥锁
+	afterSync  []func()              // 每次同步结束后调用
+	onEvent    []func(webhook.Event) // 同步产生内容和状态变化时调用
+	events     chan webhook.Event    // 待处理的同步事件，由后台协程依次交给 onEvent，不阻塞抓取
+	startOnce  sync.Once
 }
 
+// eventBuffer 同步事件队列的长度，队列满时抓取等待处理函数
+const eventBuffer = 256
+
 // APIResponse 通用 API 响应结构
 type APIResponse struct {
 	TaskList    []TaskData    `json:"taskList"`
@@ -110,6 +122,7 @@ func NewService(db *gorm.DB, cfg *config.Config) *Service {
 		baseURL:    "https://www.yqtech.ltd:8802",
 		config:     cfg,
 		saveMux:    sync.Mutex{},
+		events:     make(chan webhook.Event, eventBuffer),
 	}
 }
 
@@ -168,9 +181,54 @@ func (s *Service) ScrapeData() error {
 	}
 
 	log.Printf("Sync completed. Posts: %d, Replies: %d, Errors: %d", totalPosts, totalReplies, len(errors))
+
+	syncEvent := webhook.Event{Type: webhook.EventSyncCompleted, Data: map[string]interface{}{
+		"total_posts":   totalPosts,
+		"total_replies": totalReplies,
+	}}
+	if len(errors) > 0 {
+		syncEvent.Type = webhook.EventSyncFailed
+		syncEvent.Data["errors"] = errors
+	}
+	s.emit(syncEvent)
+
+	for _, fn := range s.afterSync {
+		fn()
+	}
 	return nil
 }
 
+// OnSynced 注册同步结束后执行的任务，需要在调度器启动前注册
+func (s *Service) OnSynced(fn func()) {
+	s.afterSync = append(s.afterSync, fn)
+}
+
+// OnEvent 注册同步事件的处理函数（新帖子、新回复、帖子状态变化、同步结束），需要在调度器启动前注册
+// 处理函数在后台协程中按事件顺序调用
+func (s *Service) OnEvent(fn func(webhook.Event)) {
+	s.onEvent = append(s.onEvent, fn)
+	s.startOnce.Do(func() {
+		go s.dispatchEvents()
+	})
+}
+
+// emit 将同步事件放入队列，没有注册处理函数时直接丢弃
+func (s *Service) emit(event webhook.Event) {
+	if len(s.onEvent) == 0 {
+		return
+	}
+	s.events <- event
+}
+
+// dispatchEvents 依次把队列中的事件交给处理函数
+func (s *Service) dispatchEvents() {
+	for event := range s.events {
+		for _, fn := range s.onEvent {
+			fn(event)
+		}
+	}
+}
+
 // scrapeNewPosts 抓取新帖子
 func (s *Service) scrapeNewPosts(totalPosts *int, errors *[]string) error {
 	// 获取本地最大ID
@@ -261,6 +319,11 @@ func (s *Service) scrapeNewReplies(totalReplies *int, errors *[]string) error {
 			continue
 		}
 
+		// 回复数已在保存回复时递增，同步点赞和浏览数后记录计数快照
+		if err := s.recordCounters(&post); err != nil {
+			log.Printf("Failed to record metrics for post %d: %v", post.ID, err)
+		}
+
 		*totalReplies++
 		// time.Sleep(200 * time.Millisecond)
 	}
@@ -383,7 +446,7 @@ func (s *Service) getUserComments(openid string) ([]CommentData, error) {
 func (s *Service) getLocalMaxPostID() string {
 	var post models.Post
 	err := database.WithRetry(s.db, func(db *gorm.DB) error {
-		return db.Order("CAST(original_id AS INTEGER) DESC").First(&post).Error
+		return db.Unscoped().Order("CAST(original_id AS INTEGER) DESC").First(&post).Error
 	})
 	if err != nil {
 		return "0" // 如果没有帖子，从0开始
@@ -396,10 +459,13 @@ func (s *Service) savePost(taskData *TaskData) error {
 	s.saveMux.Lock()
 	defer s.saveMux.Unlock()
 
-	return database.WithRetry(s.db, func(db *gorm.DB) error {
-		// 检查帖子是否已存在
+	// 事务可能重试，事件在保存成功后再发出
+	var event *webhook.Event
+	err := database.WithRetry(s.db, func(db *gorm.DB) error {
+		event = nil
+		// 检查帖子是否已存在（包括被管理员隐藏的帖子，避免重新创建）
 		var existingPost models.Post
-		result := db.Where("original_id = ?", strconv.Itoa(taskData.ID)).First(&existingPost)
+		result := db.Unscoped().Where("original_id = ?", strconv.Itoa(taskData.ID)).First(&existingPost)
 		
 		createdAt := s.parseTime(taskData.CTime)
 
@@ -424,15 +490,31 @@ func (s *Service) savePost(taskData *TaskData) error {
 				Images:      s.formatImages(taskData.Images),
 				Cover:       s.formatImages(taskData.Cover),
 				State:       s.formatState(taskData.IsDelete, taskData.IsComplaint, taskData.Choose, taskData.Hot),
-				Tag:         "未分析",
+				Tag:         classifier.Untagged,
 			}
 
 			if err := db.Create(&post).Error; err != nil {
 				return err
 			}
+			if err := metrics.Record(db, &post); err != nil {
+				return err
+			}
+			if err := dedup.Assign(db, &post); err != nil {
+				return err
+			}
+			event = &webhook.Event{Type: webhook.EventPostCreated, Post: &post}
 			log.Printf("Created new post: %d - %s", taskData.ID, taskData.Title)
 		} else if result.Error == nil {
-			// 更新现有帖子
+			// 更新现有帖子，内容变化时重新分类，人工标注的标签保留
+			if (existingPost.Title != taskData.Title || existingPost.Content != taskData.Content) &&
+				existingPost.TagSource != classifier.SourceManual {
+				existingPost.Tag = classifier.Untagged
+				existingPost.TagSource = ""
+			}
+			previousState := existingPost.State
+			countersChanged := existingPost.LikeNum != taskData.LikeNum ||
+				existingPost.ReplyCount != taskData.CommentNum ||
+				existingPost.ViewCount != taskData.WatchNum
 			existingPost.Title = taskData.Title
 			existingPost.Content = taskData.Content
 			existingPost.Author = taskData.UserName
@@ -449,12 +531,31 @@ func (s *Service) savePost(taskData *TaskData) error {
 			existingPost.Images = s.formatImages(taskData.Images)
 			existingPost.Cover = s.formatImages(taskData.Cover)
 			existingPost.State = s.formatState(taskData.IsDelete, taskData.IsComplaint, taskData.Choose, taskData.Hot)
-			existingPost.Tag = "未分析"
 			existingPost.UpdatedAt = time.Now()
 
-			if err := db.Save(&existingPost).Error; err != nil {
+			// 本站点赞数由点赞接口原子递增，不随整行写回，避免覆盖期间新增的点赞
+			if err := db.Omit("local_like_num").Save(&existingPost).Error; err != nil {
 				return err
 			}
+			// 只在计数变化时记录快照，减少数据量
+			if countersChanged {
+				if err := metrics.Record(db, &existingPost); err != nil {
+					return err
+				}
+			}
+			// 内容没有变化时直接返回，没有指纹的旧帖子在此补算
+			if err := dedup.Assign(db, &existingPost); err != nil {
+				return err
+			}
+			if err := bookmark.Refresh(db, &existingPost); err != nil {
+				return err
+			}
+			if existingPost.State != previousState {
+				event = &webhook.Event{Type: webhook.EventPostStateChanged, Post: &existingPost, Data: map[string]interface{}{
+					"previous_state": previousState,
+					"state":          existingPost.State,
+				}}
+			}
 			log.Printf("Updated post: %d - %s", taskData.ID, taskData.Title)
 		} else {
 			return result.Error
@@ -462,6 +563,31 @@ func (s *Service) savePost(taskData *TaskData) error {
 
 		return nil
 	})
+	if err == nil && event != nil {
+		s.emit(*event)
+	}
+	return err
+}
+
+// recordCounters 按有新回复的帖子列表中的点赞和浏览数更新帖子，并记录计数快照
+func (s *Service) recordCounters(taskData *TaskData) error {
+	return database.WithRetry(s.db, func(db *gorm.DB) error {
+		var post models.Post
+		if err := db.Unscoped().Where("original_id = ?", strconv.Itoa(taskData.ID)).First(&post).Error; err != nil {
+			return err
+		}
+		if post.LikeNum != taskData.LikeNum || post.ViewCount != taskData.WatchNum {
+			post.LikeNum = taskData.LikeNum
+			post.ViewCount = taskData.WatchNum
+			if err := db.Unscoped().Model(&post).Updates(map[string]interface{}{
+				"like_num":   taskData.LikeNum,
+				"view_count": taskData.WatchNum,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return metrics.Record(db, &post)
+	})
 }
 
 // scrapePostComments 抓取帖子的评论
@@ -473,7 +599,7 @@ func (s *Service) scrapePostComments(postID string) error {
 
 	// 获取帖子的数据库ID
 	var post models.Post
-	if err := s.db.Where("original_id = ?", postID).First(&post).Error; err != nil {
+	if err := s.db.Unscoped().Where("original_id = ?", postID).First(&post).Error; err != nil {
 		return err
 	}
 
@@ -482,7 +608,7 @@ func (s *Service) scrapePostComments(postID string) error {
 	for _, comment := range comments {
 		reply := s.buildReply(comment, post.ID)
 		if reply != nil {
-			s.saveCommentSingle(*reply) // 保存单条评论
+			s.saveCommentSingle(&post, *reply) // 保存单条评论
 			allReplies = append(allReplies, *reply)
 		}
 		
@@ -490,7 +616,7 @@ func (s *Service) scrapePostComments(postID string) error {
 		for _, nestedComment := range comment.CommentList {
 			nestedReply := s.buildReply(nestedComment, post.ID)
 			if nestedReply != nil {
-				s.saveCommentSingle(*nestedReply) // 保存嵌套评论
+				s.saveCommentSingle(&post, *nestedReply) // 保存嵌套评论
 				allReplies = append(allReplies, *nestedReply)
 			}
 		}
@@ -502,9 +628,9 @@ func (s *Service) scrapePostComments(postID string) error {
 
 // buildReply 构建回复对象
 func (s *Service) buildReply(comment CommentData, postID uint) *models.Reply {
-	// 检查是否已存在
+	// 检查是否已存在（包括被管理员隐藏的回复）
 	var existingReply models.Reply
-	if err := s.db.Where("original_id = ?", strconv.Itoa(comment.ID)).First(&existingReply).Error; err == nil {
+	if err := s.db.Unscoped().Where("original_id = ?", strconv.Itoa(comment.ID)).First(&existingReply).Error; err == nil {
 		return nil // 已存在
 	}
 
@@ -538,19 +664,23 @@ func (s *Service) buildReply(comment CommentData, postID uint) *models.Reply {
 		ParentID:   parentID,
 		LikeNum:    comment.LikeNum,
 		Images:     s.formatImages(comment.Images),
-		Tag:        "未分析",
+		Tag:        classifier.Untagged,
 		CreatedAt:  s.parseTime(comment.CTime),
 	}
 }
 
-// saveCommentSingle 保存单条评论
-func (s *Service) saveCommentSingle(reply models.Reply) {
+// saveCommentSingle 保存单条评论，帖子未被隐藏时发出新回复事件
+func (s *Service) saveCommentSingle(post *models.Post, reply models.Reply) {
 	err := database.WithRetry(s.db, func(db *gorm.DB) error {
 		return db.Create(&reply).Error
 	})
 	
 	if err != nil {
 		log.Printf("Failed to save reply %s: %v", reply.OriginalID, err)
+		return
+	}
+	if !post.DeletedAt.Valid {
+		s.emit(webhook.Event{Type: webhook.EventReplyCreated, Post: post, Reply: &reply})
 	}
 }
 
@@ -729,6 +859,31 @@ func (s *Service) formatImages(imgStr string) string {
 	return string(jsonArray)
 }
 
+// outboundImages 将本地图片列表转换为主站使用的逗号分隔格式
+// 本地上传的图片需要配置 PUBLIC_BASE_URL 才能被主站访问
+func (s *Service) outboundImages(imagesJSON string) string {
+	var images []string
+	if err := json.Unmarshal([]byte(imagesJSON), &images); err != nil || len(images) == 0 {
+		return "[]"
+	}
+
+	var urls []string
+	for _, image := range images {
+		if strings.HasPrefix(image, "/") {
+			if s.config.PublicBaseURL == "" {
+				log.Printf("Warning: PUBLIC_BASE_URL not set, skipping local image %s", image)
+				continue
+			}
+			image = s.config.PublicBaseURL + image
+		}
+		urls = append(urls, image)
+	}
+	if len(urls) == 0 {
+		return "[]"
+	}
+	return strings.Join(urls, ",")
+}
+
 // withRetry 通用重试函数
 func (s *Service) withRetry(operation func() error, maxRetries int, description string) error {
 	var lastError error
@@ -764,8 +919,11 @@ func (s *Service) SyncPostToMainSite(post models.Post) error {
 	title := url.QueryEscape(post.Title)
 	userName := url.QueryEscape(post.Author)
 
-	syncURL := fmt.Sprintf("%s/addtask?c_time=%s&content=%s&price=&title=%s&wechat=&avatar=http%%3A%%2F%%2Fyqtech.ltd%%2Fanimal%%2F4.png&radioGroup=radio40&campusGroup=2&userName=%s&img=%%5B%%5D&cover=%%5B%%5D&region=0&likeNum=0&commentNum=0&watchNum=%d&openid=%s",
-		s.baseURL, timeStr, content, title, userName, post.ViewCount, post.AuthorID)
+	img := url.QueryEscape(s.outboundImages(post.Images))
+	cover := url.QueryEscape(s.outboundImages(post.Cover))
+
+	syncURL := fmt.Sprintf("%s/addtask?c_time=%s&content=%s&price=&title=%s&wechat=&avatar=http%%3A%%2F%%2Fyqtech.ltd%%2Fanimal%%2F4.png&radioGroup=radio40&campusGroup=2&userName=%s&img=%s&cover=%s&region=0&likeNum=0&commentNum=0&watchNum=%d&openid=%s",
+		s.baseURL, timeStr, content, title, userName, img, cover, post.ViewCount, post.AuthorID)
 
 	// 使用重试机制发送请求到主站
 	err := s.withRetry(func() error {
@@ -823,8 +981,10 @@ func (s *Service) SyncReplyToMainSite(post models.Post, reply models.Reply) erro
 		pid, _ = strconv.Atoi(parentReply.OriginalID)
 	}
 
-	syncURL := fmt.Sprintf("%s/addcomment?c_time=%s&openid=%s&pk=%s&comment=%s&userName=%s&avatar=http%%3A%%2F%%2Fyqtech.ltd%%2Fanimal%%2F4.png&applyTo=%s&img=%%5B%%5D&level=%d&pid=%d",
-		s.baseURL, timeStr, reply.AuthorID, pk, content, userName, reply.ApplyTo, reply.Level, pid)
+	img := url.QueryEscape(s.outboundImages(reply.Images))
+
+	syncURL := fmt.Sprintf("%s/addcomment?c_time=%s&openid=%s&pk=%s&comment=%s&userName=%s&avatar=http%%3A%%2F%%2Fyqtech.ltd%%2Fanimal%%2F4.png&applyTo=%s&img=%s&level=%d&pid=%d",
+		s.baseURL, timeStr, reply.AuthorID, pk, content, userName, reply.ApplyTo, img, reply.Level, pid)
 
 	// 使用重试机制发送请求到主站
 	err := s.withRetry(func() error {
@@ -0,0 +1,66 @@
+package stats
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 时间序列的聚合粒度
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+	IntervalWeek = "week"
+)
+
+// dialect SQLite 和 MySQL 的日期函数不同，聚合表达式按数据库类型生成
+type dialect struct {
+	mysql bool
+}
+
+// newDialect 根据数据库连接判断类型
+func newDialect(db *gorm.DB) dialect {
+	return dialect{mysql: db.Dialector.Name() == "mysql"}
+}
+
+// sqliteTime SQLite 中时间以文本保存，只取前 19 个字符（YYYY-MM-DD HH:MM:SS）交给日期函数解析
+func sqliteTime(column string) string {
+	return fmt.Sprintf("substr(%s, 1, 19)", column)
+}
+
+// bucket 时间段标签表达式，小时为 "YYYY-MM-DD HH:00"，天为 "YYYY-MM-DD"，周为周一的日期
+func (d dialect) bucket(column, interval string) string {
+	if d.mysql {
+		switch interval {
+		case IntervalHour:
+			return fmt.Sprintf("DATE_FORMAT(%s, '%%Y-%%m-%%d %%H:00')", column)
+		case IntervalWeek:
+			return fmt.Sprintf("DATE_FORMAT(DATE_SUB(%s, INTERVAL WEEKDAY(%s) DAY), '%%Y-%%m-%%d')", column, column)
+		}
+		return fmt.Sprintf("DATE_FORMAT(%s, '%%Y-%%m-%%d')", column)
+	}
+
+	switch interval {
+	case IntervalHour:
+		return fmt.Sprintf("strftime('%%Y-%%m-%%d %%H:00', %s)", sqliteTime(column))
+	case IntervalWeek:
+		return fmt.Sprintf("date(%s, '-6 days', 'weekday 1')", sqliteTime(column))
+	}
+	return fmt.Sprintf("strftime('%%Y-%%m-%%d', %s)", sqliteTime(column))
+}
+
+// weekday 星期几表达式，0 为周日
+func (d dialect) weekday(column string) string {
+	if d.mysql {
+		return fmt.Sprintf("(DAYOFWEEK(%s) - 1)", column)
+	}
+	return fmt.Sprintf("CAST(strftime('%%w', %s) AS INTEGER)", sqliteTime(column))
+}
+
+// hour 小时表达式
+func (d dialect) hour(column string) string {
+	if d.mysql {
+		return fmt.Sprintf("HOUR(%s)", column)
+	}
+	return fmt.Sprintf("CAST(strftime('%%H', %s) AS INTEGER)", sqliteTime(column))
+}
@@ -0,0 +1,349 @@
+package stats
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"treehole/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 可以分组统计的帖子字段
+var breakdownFields = map[string]bool{
+	"radio_group":  true,
+	"campus_group": true,
+	"region":       true,
+}
+
+// 同步记录中的结束状态，开始同步时写入的 running 记录不计入吞吐
+const (
+	syncSuccess = "success"
+	syncError   = "error"
+)
+
+// Point 时间序列中的一个时间段
+type Point struct {
+	Bucket  string `json:"bucket"`
+	Posts   int64  `json:"posts"`
+	Replies int64  `json:"replies"`
+}
+
+// Heatmap 按星期和小时统计的发帖与回复数量，第一维为星期（0 为周日），第二维为小时
+type Heatmap struct {
+	Posts   [7][24]int64 `json:"posts"`
+	Replies [7][24]int64 `json:"replies"`
+}
+
+// Group 按字段分组的帖子数量和回复总数
+type Group struct {
+	Value   string `json:"value"`
+	Posts   int64  `json:"posts"`
+	Replies int64  `json:"replies"`
+}
+
+// TagCount 标签下新增的帖子数量
+type TagCount struct {
+	Name  string `json:"name"`
+	Posts int64  `json:"posts"`
+}
+
+// ThreadCount 帖子在时间范围内新增的回复数量
+type ThreadCount struct {
+	PostID  uint  `json:"post_id"`
+	Replies int64 `json:"replies"`
+}
+
+// SyncPoint 一个时间段内的同步次数和同步的数据量
+type SyncPoint struct {
+	Bucket  string `json:"bucket"`
+	Runs    int64  `json:"runs"`
+	Success int64  `json:"success"`
+	Errors  int64  `json:"errors"`
+	Posts   int64  `json:"posts"`
+	Replies int64  `json:"replies"`
+}
+
+// cacheEntry 缓存的统计结果
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// Service 统计服务
+// 统计结果由 SQL 聚合得出，相同参数的查询在缓存有效期内直接返回缓存结果
+type Service struct {
+	db      *gorm.DB
+	dialect dialect
+	ttl     time.Duration
+
+	mutex sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// NewService 创建统计服务，ttl 为 0 时不缓存
+func NewService(db *gorm.DB, ttl time.Duration) *Service {
+	return &Service{
+		db:      db,
+		dialect: newDialect(db),
+		ttl:     ttl,
+		cache:   make(map[string]cacheEntry),
+	}
+}
+
+// ValidInterval 检查时间序列的聚合粒度
+func ValidInterval(interval string) bool {
+	return interval == IntervalHour || interval == IntervalDay || interval == IntervalWeek
+}
+
+// ValidBreakdownField 检查分组字段
+func ValidBreakdownField(field string) bool {
+	return breakdownFields[field]
+}
+
+// Timeseries 统计自 since 以来每个时间段的发帖和回复数量，没有数据的时间段补零
+func (s *Service) Timeseries(since time.Time, interval string) ([]Point, error) {
+	key := fmt.Sprintf("timeseries|%s|%d", interval, since.Unix()/60)
+	value, err := s.cached(key, func() (interface{}, error) {
+		posts, err := s.countByBucket(s.db.Model(&models.Post{}), since, interval)
+		if err != nil {
+			return nil, err
+		}
+		replies, err := s.countByBucket(s.db.Model(&models.Reply{}), since, interval)
+		if err != nil {
+			return nil, err
+		}
+
+		buckets := bucketLabels(since, time.Now(), interval)
+		points := make([]Point, 0, len(buckets))
+		for _, bucket := range buckets {
+			points = append(points, Point{Bucket: bucket, Posts: posts[bucket], Replies: replies[bucket]})
+		}
+		return points, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]Point), nil
+}
+
+// Heatmap 统计自 since 以来各星期、各小时的发帖和回复数量
+func (s *Service) Heatmap(since time.Time) (*Heatmap, error) {
+	key := fmt.Sprintf("heatmap|%d", since.Unix()/60)
+	value, err := s.cached(key, func() (interface{}, error) {
+		heatmap := &Heatmap{}
+		if err := s.countByWeekdayHour(s.db.Model(&models.Post{}), since, &heatmap.Posts); err != nil {
+			return nil, err
+		}
+		if err := s.countByWeekdayHour(s.db.Model(&models.Reply{}), since, &heatmap.Replies); err != nil {
+			return nil, err
+		}
+		return heatmap, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*Heatmap), nil
+}
+
+// Breakdown 按 radio_group、campus_group 或 region 统计自 since 以来发布的帖子，按帖子数量降序
+func (s *Service) Breakdown(field string, since time.Time) ([]Group, error) {
+	if !ValidBreakdownField(field) {
+		return nil, fmt.Errorf("invalid breakdown field %q", field)
+	}
+	key := fmt.Sprintf("breakdown|%s|%d", field, since.Unix()/60)
+	value, err := s.cached(key, func() (interface{}, error) {
+		var groups []Group
+		err := s.db.Model(&models.Post{}).
+			Select(field+" AS value, COUNT(*) AS posts, COALESCE(SUM(reply_count), 0) AS replies").
+			Where("created_at >= ?", since).
+			Group(field).
+			Order("posts desc, value asc").
+			Scan(&groups).Error
+		return groups, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]Group), nil
+}
+
+// TopTags 自 since 以来发布的帖子中使用最多的标签
+func (s *Service) TopTags(since time.Time, limit int) ([]TagCount, error) {
+	key := fmt.Sprintf("top-tags|%d|%d", since.Unix()/60, limit)
+	value, err := s.cached(key, func() (interface{}, error) {
+		var tags []TagCount
+		err := s.db.Table("post_tags").
+			Select("tags.name AS name, COUNT(*) AS posts").
+			Joins("JOIN tags ON tags.id = post_tags.tag_id").
+			Joins("JOIN posts ON posts.id = post_tags.post_id AND posts.deleted_at IS NULL").
+			Where("posts.created_at >= ?", since).
+			Group("tags.name").
+			Order("posts desc, name asc").
+			Limit(limit).
+			Scan(&tags).Error
+		return tags, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]TagCount), nil
+}
+
+// TopThreads 自 since 以来新增回复最多的帖子
+func (s *Service) TopThreads(since time.Time, limit int) ([]ThreadCount, error) {
+	key := fmt.Sprintf("top-threads|%d|%d", since.Unix()/60, limit)
+	value, err := s.cached(key, func() (interface{}, error) {
+		var threads []ThreadCount
+		err := s.db.Model(&models.Reply{}).
+			Select("replies.post_id AS post_id, COUNT(*) AS replies").
+			Joins("JOIN posts ON posts.id = replies.post_id AND posts.deleted_at IS NULL").
+			Where("replies.created_at >= ?", since).
+			Group("replies.post_id").
+			Order("replies desc, post_id desc").
+			Limit(limit).
+			Scan(&threads).Error
+		return threads, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]ThreadCount), nil
+}
+
+// SyncThroughput 统计自 since 以来每个时间段完成的同步次数和同步的帖子、回复数量
+func (s *Service) SyncThroughput(since time.Time, interval string) ([]SyncPoint, error) {
+	key := fmt.Sprintf("sync|%s|%d", interval, since.Unix()/60)
+	value, err := s.cached(key, func() (interface{}, error) {
+		bucket := s.dialect.bucket("last_sync_time", interval)
+		var rows []SyncPoint
+		if err := s.db.Model(&models.SyncStatus{}).
+			Select(bucket+" AS bucket, COUNT(*) AS runs, "+
+				"SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS success, "+
+				"SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS errors, "+
+				"COALESCE(SUM(total_posts), 0) AS posts, COALESCE(SUM(total_replies), 0) AS replies",
+				syncSuccess, syncError).
+			Where("status IN ? AND last_sync_time >= ?", []string{syncSuccess, syncError}, since).
+			Group("bucket").
+			Scan(&rows).Error; err != nil {
+			return nil, err
+		}
+
+		byBucket := make(map[string]SyncPoint, len(rows))
+		for _, row := range rows {
+			byBucket[row.Bucket] = row
+		}
+		buckets := bucketLabels(since, time.Now(), interval)
+		points := make([]SyncPoint, 0, len(buckets))
+		for _, bucket := range buckets {
+			point := byBucket[bucket]
+			point.Bucket = bucket
+			points = append(points, point)
+		}
+		return points, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.([]SyncPoint), nil
+}
+
+// countByBucket 按时间段统计记录数量
+func (s *Service) countByBucket(query *gorm.DB, since time.Time, interval string) (map[string]int64, error) {
+	var rows []struct {
+		Bucket string
+		Total  int64
+	}
+	if err := query.Select(s.dialect.bucket("created_at", interval)+" AS bucket, COUNT(*) AS total").
+		Where("created_at >= ?", since).
+		Group("bucket").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		result[row.Bucket] = row.Total
+	}
+	return result, nil
+}
+
+// countByWeekdayHour 按星期和小时统计记录数量
+func (s *Service) countByWeekdayHour(query *gorm.DB, since time.Time, matrix *[7][24]int64) error {
+	var rows []struct {
+		Weekday int
+		Hour    int
+		Total   int64
+	}
+	if err := query.Select(s.dialect.weekday("created_at")+" AS weekday, "+s.dialect.hour("created_at")+" AS hour, COUNT(*) AS total").
+		Where("created_at >= ?", since).
+		Group("weekday, hour").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if row.Weekday >= 0 && row.Weekday < 7 && row.Hour >= 0 && row.Hour < 24 {
+			matrix[row.Weekday][row.Hour] += row.Total
+		}
+	}
+	return nil
+}
+
+// cached 返回缓存中未过期的结果，否则执行查询并缓存
+// 缓存键中的时间精确到分钟，同一分钟内的相同查询共享结果
+func (s *Service) cached(key string, load func() (interface{}, error)) (interface{}, error) {
+	now := time.Now()
+	if s.ttl > 0 {
+		s.mutex.Lock()
+		entry, ok := s.cache[key]
+		s.mutex.Unlock()
+		if ok && now.Before(entry.expires) {
+			return entry.value, nil
+		}
+	}
+
+	value, err := load()
+	if err != nil || s.ttl <= 0 {
+		return value, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for k, entry := range s.cache {
+		if !now.Before(entry.expires) {
+			delete(s.cache, k)
+		}
+	}
+	s.cache[key] = cacheEntry{value: value, expires: now.Add(s.ttl)}
+	return value, nil
+}
+
+// bucketLabels 生成 [from, to] 范围内的全部时间段标签，格式与 SQL 聚合表达式一致
+func bucketLabels(from, to time.Time, interval string) []string {
+	from, to = from.Local(), to.Local()
+	var start time.Time
+	var step func(time.Time) time.Time
+	var layout string
+
+	switch interval {
+	case IntervalHour:
+		start = time.Date(from.Year(), from.Month(), from.Day(), from.Hour(), 0, 0, 0, from.Location())
+		step = func(t time.Time) time.Time { return t.Add(time.Hour) }
+		layout = "2006-01-02 15:00"
+	case IntervalWeek:
+		// 以周一为一周的开始
+		offset := (int(from.Weekday()) + 6) % 7
+		start = time.Date(from.Year(), from.Month(), from.Day()-offset, 0, 0, 0, 0, from.Location())
+		step = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+		layout = "2006-01-02"
+	default:
+		start = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+		step = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+		layout = "2006-01-02"
+	}
+
+	var labels []string
+	for t := start; !t.After(to); t = step(t) {
+		labels = append(labels, t.Format(layout))
+	}
+	return labels
+}
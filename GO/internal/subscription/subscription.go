@@ -0,0 +1,258 @@
+package subscription
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"treehole/internal/models"
+	"treehole/internal/notify"
+
+	"gorm.io/gorm"
+)
+
+// 帖子订阅产生的通知类型
+const (
+	TypeThreadReply = "thread_reply" // 订阅的帖子有新回复
+	TypeReplyToYou  = "reply_to_you" // 回复了订阅者的帖子或回复
+)
+
+// batchSize 每批检查的回复数量
+const batchSize = 500
+
+// maxNotificationsPerRun 每个订阅在一次检查中最多产生的通知数量，避免大量同步时刷屏
+const maxNotificationsPerRun = 50
+
+// 通知中标题和内容摘要的长度（按字符计）
+const (
+	titleLength   = 100
+	excerptLength = 100
+)
+
+// Service 帖子订阅服务
+// 检查各订阅上次检查之后帖子下的新回复，回复自己的内容单独标记
+// 同步结束和本地创建回复后都会触发检查，检查过程串行执行，运行期间的多次触发合并为一次
+type Service struct {
+	db     *gorm.DB
+	notify *notify.Service
+
+	running sync.Mutex
+	pending atomic.Bool // 有未处理的触发
+}
+
+// NewService 创建帖子订阅服务
+func NewService(db *gorm.DB, notifier *notify.Service) *Service {
+	return &Service{db: db, notify: notifier}
+}
+
+// Subscribe 订阅帖子，已订阅时直接返回原订阅
+// authorID 是订阅者在该帖子中的匿名作者ID，新订阅只通知之后的回复
+func Subscribe(db *gorm.DB, subscriber, authorID string, postID uint) (*models.ThreadSubscription, error) {
+	var subscription models.ThreadSubscription
+	result := db.Where("subscriber = ? AND post_id = ?", subscriber, postID).Limit(1).Find(&subscription)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return &subscription, nil
+	}
+
+	var maxReplyID uint
+	if err := db.Unscoped().Model(&models.Reply{}).Select("COALESCE(MAX(id), 0)").Scan(&maxReplyID).Error; err != nil {
+		return nil, err
+	}
+	subscription = models.ThreadSubscription{
+		Subscriber:  subscriber,
+		PostID:      postID,
+		AuthorID:    authorID,
+		LastReplyID: maxReplyID,
+	}
+	if err := db.Create(&subscription).Error; err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// Process 检查所有订阅的新回复，返回发送的通知数量
+func (s *Service) Process() (int, error) {
+	s.running.Lock()
+	defer s.running.Unlock()
+	return s.process()
+}
+
+// process 检查所有订阅的新回复，调用方需持有 running
+// 从最早的订阅游标开始按批读取回复，只加载这些回复所在帖子的订阅
+func (s *Service) process() (int, error) {
+	var cursor struct {
+		MinReplyID uint
+		MaxID      uint
+	}
+	if err := s.db.Model(&models.ThreadSubscription{}).
+		Select("COALESCE(MIN(last_reply_id), 0) AS min_reply_id, COALESCE(MAX(id), 0) AS max_id").
+		Scan(&cursor).Error; err != nil {
+		return 0, err
+	}
+	if cursor.MaxID == 0 {
+		return 0, nil
+	}
+
+	after := cursor.MinReplyID
+	byPost := make(map[uint][]*models.ThreadSubscription)
+	loaded := make(map[uint]bool)
+	var notifications []models.Notification
+	counts := make(map[uint]int)
+	for {
+		var replies []models.Reply
+		if err := s.db.Unscoped().Select("id, post_id, author_id, apply_to, parent_id, content, deleted_at").
+			Where("id > ?", after).Order("id asc").Limit(batchSize).
+			Find(&replies).Error; err != nil {
+			return 0, err
+		}
+		if len(replies) == 0 {
+			break
+		}
+
+		if err := s.loadSubscriptions(replies, cursor.MaxID, byPost, loaded); err != nil {
+			return 0, err
+		}
+		batch, err := s.notifyReplies(replies, byPost, counts)
+		if err != nil {
+			return 0, err
+		}
+		notifications = append(notifications, batch...)
+		after = replies[len(replies)-1].ID
+		if len(replies) < batchSize {
+			break
+		}
+	}
+
+	if err := s.notify.Send(notifications); err != nil {
+		return 0, err
+	}
+	// 检查开始前已有的订阅都已检查到 after，检查期间新建的订阅保留自己的起点
+	if err := s.db.Model(&models.ThreadSubscription{}).
+		Where("id <= ? AND last_reply_id < ?", cursor.MaxID, after).
+		UpdateColumn("last_reply_id", after).Error; err != nil {
+		return len(notifications), err
+	}
+	return len(notifications), nil
+}
+
+// ProcessJob 供同步结束和创建回复后调用的检查任务
+// 已有检查在运行时只标记待处理并立即返回，由运行中的检查结束后再执行一次
+func (s *Service) ProcessJob() {
+	s.pending.Store(true)
+	for s.pending.Load() && s.running.TryLock() {
+		for s.pending.Swap(false) {
+			sent, err := s.process()
+			if err != nil {
+				log.Printf("Thread subscription check failed: %v", err)
+			} else if sent > 0 {
+				log.Printf("Thread subscription check sent %d notifications", sent)
+			}
+		}
+		s.running.Unlock()
+	}
+}
+
+// loadSubscriptions 加载回复所在帖子中尚未加载的订阅，检查开始后新建的订阅（ID 大于 maxID）不参与本次检查
+func (s *Service) loadSubscriptions(replies []models.Reply, maxID uint, byPost map[uint][]*models.ThreadSubscription, loaded map[uint]bool) error {
+	var postIDs []uint
+	for _, reply := range replies {
+		if !loaded[reply.PostID] {
+			loaded[reply.PostID] = true
+			postIDs = append(postIDs, reply.PostID)
+		}
+	}
+	if len(postIDs) == 0 {
+		return nil
+	}
+
+	var subscriptions []models.ThreadSubscription
+	if err := s.db.Where("post_id IN ? AND id <= ?", postIDs, maxID).Find(&subscriptions).Error; err != nil {
+		return err
+	}
+	for i := range subscriptions {
+		byPost[subscriptions[i].PostID] = append(byPost[subscriptions[i].PostID], &subscriptions[i])
+	}
+	return nil
+}
+
+// notifyReplies 为一批回复生成订阅通知，隐藏的帖子和回复不通知，订阅者自己的回复不通知
+func (s *Service) notifyReplies(replies []models.Reply, byPost map[uint][]*models.ThreadSubscription, counts map[uint]int) ([]models.Notification, error) {
+	var postIDs, parentIDs []uint
+	for _, reply := range replies {
+		if _, ok := byPost[reply.PostID]; !ok {
+			continue
+		}
+		postIDs = append(postIDs, reply.PostID)
+		if reply.ParentID > 0 {
+			parentIDs = append(parentIDs, uint(reply.ParentID))
+		}
+	}
+	if len(postIDs) == 0 {
+		return nil, nil
+	}
+
+	var posts []models.Post
+	if err := s.db.Select("id, title").Where("id IN ?", postIDs).Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	titles := make(map[uint]string, len(posts))
+	for _, post := range posts {
+		titles[post.ID] = post.Title
+	}
+
+	// 父回复的作者用于识别回复给订阅者的评论
+	parentAuthors := make(map[uint]string)
+	if len(parentIDs) > 0 {
+		var parents []models.Reply
+		if err := s.db.Unscoped().Select("id, author_id").Where("id IN ?", parentIDs).Find(&parents).Error; err != nil {
+			return nil, err
+		}
+		for _, parent := range parents {
+			parentAuthors[parent.ID] = parent.AuthorID
+		}
+	}
+
+	var notifications []models.Notification
+	for _, reply := range replies {
+		title, visible := titles[reply.PostID]
+		if !visible || reply.DeletedAt.Valid {
+			continue
+		}
+		for _, subscription := range byPost[reply.PostID] {
+			if reply.ID <= subscription.LastReplyID || counts[subscription.ID] >= maxNotificationsPerRun {
+				continue
+			}
+			if subscription.AuthorID != "" && reply.AuthorID == subscription.AuthorID {
+				continue
+			}
+
+			notificationType := TypeThreadReply
+			if subscription.AuthorID != "" && (reply.ApplyTo == subscription.AuthorID ||
+				(reply.ParentID > 0 && parentAuthors[uint(reply.ParentID)] == subscription.AuthorID)) {
+				notificationType = TypeReplyToYou
+			}
+			replyID := reply.ID
+			notifications = append(notifications, models.Notification{
+				Subscriber: subscription.Subscriber,
+				Type:       notificationType,
+				PostID:     reply.PostID,
+				ReplyID:    &replyID,
+				Title:      truncate(title, titleLength),
+				Excerpt:    truncate(reply.Content, excerptLength),
+			})
+			counts[subscription.ID]++
+		}
+	}
+	return notifications, nil
+}
+
+// truncate 按字符截断文本
+func truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return string(runes[:length]) + "…"
+}
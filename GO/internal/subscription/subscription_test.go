@@ -0,0 +1,97 @@
+package subscription
+
+import (
+	"path/filepath"
+	"testing"
+	"treehole/internal/database"
+	"treehole/internal/models"
+	"treehole/internal/notify"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB 在临时目录中创建迁移好的 SQLite 数据库
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+func mustCreate(t *testing.T, db *gorm.DB, values ...interface{}) {
+	t.Helper()
+	for _, value := range values {
+		if err := db.Create(value).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestProcess(t *testing.T) {
+	db := openTestDB(t)
+	service := NewService(db, notify.NewService(db))
+
+	watched := models.Post{OriginalID: "1", Title: "订阅的帖子", State: "normal"}
+	other := models.Post{OriginalID: "2", Title: "其他帖子", State: "normal"}
+	mustCreate(t, db, &watched, &other)
+	own := models.Reply{PostID: watched.ID, OriginalID: "10", Content: "订阅者的回复", AuthorID: "author-a"}
+	mustCreate(t, db, &own)
+	if _, err := Subscribe(db, "subscriber", "author-a", watched.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	replies := []*models.Reply{
+		{PostID: watched.ID, OriginalID: "11", Content: "路过", AuthorID: "author-b"},
+		{PostID: watched.ID, OriginalID: "12", Content: "回复你", AuthorID: "author-c", ParentID: int(own.ID)},
+		{PostID: watched.ID, OriginalID: "13", Content: "自己的回复", AuthorID: "author-a"},
+		{PostID: other.ID, OriginalID: "20", Content: "其他帖子的回复", AuthorID: "author-b"},
+	}
+	for _, reply := range replies {
+		mustCreate(t, db, reply)
+	}
+
+	sent, err := service.Process()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent != 2 {
+		t.Fatalf("sent %d notifications, want 2", sent)
+	}
+	var notifications []models.Notification
+	if err := db.Order("id asc").Find(&notifications).Error; err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		typ     string
+		replyID uint
+	}{{TypeThreadReply, replies[0].ID}, {TypeReplyToYou, replies[1].ID}}
+	for i, notification := range notifications {
+		if notification.Type != want[i].typ || notification.ReplyID == nil || *notification.ReplyID != want[i].replyID {
+			t.Errorf("notification %d = %s reply %v, want %s reply %d", i, notification.Type, notification.ReplyID, want[i].typ, want[i].replyID)
+		}
+	}
+
+	var subscription models.ThreadSubscription
+	if err := db.First(&subscription).Error; err != nil {
+		t.Fatal(err)
+	}
+	if subscription.LastReplyID != replies[3].ID {
+		t.Errorf("last_reply_id = %d, want %d", subscription.LastReplyID, replies[3].ID)
+	}
+
+	if sent, err := service.Process(); err != nil || sent != 0 {
+		t.Errorf("second Process() = %d, %v, want no notifications", sent, err)
+	}
+}
@@ -0,0 +1,439 @@
+package suggest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"treehole/internal/classifier"
+	"treehole/internal/database"
+	"treehole/internal/filter"
+	"treehole/internal/models"
+	"unicode"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxLimit 每次最多返回的建议数量，也是前缀树每个节点保留的结果数量
+const MaxLimit = 20
+
+// 各来源建议的权重，用户实际搜索过的词排在前面
+const (
+	queryWeight = 3.0
+	tagWeight   = 2.0
+	titleWeight = 1.0
+)
+
+// 建议来源
+const (
+	SourceQuery = "query"
+	SourceTag   = "tag"
+	SourceTitle = "title"
+)
+
+// 标题短语的提取范围
+const (
+	titlePostLimit = 20000 // 只统计最近发布的帖子
+	minGramLength  = 2
+	maxGramLength  = 6
+	minGramCount   = 3    // 至少在这么多个标题中出现
+	maxTitleGrams  = 5000 // 最多保留的短语数量
+)
+
+// maxQueryLength 记录的搜索词最大长度（按字符计），过长的搜索词通常是粘贴的内容
+const maxQueryLength = 32
+
+// maxPending 内存中等待写入的不同搜索词数量上限
+const maxPending = 10000
+
+// ErrRunning 已有重建任务在运行
+var ErrRunning = errors.New("suggestion index rebuild is already running")
+
+// Config 搜索建议配置
+type Config struct {
+	QueryDays     int // 统计最近多少天的搜索词，更早的记录在重建时删除
+	MinQueryCount int // 搜索人数达到该值的搜索词才会作为建议，避免个人的搜索内容被展示
+}
+
+// RebuildResult 一次重建的统计
+type RebuildResult struct {
+	Queries int `json:"queries"`
+	Tags    int `json:"tags"`
+	Titles  int `json:"titles"`
+	Total   int `json:"total"`
+}
+
+// Service 搜索建议服务
+// 热门搜索词、标签和标题中的高频短语建立为内存前缀树，定时任务写入搜索记录并重建
+type Service struct {
+	db     *gorm.DB
+	config Config
+
+	mutex sync.RWMutex
+	trie  *trie
+
+	pendingMutex     sync.Mutex
+	pending          map[string]int
+	pendingSearchers map[string]map[string]bool // 搜索词对应的搜索者哈希
+
+	running sync.Mutex
+}
+
+// NewService 创建搜索建议服务，前缀树在第一次重建后可用
+func NewService(db *gorm.DB, cfg Config) *Service {
+	if cfg.QueryDays <= 0 {
+		cfg.QueryDays = 30
+	}
+	if cfg.MinQueryCount <= 0 {
+		cfg.MinQueryCount = 1
+	}
+	return &Service{
+		db:               db,
+		config:           cfg,
+		trie:             newTrie(nil, MaxLimit),
+		pending:          make(map[string]int),
+		pendingSearchers: make(map[string]map[string]bool),
+	}
+}
+
+// NormalizeQuery 统一搜索词的大小写和空白
+func NormalizeQuery(query string) string {
+	return strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
+
+// Record 记录一次搜索，按天汇总次数和搜索人数，client 为客户端身份标识
+// 过长或包含联系方式、长串数字的搜索词可能涉及个人信息，不记录
+func (s *Service) Record(query, client string) {
+	query = NormalizeQuery(query)
+	if query == "" || utf8.RuneCountInString(query) > maxQueryLength || !recordable(query) {
+		return
+	}
+
+	s.pendingMutex.Lock()
+	defer s.pendingMutex.Unlock()
+	if _, ok := s.pending[query]; !ok && len(s.pending) >= maxPending {
+		return
+	}
+	s.pending[query]++
+	if s.pendingSearchers[query] == nil {
+		s.pendingSearchers[query] = make(map[string]bool)
+	}
+	s.pendingSearchers[query][searcherHash(query, client)] = true
+}
+
+// searcherHash 搜索者在某个搜索词下的标识，不同搜索词的标识无法关联
+func searcherHash(query, client string) string {
+	sum := sha256.Sum256([]byte(query + "|" + client))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Suggest 返回以 prefix 开头的建议，按得分降序
+func (s *Service) Suggest(prefix string, limit int) []Suggestion {
+	prefix = NormalizeQuery(prefix)
+	if prefix == "" {
+		return nil
+	}
+	if limit <= 0 || limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	s.mutex.RLock()
+	t := s.trie
+	s.mutex.RUnlock()
+	return t.find(prefix, limit)
+}
+
+// Rebuild 写入待保存的搜索记录，清理过期记录并重建前缀树
+func (s *Service) Rebuild() (*RebuildResult, error) {
+	if !s.running.TryLock() {
+		return nil, ErrRunning
+	}
+	defer s.running.Unlock()
+
+	if err := s.flush(); err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().AddDate(0, 0, -s.config.QueryDays).Format("2006-01-02")
+	if err := s.db.Where("day < ?", cutoff).Delete(&models.SearchQuery{}).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("day < ?", cutoff).Delete(&models.SearchQuerySearcher{}).Error; err != nil {
+		return nil, err
+	}
+
+	entries := make(map[string]*Suggestion)
+	result := &RebuildResult{}
+	var err error
+	if result.Titles, err = s.addTitleGrams(entries); err != nil {
+		return nil, err
+	}
+	if result.Tags, err = s.addTags(entries); err != nil {
+		return nil, err
+	}
+	if result.Queries, err = s.addQueries(entries, cutoff); err != nil {
+		return nil, err
+	}
+	result.Total = len(entries)
+
+	t := newTrie(entries, MaxLimit)
+	s.mutex.Lock()
+	s.trie = t
+	s.mutex.Unlock()
+	return result, nil
+}
+
+// RebuildJob 供定时任务调用的重建任务
+func (s *Service) RebuildJob() {
+	result, err := s.Rebuild()
+	if errors.Is(err, ErrRunning) {
+		log.Println("Previous suggestion rebuild is still running, skipping this execution")
+		return
+	}
+	if err != nil {
+		log.Printf("Suggestion rebuild failed: %v", err)
+		return
+	}
+	log.Printf("Suggestion index rebuilt: %d queries, %d tags, %d title phrases", result.Queries, result.Tags, result.Titles)
+}
+
+// flush 将内存中的搜索次数和搜索者累加到当天的记录
+func (s *Service) flush() error {
+	s.pendingMutex.Lock()
+	pending, searchers := s.pending, s.pendingSearchers
+	s.pending = make(map[string]int)
+	s.pendingSearchers = make(map[string]map[string]bool)
+	s.pendingMutex.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	day := time.Now().Format("2006-01-02")
+	return database.SafeTransaction(s.db, func(tx *gorm.DB) error {
+		for query, count := range pending {
+			result := tx.Model(&models.SearchQuery{}).
+				Where("query = ? AND day = ?", query, day).
+				Update("count", gorm.Expr("count + ?", count))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				continue
+			}
+			if err := tx.Create(&models.SearchQuery{Query: query, Day: day, Count: count}).Error; err != nil {
+				return err
+			}
+		}
+		// 同一搜索者当天重复搜索只记录一次
+		for query, hashes := range searchers {
+			for searcher := range hashes {
+				record := models.SearchQuerySearcher{Query: query, Day: day, Searcher: searcher}
+				if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// addQueries 加入统计范围内搜索人数足够多的搜索词，按搜索次数计分
+// 同一个人反复搜索只能提高次数，不能让搜索词达到展示门槛
+func (s *Service) addQueries(entries map[string]*Suggestion, cutoff string) (int, error) {
+	searchers := s.db.Model(&models.SearchQuerySearcher{}).
+		Select("query").
+		Where("day >= ?", cutoff).
+		Group("query").
+		Having("COUNT(DISTINCT searcher) >= ?", s.config.MinQueryCount)
+
+	var rows []struct {
+		Query string
+		Total int
+	}
+	if err := s.db.Model(&models.SearchQuery{}).
+		Select("query, SUM(count) AS total").
+		Where("day >= ? AND query IN (?)", cutoff, searchers).
+		Group("query").
+		Scan(&rows).Error; err != nil {
+		return 0, err
+	}
+	for _, row := range rows {
+		add(entries, row.Query, SourceQuery, queryWeight*float64(row.Total))
+	}
+	return len(rows), nil
+}
+
+// addTags 加入标签名称和别名，按未隐藏的帖子数量计分
+func (s *Service) addTags(entries map[string]*Suggestion) (int, error) {
+	var tags []models.Tag
+	if err := s.db.Find(&tags).Error; err != nil {
+		return 0, err
+	}
+	var counts []struct {
+		TagID uint
+		Total int
+	}
+	if err := s.db.Table("post_tags").
+		Select("post_tags.tag_id AS tag_id, COUNT(*) AS total").
+		Joins("JOIN posts ON posts.id = post_tags.post_id AND posts.deleted_at IS NULL").
+		Group("post_tags.tag_id").
+		Scan(&counts).Error; err != nil {
+		return 0, err
+	}
+	countByTag := make(map[uint]int, len(counts))
+	for _, count := range counts {
+		countByTag[count.TagID] = count.Total
+	}
+
+	for _, tag := range tags {
+		score := tagWeight * float64(countByTag[tag.ID]+1)
+		add(entries, tag.Name, SourceTag, score)
+		for _, alias := range classifier.SplitAliases(tag.Aliases) {
+			add(entries, alias, SourceTag, score)
+		}
+	}
+	return len(tags), nil
+}
+
+// addTitleGrams 统计最近帖子标题中的短语，保留出现在足够多标题中且不被更长短语覆盖的短语
+func (s *Service) addTitleGrams(entries map[string]*Suggestion) (int, error) {
+	var titles []string
+	if err := s.db.Model(&models.Post{}).
+		Order("created_at desc").
+		Limit(titlePostLimit).
+		Pluck("title", &titles).Error; err != nil {
+		return 0, err
+	}
+
+	counts := make(map[string]int)
+	for _, title := range titles {
+		seen := make(map[string]bool)
+		for _, gram := range titleGrams(title) {
+			if !seen[gram] {
+				seen[gram] = true
+				counts[gram]++
+			}
+		}
+	}
+
+	// 记录每个短语前后各加一个字得到的更长短语的最大出现次数
+	extended := make(map[string]int)
+	for gram, count := range counts {
+		runes := []rune(gram)
+		if len(runes) <= minGramLength || !isHan(runes[0]) {
+			continue
+		}
+		for _, shorter := range []string{string(runes[1:]), string(runes[:len(runes)-1])} {
+			if count > extended[shorter] {
+				extended[shorter] = count
+			}
+		}
+	}
+
+	// 短语的绝大多数出现都属于某个更长的短语时不单独作为建议，如“自行”被“自行车”覆盖
+	type gramCount struct {
+		gram  string
+		count int
+	}
+	var grams []gramCount
+	for gram, count := range counts {
+		if count < minGramCount || float64(extended[gram]) >= 0.8*float64(count) {
+			continue
+		}
+		grams = append(grams, gramCount{gram: gram, count: count})
+	}
+	if len(grams) > maxTitleGrams {
+		// 只保留出现次数最多的短语
+		sort.Slice(grams, func(i, j int) bool {
+			if grams[i].count != grams[j].count {
+				return grams[i].count > grams[j].count
+			}
+			return grams[i].gram < grams[j].gram
+		})
+		grams = grams[:maxTitleGrams]
+	}
+	for _, g := range grams {
+		add(entries, g.gram, SourceTitle, titleWeight*float64(g.count))
+	}
+	return len(grams), nil
+}
+
+// titleGrams 提取标题中长度为 2 到 6 个字的中文短语和英文单词
+func titleGrams(title string) []string {
+	var grams []string
+	var han []rune
+	var word []rune
+
+	flushHan := func() {
+		for n := minGramLength; n <= maxGramLength; n++ {
+			for i := 0; i+n <= len(han); i++ {
+				grams = append(grams, string(han[i:i+n]))
+			}
+		}
+		han = han[:0]
+	}
+	flushWord := func() {
+		if len(word) >= minGramLength {
+			grams = append(grams, strings.ToLower(string(word)))
+		}
+		word = word[:0]
+	}
+
+	for _, r := range title {
+		switch {
+		case isHan(r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r):
+			flushHan()
+			word = append(word, r)
+		default:
+			flushHan()
+			flushWord()
+		}
+	}
+	flushHan()
+	flushWord()
+	return grams
+}
+
+// isHan 是否为汉字
+func isHan(r rune) bool {
+	return unicode.Is(unicode.Han, r)
+}
+
+// add 加入建议词，同一个词来自多个来源时保留得分最高的
+func add(entries map[string]*Suggestion, text, source string, score float64) {
+	key := NormalizeQuery(text)
+	if key == "" {
+		return
+	}
+	if existing, ok := entries[key]; ok && existing.Score >= score {
+		return
+	}
+	entries[key] = &Suggestion{Text: key, Source: source, Score: score}
+}
+
+// recordable 搜索词不包含联系方式和 5 位以上的连续数字（手机号、学号、QQ 号等）
+func recordable(query string) bool {
+	if filter.ContainsContact(query) {
+		return false
+	}
+	digits := 0
+	for _, r := range query {
+		if unicode.IsDigit(r) {
+			digits++
+			if digits >= 5 {
+				return false
+			}
+		} else {
+			digits = 0
+		}
+	}
+	return true
+}
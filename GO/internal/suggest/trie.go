@@ -0,0 +1,89 @@
+package suggest
+
+import "sort"
+
+// Suggestion 搜索建议
+type Suggestion struct {
+	Text   string  `json:"text"`
+	Source string  `json:"source"` // query: 热门搜索, tag: 标签, title: 标题短语
+	Score  float64 `json:"score"`
+}
+
+// trieNode 前缀树节点，top 保存以该节点为前缀的得分最高的建议
+type trieNode struct {
+	children map[rune]*trieNode
+	entry    *Suggestion
+	top      []*Suggestion
+}
+
+// trie 建议词前缀树，建立后只读，查询时直接返回节点上预先排好的结果
+type trie struct {
+	root *trieNode
+	size int
+}
+
+// newTrie 由建议词建立前缀树，每个节点保留 topK 个结果
+func newTrie(entries map[string]*Suggestion, topK int) *trie {
+	t := &trie{root: &trieNode{}}
+	for key, entry := range entries {
+		node := t.root
+		for _, r := range key {
+			if node.children == nil {
+				node.children = make(map[rune]*trieNode)
+			}
+			child, ok := node.children[r]
+			if !ok {
+				child = &trieNode{}
+				node.children[r] = child
+			}
+			node = child
+		}
+		node.entry = entry
+		t.size++
+	}
+	t.root.collect(topK)
+	return t
+}
+
+// collect 自底向上合并子节点的结果
+func (n *trieNode) collect(topK int) {
+	var candidates []*Suggestion
+	if n.entry != nil {
+		candidates = append(candidates, n.entry)
+	}
+	for _, child := range n.children {
+		child.collect(topK)
+		candidates = append(candidates, child.top...)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].Text < candidates[j].Text
+	})
+	if len(candidates) > topK {
+		candidates = candidates[:topK]
+	}
+	n.top = candidates
+}
+
+// find 返回以 prefix 开头的得分最高的建议
+func (t *trie) find(prefix string, limit int) []Suggestion {
+	node := t.root
+	for _, r := range prefix {
+		child, ok := node.children[r]
+		if !ok {
+			return nil
+		}
+		node = child
+	}
+
+	result := make([]Suggestion, 0, limit)
+	for _, entry := range node.top {
+		if len(result) == limit {
+			break
+		}
+		result = append(result, *entry)
+	}
+	return result
+}
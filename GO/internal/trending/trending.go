@@ -0,0 +1,284 @@
+package trending
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"treehole/internal/database"
+	"treehole/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 各项计数增长的权重，回复最能体现讨论热度
+const (
+	replyWeight = 3.0
+	likeWeight  = 2.0
+	viewWeight  = 0.05
+)
+
+// maxRanked 每个时间窗口保存的排行数量
+const maxRanked = 100
+
+// ErrRunning 已有排行计算任务在运行
+var ErrRunning = errors.New("trending refresh is already running")
+
+// Window 热度统计的时间窗口
+type Window struct {
+	Name     string
+	Duration time.Duration
+}
+
+// ParseWindows 解析逗号分隔的时间窗口，如 "1h,24h,7d"，支持以 d 表示天
+func ParseWindows(spec string) ([]Window, error) {
+	var windows []Window
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		var duration time.Duration
+		if days, ok := strings.CutSuffix(name, "d"); ok {
+			n, err := strconv.Atoi(days)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trending window %q", name)
+			}
+			duration = time.Duration(n) * 24 * time.Hour
+		} else {
+			d, err := time.ParseDuration(name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trending window %q", name)
+			}
+			duration = d
+		}
+		if duration <= 0 {
+			return nil, fmt.Errorf("invalid trending window %q", name)
+		}
+		windows = append(windows, Window{Name: name, Duration: duration})
+	}
+	if len(windows) == 0 {
+		return nil, errors.New("no trending windows configured")
+	}
+	return windows, nil
+}
+
+// Service 热度排行服务
+// 根据同步时记录的计数快照计算各时间窗口内的增长，越近的增长权重越高，结果写入排行表
+type Service struct {
+	db      *gorm.DB
+	windows []Window
+
+	running sync.Mutex
+}
+
+// NewService 创建热度排行服务
+func NewService(db *gorm.DB, windows []Window) *Service {
+	return &Service{db: db, windows: windows}
+}
+
+// Windows 配置的时间窗口
+func (s *Service) Windows() []Window {
+	return s.windows
+}
+
+// DefaultWindow 默认时间窗口，优先使用 24h
+func (s *Service) DefaultWindow() Window {
+	for _, window := range s.windows {
+		if window.Duration == 24*time.Hour {
+			return window
+		}
+	}
+	return s.windows[0]
+}
+
+// Window 按名称查找时间窗口
+func (s *Service) Window(name string) (Window, bool) {
+	for _, window := range s.windows {
+		if window.Name == name {
+			return window, true
+		}
+	}
+	return Window{}, false
+}
+
+// Refresh 重新计算所有时间窗口的排行
+func (s *Service) Refresh() error {
+	if !s.running.TryLock() {
+		return ErrRunning
+	}
+	defer s.running.Unlock()
+
+	now := time.Now()
+	for _, window := range s.windows {
+		if err := s.refreshWindow(window, now); err != nil {
+			return fmt.Errorf("failed to refresh %s ranking: %v", window.Name, err)
+		}
+	}
+	return nil
+}
+
+// RefreshJob 供定时任务调用的排行计算任务
+func (s *Service) RefreshJob() {
+	if err := s.Refresh(); err != nil {
+		log.Printf("Trending refresh failed: %v", err)
+	}
+}
+
+// postScore 单个帖子在时间窗口内的得分
+type postScore struct {
+	postID     uint
+	score      float64
+	replyDelta int
+	likeDelta  int
+	viewDelta  int
+}
+
+// refreshWindow 计算一个时间窗口的排行并替换原有结果
+func (s *Service) refreshWindow(window Window, now time.Time) error {
+	start := now.Add(-window.Duration)
+
+	var snapshots []models.PostMetric
+	if err := s.db.Where("captured_at >= ?", start).
+		Order("post_id asc, captured_at asc").
+		Find(&snapshots).Error; err != nil {
+		return err
+	}
+
+	// 窗口开始前的最后一个快照作为基线
+	var baselines []models.PostMetric
+	if err := s.db.Raw(`SELECT m.* FROM post_metrics m
+		JOIN (SELECT post_id, MAX(captured_at) AS captured_at FROM post_metrics WHERE captured_at < ? GROUP BY post_id) b
+		ON b.post_id = m.post_id AND b.captured_at = m.captured_at
+		WHERE m.post_id IN (SELECT DISTINCT post_id FROM post_metrics WHERE captured_at >= ?)`,
+		start, start).Scan(&baselines).Error; err != nil {
+		return err
+	}
+	baselineByPost := make(map[uint]models.PostMetric, len(baselines))
+	for _, baseline := range baselines {
+		baselineByPost[baseline.PostID] = baseline
+	}
+
+	// 只统计未隐藏的帖子，窗口内发布的帖子以零为基线
+	var postIDs []uint
+	for i, snapshot := range snapshots {
+		if i == 0 || snapshots[i-1].PostID != snapshot.PostID {
+			postIDs = append(postIDs, snapshot.PostID)
+		}
+	}
+	createdAt, err := s.postCreatedAt(postIDs)
+	if err != nil {
+		return err
+	}
+
+	halfLife := window.Duration / 4
+	var scores []postScore
+	for i := 0; i < len(snapshots); {
+		postID := snapshots[i].PostID
+		j := i
+		for j < len(snapshots) && snapshots[j].PostID == postID {
+			j++
+		}
+
+		created, visible := createdAt[postID]
+		if visible {
+			previous, hasBaseline := baselineByPost[postID]
+			series := snapshots[i:j]
+			if !hasBaseline {
+				if created.Before(start) {
+					// 窗口开始前发布但没有更早的快照，以窗口内第一个快照为基线
+					previous, series = series[0], series[1:]
+				} else {
+					previous = models.PostMetric{}
+				}
+			}
+			if score := scoreSeries(previous, series, now, halfLife); score.score > 0 {
+				score.postID = postID
+				scores = append(scores, score)
+			}
+		}
+		i = j
+	}
+
+	sort.Slice(scores, func(a, b int) bool {
+		if scores[a].score != scores[b].score {
+			return scores[a].score > scores[b].score
+		}
+		return scores[a].postID > scores[b].postID
+	})
+	if len(scores) > maxRanked {
+		scores = scores[:maxRanked]
+	}
+
+	return database.SafeTransaction(s.db, func(tx *gorm.DB) error {
+		if err := tx.Where("time_window = ?", window.Name).Delete(&models.TrendingPost{}).Error; err != nil {
+			return err
+		}
+		for i, score := range scores {
+			if err := tx.Create(&models.TrendingPost{
+				Window:     window.Name,
+				Rank:       i + 1,
+				PostID:     score.postID,
+				Score:      math.Round(score.score*1000) / 1000,
+				ReplyDelta: score.replyDelta,
+				LikeDelta:  score.likeDelta,
+				ViewDelta:  score.viewDelta,
+				ComputedAt: now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// postCreatedAt 查询未隐藏帖子的发布时间
+func (s *Service) postCreatedAt(postIDs []uint) (map[uint]time.Time, error) {
+	result := make(map[uint]time.Time, len(postIDs))
+	// 分批查询，避免 IN 参数过多
+	for start := 0; start < len(postIDs); start += 500 {
+		end := start + 500
+		if end > len(postIDs) {
+			end = len(postIDs)
+		}
+		var posts []models.Post
+		if err := s.db.Select("id, created_at").Where("id IN ?", postIDs[start:end]).Find(&posts).Error; err != nil {
+			return nil, err
+		}
+		for _, post := range posts {
+			result[post.ID] = post.CreatedAt
+		}
+	}
+	return result, nil
+}
+
+// scoreSeries 累加相邻快照之间的增长，每段增长按发生时间指数衰减
+func scoreSeries(previous models.PostMetric, series []models.PostMetric, now time.Time, halfLife time.Duration) postScore {
+	var score postScore
+	for _, current := range series {
+		replies := positive(current.ReplyCount - previous.ReplyCount)
+		likes := positive(current.LikeNum - previous.LikeNum)
+		views := positive(current.ViewCount - previous.ViewCount)
+
+		decay := math.Pow(0.5, float64(now.Sub(current.CapturedAt))/float64(halfLife))
+		score.score += decay * (replyWeight*float64(replies) + likeWeight*float64(likes) + viewWeight*float64(views))
+		score.replyDelta += replies
+		score.likeDelta += likes
+		score.viewDelta += views
+		previous = current
+	}
+	return score
+}
+
+// positive 计数减少（如源站删除回复）时不计为负增长
+func positive(delta int) int {
+	if delta < 0 {
+		return 0
+	}
+	return delta
+}
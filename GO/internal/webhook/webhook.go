@@ -0,0 +1,596 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"treehole/internal/classifier"
+	"treehole/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// 事件类型
+const (
+	EventPostCreated      = "post.created"
+	EventReplyCreated     = "reply.created"
+	EventPostStateChanged = "post.state_changed"
+	EventSyncCompleted    = "sync.completed"
+	EventSyncFailed       = "sync.failed"
+	EventPing             = "ping"         // 管理员测试 Webhook 时发送，不需要订阅
+	EventSavedSearch      = "saved_search" // 关键词订阅命中时推送到订阅者填写的地址
+)
+
+// Events 可以订阅的事件类型
+var Events = []string{EventPostCreated, EventReplyCreated, EventPostStateChanged, EventSyncCompleted, EventSyncFailed}
+
+// 投递状态
+const (
+	StatusPending = "pending"
+	StatusSuccess = "success"
+	StatusFailed  = "failed"
+)
+
+// 重试间隔从 retryBase 开始逐次翻倍，最长 retryMax
+const (
+	retryBase = 30 * time.Second
+	retryMax  = time.Hour
+)
+
+// batchSize 每批投递的数量
+const batchSize = 100
+
+// deliveryRetention 已结束的投递记录保留时间，purgeInterval 清理的最短间隔
+const (
+	deliveryRetention = 30 * 24 * time.Hour
+	purgeInterval     = time.Hour
+)
+
+// maxErrorLength 投递记录中错误信息的最大长度
+const maxErrorLength = 500
+
+// ErrRunning 已有投递任务在运行
+var ErrRunning = errors.New("webhook delivery is already running")
+
+// blockedNetworks net.IP 方法没有覆盖的非公网地址段
+var blockedNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),      // 本网络
+	mustParseCIDR("100.64.0.0/10"),  // 运营商级 NAT
+	mustParseCIDR("192.0.0.0/24"),   // IETF 协议分配
+	mustParseCIDR("198.18.0.0/15"),  // 网络设备基准测试
+	mustParseCIDR("240.0.0.0/4"),    // 保留地址和广播地址
+	mustParseCIDR("64:ff9b::/96"),   // NAT64，可以映射到任意 IPv4 地址
+	mustParseCIDR("64:ff9b:1::/48"), // 本地 NAT64
+}
+
+// Event 待推送的事件，内容事件带有帖子，用于按标签、分组和关键词过滤
+type Event struct {
+	Type  string
+	Post  *models.Post
+	Reply *models.Reply
+	Data  map[string]interface{} // 附加字段，如状态变化前后的值、同步统计
+}
+
+// Config Webhook 投递配置
+type Config struct {
+	MaxAttempts int
+	Timeout     time.Duration
+}
+
+// Service 出站 Webhook 服务
+// 事件按订阅和过滤条件写入投递记录后立即尝试投递，失败的投递由定时任务按退避时间重试
+type Service struct {
+	db           *gorm.DB
+	config       Config
+	client       *http.Client // 管理员注册的地址
+	publicClient *http.Client // 用户填写的地址，只允许连接公网地址
+
+	running   sync.Mutex
+	lastPurge time.Time
+}
+
+// NewService 创建 Webhook 服务
+func NewService(db *gorm.DB, cfg Config) *Service {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 1
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	dialer := &net.Dialer{Timeout: cfg.Timeout, Control: publicAddressOnly}
+	return &Service{
+		db:     db,
+		config: cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		publicClient: &http.Client{
+			Timeout:   cfg.Timeout,
+			Transport: &http.Transport{DialContext: dialer.DialContext},
+		},
+	}
+}
+
+// ValidEvent 是否为可以订阅的事件类型
+func ValidEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign 计算签名，签名内容为 "时间戳.请求体"，接收方应校验签名并拒绝时间戳过旧的请求
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Emit 为订阅了该事件且满足过滤条件的 Webhook 创建投递记录，并在后台开始投递
+func (s *Service) Emit(event Event) {
+	var hooks []models.Webhook
+	if err := s.db.Where("active = ?", true).Find(&hooks).Error; err != nil {
+		log.Printf("Failed to load webhooks: %v", err)
+		return
+	}
+	created := 0
+	for i := range hooks {
+		if !subscribed(&hooks[i], event.Type) || !matches(&hooks[i], event) {
+			continue
+		}
+		if err := s.enqueue(&hooks[i], event); err != nil {
+			log.Printf("Failed to enqueue webhook %d: %v", hooks[i].ID, err)
+			continue
+		}
+		created++
+	}
+	if created > 0 {
+		s.kick()
+	}
+}
+
+// NotifySavedSearch 为关键词订阅填写的地址创建投递记录并在后台投递，与注册的 Webhook 一样签名和重试
+func (s *Service) NotifySavedSearch(search *models.SavedSearch, payloads []map[string]interface{}) error {
+	if search.WebhookURL == "" || len(payloads) == 0 {
+		return nil
+	}
+	if search.WebhookSecret == "" {
+		return fmt.Errorf("saved search %d has no webhook secret", search.ID)
+	}
+	target := savedSearchTarget(search)
+	for _, payload := range payloads {
+		delivery, err := s.build(target, Event{Type: EventSavedSearch, Data: payload})
+		if err != nil {
+			return err
+		}
+		delivery.SavedSearchID = search.ID
+		if err := s.db.Create(delivery).Error; err != nil {
+			return err
+		}
+	}
+	s.kick()
+	return nil
+}
+
+// Ping 向指定 Webhook 发送测试事件，不检查订阅和过滤条件
+func (s *Service) Ping(hook *models.Webhook) (*models.WebhookDelivery, error) {
+	delivery, err := s.build(hook, Event{Type: EventPing, Data: map[string]interface{}{"webhook_id": hook.ID}})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Create(delivery).Error; err != nil {
+		return nil, err
+	}
+	s.kick()
+	return delivery, nil
+}
+
+// Redeliver 重新投递，重置尝试次数
+func (s *Service) Redeliver(delivery *models.WebhookDelivery) error {
+	now := time.Now()
+	if err := s.db.Model(delivery).Updates(map[string]interface{}{
+		"status":          StatusPending,
+		"attempts":        0,
+		"error":           "",
+		"next_attempt_at": &now,
+	}).Error; err != nil {
+		return err
+	}
+	s.kick()
+	return nil
+}
+
+// Deliver 投递所有到期的待投递记录，返回投递成功的数量
+func (s *Service) Deliver() (int, error) {
+	if !s.running.TryLock() {
+		return 0, ErrRunning
+	}
+	defer s.running.Unlock()
+
+	if time.Since(s.lastPurge) > purgeInterval {
+		if err := s.db.Where("status <> ? AND created_at < ?", StatusPending, time.Now().Add(-deliveryRetention)).
+			Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return 0, err
+		}
+		s.lastPurge = time.Now()
+	}
+
+	delivered := 0
+	targets := make(map[targetKey]*models.Webhook)
+	for {
+		var deliveries []models.WebhookDelivery
+		if err := s.db.Where("status = ? AND next_attempt_at <= ?", StatusPending, time.Now()).
+			Order("id asc").Limit(batchSize).Find(&deliveries).Error; err != nil {
+			return delivered, err
+		}
+		for i := range deliveries {
+			hook, err := s.target(&deliveries[i], targets)
+			if err != nil {
+				return delivered, err
+			}
+			success, err := s.attempt(&deliveries[i], hook)
+			if err != nil {
+				return delivered, err
+			}
+			if success {
+				delivered++
+			}
+		}
+		if len(deliveries) < batchSize {
+			return delivered, nil
+		}
+	}
+}
+
+// DeliverJob 供定时任务调用的重试任务
+func (s *Service) DeliverJob() {
+	delivered, err := s.Deliver()
+	if errors.Is(err, ErrRunning) {
+		return
+	}
+	if err != nil {
+		log.Printf("Webhook delivery failed: %v", err)
+		return
+	}
+	if delivered > 0 {
+		log.Printf("Delivered %d webhooks", delivered)
+	}
+}
+
+// targetKey 投递目标的缓存键
+type targetKey struct {
+	savedSearch bool
+	id          uint
+}
+
+// target 查找投递记录的目标，关键词订阅的地址转换为 Webhook，目标已删除时返回 nil
+func (s *Service) target(delivery *models.WebhookDelivery, cache map[targetKey]*models.Webhook) (*models.Webhook, error) {
+	key := targetKey{id: delivery.WebhookID}
+	if delivery.SavedSearchID != 0 {
+		key = targetKey{savedSearch: true, id: delivery.SavedSearchID}
+	}
+	if hook, ok := cache[key]; ok {
+		return hook, nil
+	}
+
+	var hook *models.Webhook
+	if key.savedSearch {
+		var search models.SavedSearch
+		result := s.db.Where("id = ?", key.id).Limit(1).Find(&search)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected > 0 {
+			hook = savedSearchTarget(&search)
+		}
+	} else {
+		var found models.Webhook
+		result := s.db.Where("id = ?", key.id).Limit(1).Find(&found)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected > 0 {
+			hook = &found
+		}
+	}
+	cache[key] = hook
+	return hook, nil
+}
+
+// savedSearchTarget 关键词订阅的推送地址，清空地址后不再投递
+func savedSearchTarget(search *models.SavedSearch) *models.Webhook {
+	return &models.Webhook{
+		URL:    search.WebhookURL,
+		Secret: search.WebhookSecret,
+		Active: search.WebhookURL != "",
+	}
+}
+
+// kick 在后台开始投递，已有投递任务在运行时由其处理
+func (s *Service) kick() {
+	go func() {
+		if _, err := s.Deliver(); err != nil && !errors.Is(err, ErrRunning) {
+			log.Printf("Webhook delivery failed: %v", err)
+		}
+	}()
+}
+
+// enqueue 为 Webhook 创建待投递记录
+func (s *Service) enqueue(hook *models.Webhook, event Event) error {
+	delivery, err := s.build(hook, event)
+	if err != nil {
+		return err
+	}
+	return s.db.Create(delivery).Error
+}
+
+// build 生成事件的请求体和投递记录
+func (s *Service) build(hook *models.Webhook, event Event) (*models.WebhookDelivery, error) {
+	data := make(map[string]interface{}, len(event.Data)+2)
+	for key, value := range event.Data {
+		data[key] = value
+	}
+	if event.Post != nil {
+		data["post"] = postPayload(event.Post)
+	}
+	if event.Reply != nil {
+		data["reply"] = replyPayload(event.Reply)
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"event":      event.Type,
+		"created_at": time.Now(),
+		"data":       data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &models.WebhookDelivery{
+		WebhookID:     hook.ID,
+		Event:         event.Type,
+		Payload:       string(body),
+		Status:        StatusPending,
+		NextAttemptAt: &now,
+	}, nil
+}
+
+// attempt 投递一次并更新记录，Webhook 已删除或停用时直接标记失败
+func (s *Service) attempt(delivery *models.WebhookDelivery, hook *models.Webhook) (bool, error) {
+	delivery.Attempts++
+	var code int
+	var err error
+	if hook == nil || !hook.Active {
+		err = errors.New("webhook is deleted or disabled")
+		delivery.Attempts = s.config.MaxAttempts
+	} else {
+		code, err = s.send(hook, delivery)
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"attempts":      delivery.Attempts,
+		"response_code": code,
+	}
+	success := err == nil
+	switch {
+	case success:
+		updates["status"] = StatusSuccess
+		updates["error"] = ""
+		updates["delivered_at"] = &now
+		updates["next_attempt_at"] = nil
+	case delivery.Attempts >= s.config.MaxAttempts:
+		updates["status"] = StatusFailed
+		updates["error"] = truncate(err.Error(), maxErrorLength)
+		updates["next_attempt_at"] = nil
+	default:
+		next := now.Add(backoff(delivery.Attempts))
+		updates["error"] = truncate(err.Error(), maxErrorLength)
+		updates["next_attempt_at"] = &next
+	}
+	if dbErr := s.db.Model(delivery).Updates(updates).Error; dbErr != nil {
+		return false, dbErr
+	}
+	return success, nil
+}
+
+// send 发送签名的请求，2xx 状态码视为成功
+func (s *Service) send(hook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Treehole-Webhook/1.0")
+	req.Header.Set("X-Treehole-Event", delivery.Event)
+	req.Header.Set("X-Treehole-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-Treehole-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Treehole-Signature", Sign(hook.Secret, timestamp, body))
+
+	client := s.client
+	if delivery.SavedSearchID != 0 {
+		client = s.publicClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// ValidatePublicURL 检查用户填写的推送地址，只允许 http 和 https，且不能指向内网地址
+func ValidatePublicURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("webhook URL must be an absolute http or https URL")
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !publicIP(ip) {
+		return errors.New("webhook URL must not point to a private address")
+	}
+	if u.User != nil {
+		return errors.New("webhook URL must not contain credentials")
+	}
+	return nil
+}
+
+// publicAddressOnly 连接前检查解析后的地址，防止域名解析到内网地址
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIP(ip) {
+		return fmt.Errorf("webhook address %s is not allowed", host)
+	}
+	return nil
+}
+
+// publicIP 是否为公网地址
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// mustParseCIDR 解析常量地址段
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+// backoff 第 attempts 次失败后的重试间隔
+func backoff(attempts int) time.Duration {
+	delay := retryBase
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= retryMax {
+			return retryMax
+		}
+	}
+	return delay
+}
+
+// subscribed Webhook 是否订阅了该事件，未指定事件时订阅全部
+func subscribed(hook *models.Webhook, event string) bool {
+	if strings.TrimSpace(hook.Events) == "" {
+		return true
+	}
+	for _, e := range strings.Split(hook.Events, ",") {
+		if strings.TrimSpace(e) == event {
+			return true
+		}
+	}
+	return false
+}
+
+// matches 内容事件是否满足 Webhook 的标签、分组和关键词过滤条件，同步事件不过滤
+// 帖子事件匹配标题和内容，回复事件匹配回复内容
+func matches(hook *models.Webhook, event Event) bool {
+	if event.Post == nil {
+		return true
+	}
+	post := event.Post
+	if hook.RadioGroup != "" && hook.RadioGroup != post.RadioGroup {
+		return false
+	}
+	if tags := classifier.SplitTags(hook.Tags); len(tags) > 0 {
+		postTags := make(map[string]bool)
+		for _, tag := range classifier.SplitTags(post.Tag) {
+			postTags[tag] = true
+		}
+		found := false
+		for _, tag := range tags {
+			if postTags[tag] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if keywords := strings.Fields(strings.ToLower(hook.Keywords)); len(keywords) > 0 {
+		text := post.Title + "\n" + post.Content
+		if event.Reply != nil {
+			text = event.Reply.Content
+		}
+		text = strings.ToLower(text)
+		for _, keyword := range keywords {
+			if !strings.Contains(text, keyword) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// postPayload 推送的帖子字段，不包含 IP、作者ID和联系方式
+func postPayload(post *models.Post) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           post.ID,
+		"original_id":  post.OriginalID,
+		"title":        post.Title,
+		"content":      post.Content,
+		"author":       post.Author,
+		"radio_group":  post.RadioGroup,
+		"campus_group": post.CampusGroup,
+		"tag":          post.Tag,
+		"state":        post.State,
+		"images":       post.Images,
+		"created_at":   post.CreatedAt,
+	}
+}
+
+// replyPayload 推送的回复字段，不包含作者ID
+func replyPayload(reply *models.Reply) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          reply.ID,
+		"post_id":     reply.PostID,
+		"original_id": reply.OriginalID,
+		"content":     reply.Content,
+		"author":      reply.Author,
+		"parent_id":   reply.ParentID,
+		"images":      reply.Images,
+		"created_at":  reply.CreatedAt,
+	}
+}
+
+// truncate 按字符截断文本
+func truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return string(runes[:length])
+}
@@ -5,10 +5,23 @@ import (
 	"os"
 
 	"treehole/internal/api"
+	"treehole/internal/backup"
+	"treehole/internal/classifier"
 	"treehole/internal/config"
 	"treehole/internal/database"
+	"treehole/internal/identity"
+	"treehole/internal/metrics"
+	"treehole/internal/notify"
+	"treehole/internal/ratelimit"
+	"treehole/internal/related"
+	"treehole/internal/retention"
+	"treehole/internal/savedsearch"
 	"treehole/internal/scheduler"
 	"treehole/internal/scraper"
+	"treehole/internal/subscription"
+	"treehole/internal/suggest"
+	"treehole/internal/trending"
+	"treehole/internal/webhook"
 
 	"github.com/joho/godotenv"
 )
@@ -22,6 +35,16 @@ func main() {
 	// 初始化配置
 	cfg := config.Load()
 
+	// 子命令执行后退出，不启动服务
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "export":
+			os.Exit(runExport(cfg, os.Args[2:]))
+		case "import":
+			os.Exit(runImport(cfg, os.Args[2:]))
+		}
+	}
+
 	// 初始化数据库
 	db, err := database.InitDB(cfg.DatabaseURL)
 	if err != nil {
@@ -36,13 +59,155 @@ func main() {
 	// 初始化爬虫
 	scraperService := scraper.NewService(db, cfg)
 
+	// 初始化标签分类
+	classifierService := classifier.NewService(db, classifier.Config{
+		RulesFile:       cfg.ClassifierRulesFile,
+		BatchSize:       cfg.ClassifierBatchSize,
+		BayesEnabled:    cfg.ClassifierBayesEnabled,
+		BayesMinSamples: cfg.ClassifierBayesMinSamples,
+		BayesThreshold:  cfg.ClassifierBayesThreshold,
+	})
+
+	// 初始化热度排行
+	trendingWindows, err := trending.ParseWindows(cfg.TrendingWindows)
+	if err != nil {
+		log.Fatalf("Invalid TRENDING_WINDOWS: %v", err)
+	}
+	trendingService := trending.NewService(db, trendingWindows)
+
+	// 初始化帖子计数快照
+	metricsService := metrics.NewService(db, metrics.Config{
+		HourlyAfter: cfg.MetricsHourlyAfter,
+		DailyAfter:  cfg.MetricsDailyAfter,
+		Retention:   cfg.MetricsRetention,
+	})
+
+	// 初始化相关帖子索引
+	relatedService := related.NewService(db)
+
+	// 初始化搜索建议
+	suggestService := suggest.NewService(db, suggest.Config{
+		QueryDays:     cfg.SuggestQueryDays,
+		MinQueryCount: cfg.SuggestMinQueryCount,
+	})
+
+	// 初始化出站 Webhook，同步产生的事件写入投递记录
+	webhookService := webhook.NewService(db, webhook.Config{
+		MaxAttempts: cfg.WebhookMaxAttempts,
+		Timeout:     cfg.WebhookTimeout,
+	})
+	scraperService.OnEvent(webhookService.Emit)
+
+	// 初始化通知、关键词订阅和帖子订阅，同步结束和本地发帖、回复后检查新内容
+	notifyService := notify.NewService(db)
+	savedSearchService := savedsearch.NewService(db, notifyService, webhookService)
+	scraperService.OnSynced(savedSearchService.MatchJob)
+	subscriptionService := subscription.NewService(db, notifyService)
+	scraperService.OnSynced(subscriptionService.ProcessJob)
+
+	// 初始化数据库备份
+	backupService := backup.NewService(db, backup.Config{
+		Dir:    cfg.BackupDir,
+		Keep:   cfg.BackupKeep,
+		MaxAge: cfg.BackupMaxAge,
+	})
+
+	// 初始化数据保留策略
+	if !retention.ValidMode(cfg.RetentionOpenIDMode) {
+		log.Fatalf("Invalid RETENTION_OPENID_MODE: %s", cfg.RetentionOpenIDMode)
+	}
+	if cfg.RetentionOpenIDAfter > 0 && cfg.RetentionOpenIDMode == retention.ModeHash {
+		if cfg.RetentionHashSecret == "" {
+			log.Fatalf("RETENTION_HASH_SECRET is required when RETENTION_OPENID_MODE is hash")
+		}
+		if cfg.RetentionHashSecret == cfg.IdentitySecret {
+			log.Fatalf("RETENTION_HASH_SECRET must not be the same as IDENTITY_SECRET")
+		}
+	}
+	retentionService := retention.NewService(db, retention.Config{
+		OpenIDAfter:       cfg.RetentionOpenIDAfter,
+		OpenIDMode:        cfg.RetentionOpenIDMode,
+		HashSecret:        cfg.RetentionHashSecret,
+		IPAfter:           cfg.RetentionIPAfter,
+		WechatAfter:       cfg.RetentionWechatAfter,
+		PurgeDeletedAfter: cfg.RetentionPurgeDeletedAfter,
+	})
+
+	// 检查匿名身份签名密钥
+	if err := identity.ValidateSecret(cfg.IdentitySecret); err != nil {
+		log.Fatalf("Invalid IDENTITY_SECRET: %v", err)
+	}
+
+	// 检查 CSRF 令牌签名密钥，与身份密钥分开
+	if cfg.CSRFProtection {
+		if err := identity.ValidateSecret(cfg.CSRFSecret); err != nil {
+			log.Fatalf("Invalid CSRF_SECRET: %v", err)
+		}
+		if cfg.CSRFSecret == cfg.IdentitySecret {
+			log.Fatalf("CSRF_SECRET must not be the same as IDENTITY_SECRET")
+		}
+	}
+
+	// 检查敏感字段可见策略
+	if _, err := api.ParseFieldPolicy(cfg.FieldVisibility); err != nil {
+		log.Fatalf("Invalid FIELD_VISIBILITY: %v", err)
+	}
+
+	// 检查限流策略
+	if _, err := ratelimit.ParsePolicies(cfg.RateLimitRoutes, cfg.RateLimitRead, cfg.RateLimitWrite); err != nil {
+		log.Fatalf("Invalid rate limit configuration: %v", err)
+	}
+
 	// 启动定时任务
 	scheduler := scheduler.New(scraperService)
+	if err := scheduler.AddJob(cfg.ClassifierCron, classifierService.RunJob); err != nil {
+		log.Printf("Failed to add classifier job: %v", err)
+	}
+	if err := scheduler.AddJob(cfg.TrendingCron, trendingService.RefreshJob); err != nil {
+		log.Printf("Failed to add trending job: %v", err)
+	}
+	if err := scheduler.AddJob(cfg.MetricsCompactCron, metricsService.CompactJob); err != nil {
+		log.Printf("Failed to add metrics compaction job: %v", err)
+	}
+	if err := scheduler.AddJob(cfg.RelatedCron, relatedService.UpdateJob); err != nil {
+		log.Printf("Failed to add related index job: %v", err)
+	}
+	if err := scheduler.AddJob(cfg.SuggestCron, suggestService.RebuildJob); err != nil {
+		log.Printf("Failed to add suggestion rebuild job: %v", err)
+	}
+	if err := scheduler.AddJob(cfg.WebhookRetryCron, webhookService.DeliverJob); err != nil {
+		log.Printf("Failed to add webhook delivery job: %v", err)
+	}
+	if cfg.BackupCron != "off" {
+		if err := scheduler.AddJob(cfg.BackupCron, backupService.RunJob); err != nil {
+			log.Printf("Failed to add backup job: %v", err)
+		}
+	}
+	if cfg.RetentionCron != "off" {
+		if err := scheduler.AddJob(cfg.RetentionCron, retentionService.RunJob); err != nil {
+			log.Printf("Failed to add retention job: %v", err)
+		}
+	}
 	scheduler.Start()
+	go suggestService.RebuildJob()
 	defer scheduler.Stop()
 
 	// 启动 API 服务器
-	router := api.SetupRouter(db, scraperService)
+	router := api.SetupRouter(db, cfg, api.Services{
+		Scraper:    scraperService,
+		Scheduler:  scheduler,
+		Classifier: classifierService,
+		Trending:   trendingService,
+		Metrics:    metricsService,
+		Related:    relatedService,
+		Suggest:    suggestService,
+		Notify:     notifyService,
+		Searches:   savedSearchService,
+		Threads:    subscriptionService,
+		Webhooks:   webhookService,
+		Backups:    backupService,
+		Retention:  retentionService,
+	})
 	
 	port := os.Getenv("PORT")
 	if port == "" {
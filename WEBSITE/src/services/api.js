@@ -9,10 +9,44 @@ const api = axios.create({
   },
 });
 
+// 写请求需要携带 CSRF 令牌，过期前一分钟重新获取
+let csrfToken = null;
+let csrfExpiresAt = 0;
+
+const fetchCSRFToken = async () => {
+  if (!csrfToken || Date.now() > csrfExpiresAt - 60 * 1000) {
+    const { data } = await axios.get("/api/v1/csrf-token");
+    csrfToken = data.csrf_token;
+    csrfExpiresAt = new Date(data.expires_at).getTime();
+  }
+  return csrfToken;
+};
+
+// 请求拦截器
+api.interceptors.request.use(async (config) => {
+  const method = (config.method || "get").toLowerCase();
+  if (!["get", "head", "options"].includes(method)) {
+    config.headers["X-CSRF-Token"] = await fetchCSRFToken();
+  }
+  return config;
+});
+
 // 响应拦截器
 api.interceptors.response.use(
   (response) => response.data,
-  (error) => {
+  async (error) => {
+    // 令牌过期或身份变化时重新获取令牌并重试一次
+    const config = error.config;
+    if (
+      config &&
+      !config._csrfRetried &&
+      error.response?.status === 403 &&
+      /CSRF/.test(error.response.data?.error || "")
+    ) {
+      config._csrfRetried = true;
+      csrfToken = null;
+      return api(config);
+    }
     console.error("API Error:", error);
     return Promise.reject(error);
   }